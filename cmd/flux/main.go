@@ -147,6 +147,78 @@ func init() {
 		},
 	}
 
+	makeClientCmd := &cobra.Command{
+		Use:   "make:client",
+		Short: "Generate a typed Go client from an OpenAPI spec",
+		Run: func(cmd *cobra.Command, args []string) {
+			spec, _ := cmd.Flags().GetString("spec")
+			pkg, _ := cmd.Flags().GetString("package")
+			output, _ := cmd.Flags().GetString("output")
+			service, _ := cmd.Flags().GetString("service")
+			if err := generateClient(spec, pkg, output, service); err != nil {
+				fmt.Printf("Error generating client: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	makeClientCmd.Flags().String("spec", filepath.Join("docs", "openapi.json"), "Path or URL of the OpenAPI spec")
+	makeClientCmd.Flags().String("package", "", "Package name of the generated client, e.g. ordersclient")
+	makeClientCmd.Flags().String("output", "", "Output directory (default: clients/<package>)")
+	makeClientCmd.Flags().String("service", "", "Service host for the default base URL (default: package without the client suffix)")
+	makeClientCmd.MarkFlagRequired("package")
+
+	workflowListCmd := &cobra.Command{
+		Use:   "workflow:list",
+		Short: "List workflow instances of a running app",
+		Run: func(cmd *cobra.Command, args []string) {
+			baseURL, _ := cmd.Flags().GetString("url")
+			token, _ := cmd.Flags().GetString("token")
+			name, _ := cmd.Flags().GetString("workflow")
+			status, _ := cmd.Flags().GetString("status")
+			limit, _ := cmd.Flags().GetInt("limit")
+			if err := listWorkflows(baseURL, token, name, status, limit); err != nil {
+				fmt.Printf("Error listing workflows: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	workflowListCmd.Flags().String("workflow", "", "Only list instances of this workflow")
+	workflowListCmd.Flags().String("status", "", "Only list instances with this status, e.g. failed")
+	workflowListCmd.Flags().Int("limit", 50, "Maximum number of instances")
+
+	workflowShowCmd := &cobra.Command{
+		Use:   "workflow:show [id]",
+		Short: "Show a workflow instance with its state and step history",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			baseURL, _ := cmd.Flags().GetString("url")
+			token, _ := cmd.Flags().GetString("token")
+			if err := showWorkflow(baseURL, token, args[0]); err != nil {
+				fmt.Printf("Error showing workflow: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	workflowResumeCmd := &cobra.Command{
+		Use:   "workflow:resume [id]",
+		Short: "Resume a failed workflow instance or run a waiting one now",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			baseURL, _ := cmd.Flags().GetString("url")
+			token, _ := cmd.Flags().GetString("token")
+			if err := resumeWorkflow(baseURL, token, args[0]); err != nil {
+				fmt.Printf("Error resuming workflow: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	for _, c := range []*cobra.Command{workflowListCmd, workflowShowCmd, workflowResumeCmd} {
+		c.Flags().String("url", "http://localhost:3000/workflows", "URL of the app's workflow API")
+		c.Flags().String("token", os.Getenv("FLUX_TOKEN"), "Bearer token for the workflow API (default $FLUX_TOKEN)")
+	}
+
 	serveCmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start the development server with hot reload",
@@ -167,6 +239,10 @@ func init() {
 	rootCmd.AddCommand(makeMiddlewareCmd)
 	rootCmd.AddCommand(makeServiceCmd)
 	rootCmd.AddCommand(docGenerateCmd)
+	rootCmd.AddCommand(makeClientCmd)
+	rootCmd.AddCommand(workflowListCmd)
+	rootCmd.AddCommand(workflowShowCmd)
+	rootCmd.AddCommand(workflowResumeCmd)
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -3,12 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/Fluxgo/flux/pkg/flux/client/codegen"
 )
 
 type ProjectTemplate struct {
@@ -43,9 +46,11 @@ func createNewProject(name string) error {
 		filepath.Join(name, "database", "migrations"),
 		filepath.Join(name, "database", "seeders"),
 		filepath.Join(name, "routes"),
-		filepath.Join(name, "templates"),
+		filepath.Join(name, "templates", "layouts"),
+		filepath.Join(name, "templates", "partials"),
 		filepath.Join(name, "storage", "logs"),
 		filepath.Join(name, "storage", "uploads"),
+		filepath.Join(name, "locales"),
 	}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -60,6 +65,8 @@ import (
 	"log"
 
 	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/Fluxgo/flux/pkg/flux/i18n"
+	"github.com/Fluxgo/flux/pkg/flux/storage"
 	// Import your controllers and models as needed
 	// "` + name + `/app/controllers"
 )
@@ -84,6 +91,20 @@ func main() {
 			// Username: "flux_user",
 			// Password: "flux_password",
 		},
+		View: flux.ViewConfig{
+			Directory:     "templates",
+			DefaultLayout: "main",
+		},
+		// Uploads handled by ctx.Upload end up here
+		Storage: storage.Config{
+			Driver: "local",
+			Root:   "storage/uploads",
+		},
+		// One file per locale; use ctx.T("key") in handlers
+		I18n: i18n.Config{
+			Directory:     "locales",
+			DefaultLocale: "en",
+		},
 	})
 	if err != nil {
 		log.Fatalf("Failed to create application: %v", err)
@@ -162,13 +183,45 @@ view:
   engine: "go-template" 
   directory: "templates"
   extension: ".gohtml"
-  cache: true
+  default_layout: "main"
+  # Templates are always cached when ENVIRONMENT=production
+  cache: false
+
+
+storage:
+  driver: "local" # local, memory or s3
+  root: "storage/uploads"
+  # s3:
+  #   endpoint: "https://s3.amazonaws.com"
+  #   region: "us-east-1"
+  #   bucket: "my-bucket"
+  #   access_key: ""
+  #   secret_key: ""
+  #   path_style: false
+
+
+i18n:
+  directory: "locales"
+  default_locale: "en"
+  # Explicit locale choice, checked before Accept-Language
+  query_param: "lang"
+  cookie: "lang"
 `
 
 	if err := os.WriteFile(filepath.Join(name, "config", "flux.yaml"), []byte(configContent), 0644); err != nil {
 		return fmt.Errorf("failed to create flux.yaml: %w", err)
 	}
 
+	localeContent := `# Messages for ctx.T("key"). Add fr.yaml, de.yaml, ... next to this file.
+welcome: "Welcome to {name}!"
+validation:
+  required: "The {field} field is required"
+`
+
+	if err := os.WriteFile(filepath.Join(name, "locales", "en.yaml"), []byte(localeContent), 0644); err != nil {
+		return fmt.Errorf("failed to create en.yaml: %w", err)
+	}
+
 	modContent := `module ` + name + `
 
 go 1.20
@@ -182,6 +235,30 @@ require (
 		return fmt.Errorf("failed to create go.mod: %w", err)
 	}
 
+	layoutContent := `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<title>{{block "title" .}}` + name + `{{end}}</title>
+</head>
+<body>
+	{{template "partials/header" .}}
+	<main>{{template "content" .}}</main>
+</body>
+</html>
+`
+
+	if err := os.WriteFile(filepath.Join(name, "templates", "layouts", "main.gohtml"), []byte(layoutContent), 0644); err != nil {
+		return fmt.Errorf("failed to create main layout: %w", err)
+	}
+
+	headerContent := `<header><a href="/">` + name + `</a></header>
+`
+
+	if err := os.WriteFile(filepath.Join(name, "templates", "partials", "header.gohtml"), []byte(headerContent), 0644); err != nil {
+		return fmt.Errorf("failed to create header partial: %w", err)
+	}
+
 	readmeContent := `# ` + name + `
 
 A web application built with flux Framework.
@@ -948,6 +1025,41 @@ func generateDocumentation() error {
 	return nil
 }
 
+// generateClient writes a typed client for the spec at specPath, which may
+// be a file or an http(s) URL such as a gateway's /openapi.json.
+func generateClient(specPath, pkg, output, service string) error {
+	var spec []byte
+	var err error
+	if strings.HasPrefix(specPath, "http://") || strings.HasPrefix(specPath, "https://") {
+		resp, err := http.Get(specPath)
+		if err != nil {
+			return fmt.Errorf("failed to download spec: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to download spec: %s", resp.Status)
+		}
+		if spec, err = io.ReadAll(resp.Body); err != nil {
+			return fmt.Errorf("failed to download spec: %w", err)
+		}
+	} else if spec, err = os.ReadFile(specPath); err != nil {
+		return fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	if output == "" {
+		output = filepath.Join("clients", pkg)
+	}
+
+	written, err := codegen.Write(output, spec, codegen.Options{Package: pkg, Service: service})
+	if err != nil {
+		return err
+	}
+	for _, file := range written {
+		fmt.Printf("Generated client: %s\n", file)
+	}
+	return nil
+}
+
 func generateMarkdownDocumentation(app *flux.Application, spec *flux.OpenAPISpec) string {
 	var doc strings.Builder
 
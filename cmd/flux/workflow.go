@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// workflowInstance mirrors the instances served by app.WorkflowAPI.
+type workflowInstance struct {
+	ID          string          `json:"id"`
+	Workflow    string          `json:"workflow"`
+	Status      string          `json:"status"`
+	State       json.RawMessage `json:"state"`
+	Step        int             `json:"step"`
+	StepName    string          `json:"step_name"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error"`
+	NextRunAt   *time.Time      `json:"next_run_at"`
+	CompletedAt *time.Time      `json:"completed_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type workflowStep struct {
+	Step       string    `json:"step"`
+	Action     string    `json:"action"`
+	Attempt    int       `json:"attempt"`
+	Error      string    `json:"error"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// workflowRequest calls the workflow API and decodes the data of the
+// response into out.
+func workflowRequest(method, endpoint, token string, out interface{}) error {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+			Title   string `json:"title"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		for _, msg := range []string{apiErr.Message, apiErr.Detail, apiErr.Title, strings.TrimSpace(string(body))} {
+			if msg != "" {
+				return fmt.Errorf("%s: %s", resp.Status, msg)
+			}
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+func listWorkflows(baseURL, token, name, status string, limit int) error {
+	query := url.Values{}
+	if name != "" {
+		query.Set("workflow", name)
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+	query.Set("limit", fmt.Sprint(limit))
+
+	var instances []workflowInstance
+	if err := workflowRequest("GET", strings.TrimRight(baseURL, "/")+"?"+query.Encode(), token, &instances); err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		fmt.Println("No workflow instances found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tWORKFLOW\tSTATUS\tSTEP\tATTEMPTS\tNEXT RUN\tUPDATED")
+	for _, inst := range instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", inst.ID, inst.Workflow, inst.Status, inst.StepName,
+			inst.Attempts, formatWorkflowTime(inst.NextRunAt), inst.UpdatedAt.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func showWorkflow(baseURL, token, id string) error {
+	var result struct {
+		Instance workflowInstance `json:"instance"`
+		History  []workflowStep   `json:"history"`
+	}
+	if err := workflowRequest("GET", strings.TrimRight(baseURL, "/")+"/"+url.PathEscape(id), token, &result); err != nil {
+		return err
+	}
+	printWorkflow(result.Instance)
+
+	if len(result.History) > 0 {
+		fmt.Println("\nHistory:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  STARTED\tACTION\tSTEP\tATTEMPT\tDURATION\tERROR")
+		for _, s := range result.History {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\t%s\n", s.StartedAt.Local().Format(time.DateTime), s.Action, s.Step,
+				s.Attempt, s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond), s.Error)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func resumeWorkflow(baseURL, token, id string) error {
+	var inst workflowInstance
+	if err := workflowRequest("POST", strings.TrimRight(baseURL, "/")+"/"+url.PathEscape(id)+"/resume", token, &inst); err != nil {
+		return err
+	}
+	fmt.Printf("Resumed %s (%s), now %s at step %s\n", inst.ID, inst.Workflow, inst.Status, inst.StepName)
+	return nil
+}
+
+func printWorkflow(inst workflowInstance) {
+	fmt.Printf("ID:         %s\n", inst.ID)
+	fmt.Printf("Workflow:   %s\n", inst.Workflow)
+	fmt.Printf("Status:     %s\n", inst.Status)
+	if inst.StepName != "" {
+		fmt.Printf("Step:       %s (attempts: %d)\n", inst.StepName, inst.Attempts)
+	}
+	if inst.NextRunAt != nil {
+		fmt.Printf("Next run:   %s\n", formatWorkflowTime(inst.NextRunAt))
+	}
+	if inst.LastError != "" {
+		fmt.Printf("Last error: %s\n", inst.LastError)
+	}
+	fmt.Printf("Created:    %s\n", inst.CreatedAt.Local().Format(time.DateTime))
+	if inst.CompletedAt != nil {
+		fmt.Printf("Completed:  %s\n", formatWorkflowTime(inst.CompletedAt))
+	}
+
+	var state interface{}
+	if err := json.Unmarshal(inst.State, &state); err == nil {
+		pretty, _ := json.MarshalIndent(state, "", "  ")
+		fmt.Printf("State:\n%s\n", pretty)
+	}
+}
+
+func formatWorkflowTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -17,9 +17,14 @@ import (
 
 	"github.com/Fluxgo/flux/pkg/flux/auth"
 	"github.com/Fluxgo/flux/pkg/flux/logger"
+	"github.com/Fluxgo/flux/pkg/flux/events"
+	"github.com/Fluxgo/flux/pkg/flux/i18n"
 	"github.com/Fluxgo/flux/pkg/flux/mailer"
+	"github.com/Fluxgo/flux/pkg/flux/outbox"
 	"github.com/Fluxgo/flux/pkg/flux/plugin"
 	"github.com/Fluxgo/flux/pkg/flux/queue"
+	"github.com/Fluxgo/flux/pkg/flux/storage"
+	"github.com/Fluxgo/flux/pkg/flux/workflow"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
@@ -42,8 +47,18 @@ type Application struct {
 	plugins     *plugin.Manager
 	logger      *logger.Logger
 	routes      *RouteManager
+	views       *ViewEngine
+	codecs      *CodecRegistry
+	storage     storage.Disk
+	signer      *storage.Signer
+	i18n        *i18n.Bundle
+	invalidator CacheInvalidator
+	events      *events.Dispatcher
+	outbox      *outbox.Relay
+	workflows   *workflow.Engine
 	mu          sync.RWMutex
 	controllers []interface{}
+	documented  []documentedRoute
 	startTime   time.Time
 }
 
@@ -57,6 +72,13 @@ type Config struct {
 	Mailer      mailer.Config
 	Queue       queue.Config
 	CORS        CORSConfig
+	View        ViewConfig
+	// ErrorFormat is ErrorFormatJSON (default) or ErrorFormatProblem.
+	ErrorFormat string
+	Storage     storage.Config
+	// Key signs download URLs and other tamper-proof values. Keep it secret.
+	Key         string
+	I18n        i18n.Config
 	LogLevel    string
 }
 
@@ -117,19 +139,20 @@ func DefaultRateLimitConfig() RateLimitConfig {
 }
 
 func New(config *Config) (*Application, error) {
+	app := &Application{
+		config:    config,
+		codecs:    DefaultCodecRegistry(),
+		startTime: time.Now(),
+	}
+	app.validator = newValidator(app)
+
 	fiberConfig := fiber.Config{
 		AppName:             config.Name,
 		ServerHeader:        "flux", 
-		ErrorHandler:        defaultErrorHandler,
+		ErrorHandler:        app.handleError,
 		DisableStartupMessage: true, 
 	}
-
-	app := &Application{
-		config:    config,
-		server:    fiber.New(fiberConfig),
-		validator: validator.New(),
-		startTime: time.Now(),
-	}
+	app.server = fiber.New(fiberConfig)
 
 	// Initialize the route manager
 	app.routes = NewRouteManager(app)
@@ -208,6 +231,40 @@ func New(config *Config) (*Application, error) {
 		log.Info("Message queue initialized")
 	}
 
+	if config.Storage.Driver != "" {
+		log.Info("Initializing storage: %s", config.Storage.Driver)
+		store, err := storage.New(config.Storage)
+		if err != nil {
+			log.Error("Failed to initialize storage: %v", err)
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		app.storage = store
+		log.Info("Storage initialized")
+	}
+
+	if config.I18n.FS != nil || config.I18n.Directory != "" {
+		log.Info("Loading translations")
+		bundle, err := i18n.Load(config.I18n)
+		if err != nil {
+			log.Error("Failed to load translations: %v", err)
+			return nil, fmt.Errorf("failed to load translations: %w", err)
+		}
+		app.SetI18n(bundle)
+		app.server.Use(app.localize)
+		log.Info("Translations loaded: %v", bundle.Locales())
+	}
+
+	if config.View.Directory != "" || config.View.FS != nil {
+		log.Info("Initializing view engine")
+		views, err := NewViewEngine(config.View)
+		if err != nil {
+			log.Error("Failed to initialize view engine: %v", err)
+			return nil, fmt.Errorf("failed to initialize view engine: %w", err)
+		}
+		app.views = views
+		log.Info("View engine initialized")
+	}
+
 	log.Info("Loading plugins")
 	plugins := plugin.NewManager(app, "plugins")
 	if err := plugins.LoadPlugins(); err != nil {
@@ -246,17 +303,16 @@ func New(config *Config) (*Application, error) {
 }
 
 func defaultErrorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
+	appErr, message := publicError(c, err)
 
-	
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
-	}
-
-	return c.Status(code).JSON(fiber.Map{
+	body := fiber.Map{
 		"error":   true,
-		"message": err.Error(),
-	})
+		"message": message,
+	}
+	if appErr.Code != "" {
+		body["code"] = appErr.Code
+	}
+	return c.Status(appErr.StatusCode).JSON(body)
 }
 
 func (app *Application) GetConfig() interface{} {
@@ -308,7 +364,7 @@ func (app *Application) RegisterController(controller interface{}) {
 		}
 
 		routeInfo := parseRouteFromMethodName(method.Name, basePath)
-		handler := createHandlerFunc(method, controllerValue)
+		handler := createHandlerFunc(app, method, controllerValue)
 
 		
 		description := descriptionFromMethod(controllerBaseName, method.Name)
@@ -461,9 +517,9 @@ func parseRouteFromMethodName(methodName string, basePath string) RouteInfo {
 	}
 }
 
-func createHandlerFunc(method reflect.Method, controllerValue reflect.Value) fiber.Handler {
+func createHandlerFunc(app *Application, method reflect.Method, controllerValue reflect.Value) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		ctx := &Context{Ctx: c}
+		ctx := NewContext(c, app)
 		result := method.Func.Call([]reflect.Value{controllerValue, reflect.ValueOf(ctx)})
 		if len(result) > 0 && !result[0].IsNil() {
 			if err, ok := result[0].Interface().(error); ok {
@@ -482,6 +538,12 @@ func (app *Application) Start() error {
 	if app.queue != nil {
 		app.queue.Start()
 	}
+	if app.outbox != nil {
+		app.outbox.Start()
+	}
+	if app.workflows != nil {
+		app.workflows.StartPolling()
+	}
 
 	return app.server.Listen(fmt.Sprintf("%s:%d", app.config.Server.Host, app.config.Server.Port))
 }
@@ -499,6 +561,12 @@ func (app *Application) Listen(addr string) error {
 	if app.queue != nil {
 		app.queue.Start()
 	}
+	if app.outbox != nil {
+		app.outbox.Start()
+	}
+	if app.workflows != nil {
+		app.workflows.StartPolling()
+	}
 
 	return app.server.Listen(addr)
 }
@@ -525,6 +593,14 @@ func (app *Application) Shutdown() error {
 		app.queue.Stop()
 	}
 
+	if app.outbox != nil {
+		app.outbox.Stop()
+	}
+
+	if app.workflows != nil {
+		app.workflows.StopPolling()
+	}
+
 	if app.plugins != nil {
 		if err := app.plugins.UnloadPlugins(); err != nil {
 			return fmt.Errorf("failed to unload plugins: %w", err)
@@ -552,10 +628,84 @@ func (app *Application) Queue() *queue.Queue {
 	return app.queue
 }
 
+// Events returns the event dispatcher. Queued listeners use the app's queue
+// when one is configured.
+func (app *Application) Events() *events.Dispatcher {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.events == nil {
+		config := events.DefaultConfig()
+		if app.queue != nil {
+			config.Queue = app.queue
+		}
+		if app.logger != nil {
+			config.Logger = app.logger
+		}
+		app.events = events.New(config)
+	}
+	return app.events
+}
+
+// SetEvents replaces the event dispatcher, e.g. with events.NewFake() in
+// tests.
+func (app *Application) SetEvents(dispatcher *events.Dispatcher) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.events = dispatcher
+}
+
+// UseOutbox creates the outbox table and a relay that publishes recorded
+// messages through publisher while the app runs. Record messages with
+// outbox.Record inside app.DB().Transaction.
+func (app *Application) UseOutbox(publisher outbox.Publisher, config outbox.Config) (*outbox.Relay, error) {
+	if app.database == nil {
+		return nil, fmt.Errorf("outbox requires a database")
+	}
+	if err := outbox.Migrate(app.database.DB); err != nil {
+		return nil, err
+	}
+	if config.Logger == nil && app.logger != nil {
+		config.Logger = app.logger
+	}
+
+	relay := outbox.NewRelay(app.database.DB, publisher, config)
+	app.mu.Lock()
+	app.outbox = relay
+	app.mu.Unlock()
+	return relay, nil
+}
+
 func (app *Application) Mailer() *mailer.Mailer {
 	return app.mailer
 }
 
+func (app *Application) Views() *ViewEngine {
+	return app.views
+}
+
+func (app *Application) SetViews(views *ViewEngine) {
+	app.views = views
+}
+
+func (app *Application) Storage() storage.Disk {
+	return app.storage
+}
+
+func (app *Application) SetStorage(store storage.Disk) {
+	app.storage = store
+}
+
+func (app *Application) Codecs() *CodecRegistry {
+	return app.codecs
+}
+
+// RegisterCodec adds a response/request body codec used by Context.Negotiate
+// and Context.Bind.
+func (app *Application) RegisterCodec(codec Codec) {
+	app.codecs.Register(codec)
+}
+
 func (app *Application) Plugins() *plugin.Manager {
 	return app.plugins
 }
@@ -587,7 +737,11 @@ func (app *Application) Test(req *http.Request) (*http.Response, error) {
 func (app *Application) AddTracing() {
 	app.server.Use(func(c *fiber.Ctx) error {
 
-		traceID := generateTraceID()
+		// Keep the caller's trace ID so one request can be followed across services.
+		traceID := c.Get("X-Trace-ID")
+		if traceID == "" || len(traceID) > 128 {
+			traceID = generateTraceID()
+		}
 		c.Locals("trace_id", traceID)
 
 		c.Set("X-Trace-ID", traceID)
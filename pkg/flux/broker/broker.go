@@ -0,0 +1,173 @@
+// Package broker publishes messages to topics and delivers them to consumer
+// groups, so microservices can react to each other's events. Every group
+// receives each message once and shares it among its consumers. Delivery is
+// at-least-once: a message is redelivered until a handler acknowledges it by
+// returning nil, so handlers must tolerate duplicates.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var ErrClosed = errors.New("broker: closed")
+
+type Message struct {
+	ID      string
+	Topic   string
+	Payload []byte
+	Headers map[string]string
+	// Deliveries counts how often the message was handed to the group,
+	// including this time. It is 1 on the first delivery.
+	Deliveries int
+}
+
+// Decode unmarshals the JSON payload into v.
+func (m *Message) Decode(v interface{}) error {
+	if err := json.Unmarshal(m.Payload, v); err != nil {
+		return fmt.Errorf("failed to decode message %s: %w", m.ID, err)
+	}
+	return nil
+}
+
+// Handler processes a message. Returning nil acknowledges it; an error
+// leaves it pending so it is delivered again after Config.ClaimIdle.
+type Handler func(ctx context.Context, msg *Message) error
+
+type Broker interface {
+	// Publish sends payload to topic and returns the message ID. Payloads
+	// other than []byte and json.RawMessage are encoded as JSON.
+	Publish(ctx context.Context, topic string, payload interface{}) (string, error)
+	// Subscribe consumes topic as a member of group until Close. A group
+	// created by Subscribe only receives messages published after it.
+	Subscribe(topic, group string, handler Handler) error
+	// Close stops all consumers and waits for running handlers.
+	Close() error
+}
+
+type Logger interface {
+	Error(format string, args ...interface{})
+}
+
+type Config struct {
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// Consumer names this process within its groups. It defaults to
+	// <hostname>-<pid> and must differ between processes.
+	Consumer string `yaml:"consumer" json:"consumer"`
+	// BatchSize is how many messages a consumer reads at once.
+	BatchSize int64 `yaml:"batch_size" json:"batch_size"`
+	// Block is how long a read waits for new messages.
+	Block time.Duration `yaml:"block" json:"block"`
+	// ClaimIdle is how long a message stays unacknowledged before another
+	// consumer of the group takes it over, e.g. after a crash or an error.
+	ClaimIdle time.Duration `yaml:"claim_idle" json:"claim_idle"`
+	// ClaimInterval is how often consumers look for such messages.
+	ClaimInterval time.Duration `yaml:"claim_interval" json:"claim_interval"`
+	// MaxDeliveries moves a message to the "<topic>:dead" stream once it has
+	// failed that many times. Zero retries forever.
+	MaxDeliveries int `yaml:"max_deliveries" json:"max_deliveries"`
+	// MaxLen trims streams to about this many messages. Zero keeps all.
+	MaxLen int64  `yaml:"max_len" json:"max_len"`
+	Logger Logger `yaml:"-" json:"-"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Prefix:        "flux:broker:",
+		BatchSize:     10,
+		Block:         5 * time.Second,
+		ClaimIdle:     30 * time.Second,
+		ClaimInterval: 10 * time.Second,
+		MaxDeliveries: 5,
+		MaxLen:        100000,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	defaults := DefaultConfig()
+	if c.Prefix == "" {
+		c.Prefix = defaults.Prefix
+	}
+	if c.Consumer == "" {
+		host, err := os.Hostname()
+		if err != nil || host == "" {
+			host = "localhost"
+		}
+		c.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.Block <= 0 {
+		c.Block = defaults.Block
+	}
+	if c.ClaimIdle <= 0 {
+		c.ClaimIdle = defaults.ClaimIdle
+	}
+	if c.ClaimInterval <= 0 {
+		c.ClaimInterval = defaults.ClaimInterval
+	}
+	return c
+}
+
+type headersKey struct{}
+
+// WithHeaders adds headers to the messages published with ctx, such as a
+// trace ID.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range headersFrom(ctx) {
+		merged[k] = v
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+func headersFrom(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers
+}
+
+func encode(payload interface{}) ([]byte, error) {
+	switch p := payload.(type) {
+	case []byte:
+		return p, nil
+	case json.RawMessage:
+		return p, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return data, nil
+}
+
+func validate(topic, group string) error {
+	if topic == "" {
+		return errors.New("broker: topic is required")
+	}
+	if group == "" {
+		return errors.New("broker: group is required")
+	}
+	return nil
+}
+
+// handle runs the handler, turning a panic into an error.
+func handle(ctx context.Context, handler Handler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, msg)
+}
+
+func deadTopic(topic string) string {
+	return topic + ":dead"
+}
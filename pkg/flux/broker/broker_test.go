@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderPlaced struct {
+	ID    int     `json:"id"`
+	Total float64 `json:"total"`
+}
+
+type collector struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func (c *collector) handle(ctx context.Context, msg *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+func (c *collector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.messages)
+}
+
+func TestMemoryDeliversOncePerGroup(t *testing.T) {
+	b := NewMemory(DefaultConfig())
+	defer b.Close()
+
+	_, err := b.Publish(context.Background(), "orders.placed", orderPlaced{ID: 0})
+	require.NoError(t, err)
+
+	billing, shippingA, shippingB := &collector{}, &collector{}, &collector{}
+	require.NoError(t, b.Subscribe("orders.placed", "billing", billing.handle))
+	require.NoError(t, b.Subscribe("orders.placed", "shipping", shippingA.handle))
+	require.NoError(t, b.Subscribe("orders.placed", "shipping", shippingB.handle))
+
+	ctx := WithHeaders(context.Background(), map[string]string{"trace_id": "abc"})
+	for i := 1; i <= 10; i++ {
+		_, err := b.Publish(ctx, "orders.placed", orderPlaced{ID: i, Total: 9.5})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return billing.count() == 10 && shippingA.count()+shippingB.count() == 10
+	}, time.Second, 5*time.Millisecond)
+	// Messages published before a group existed are not delivered to it.
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 10, billing.count())
+	assert.Equal(t, 0, b.Pending("orders.placed", "billing"))
+
+	var order orderPlaced
+	msg := billing.messages[0]
+	require.NoError(t, msg.Decode(&order))
+	assert.Equal(t, orderPlaced{ID: 1, Total: 9.5}, order)
+	assert.Equal(t, "abc", msg.Headers["trace_id"])
+	assert.Equal(t, 1, msg.Deliveries)
+	assert.Len(t, b.Published("orders.placed"), 11)
+}
+
+func TestMemoryRedeliversUntilAcknowledged(t *testing.T) {
+	b := NewMemory(Config{ClaimIdle: 10 * time.Millisecond, MaxDeliveries: 5})
+	defer b.Close()
+
+	var mu sync.Mutex
+	var deliveries []int
+	require.NoError(t, b.Subscribe("mail", "mailer", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, msg.Deliveries)
+		if msg.Deliveries < 3 {
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	}))
+
+	_, err := b.Publish(context.Background(), "mail", []byte(`{"to":"a@example.com"}`))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return b.Pending("mail", "mailer") == 0 && len(b.Published("mail")) == 1 && func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(deliveries) == 3
+		}()
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{1, 2, 3}, deliveries)
+}
+
+func TestMemoryMovesFailingMessagesToDeadTopic(t *testing.T) {
+	b := NewMemory(Config{ClaimIdle: 5 * time.Millisecond, MaxDeliveries: 2})
+	defer b.Close()
+
+	dead := &collector{}
+	require.NoError(t, b.Subscribe("mail:dead", "ops", dead.handle))
+	require.NoError(t, b.Subscribe("mail", "mailer", func(ctx context.Context, msg *Message) error {
+		panic("boom")
+	}))
+
+	_, err := b.Publish(context.Background(), "mail", map[string]string{"to": "a@example.com"})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return dead.count() == 1 }, time.Second, 5*time.Millisecond)
+	assert.JSONEq(t, `{"to":"a@example.com"}`, string(dead.messages[0].Payload))
+	assert.Equal(t, 0, b.Pending("mail", "mailer"))
+}
+
+func TestMemoryClose(t *testing.T) {
+	b := NewMemory(DefaultConfig())
+	require.NoError(t, b.Subscribe("orders", "billing", (&collector{}).handle))
+	require.NoError(t, b.Close())
+
+	_, err := b.Publish(context.Background(), "orders", "x")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, b.Subscribe("orders", "billing", (&collector{}).handle), ErrClosed)
+	assert.Error(t, b.Subscribe("orders", "", (&collector{}).handle))
+}
@@ -0,0 +1,225 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process broker for tests and single-process setups. It
+// follows the Redis driver: groups start at the end of the topic, failed
+// messages are redelivered after ClaimIdle and dead messages are published
+// to "<topic>:dead".
+type Memory struct {
+	config Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+	seq    int
+	topics map[string]*memoryTopic
+}
+
+type memoryTopic struct {
+	messages []*Message
+	groups   map[string]*memoryGroup
+}
+
+type memoryGroup struct {
+	next    int
+	pending map[string]*memoryPending
+	// wake is closed and replaced when a message is published.
+	wake chan struct{}
+}
+
+type memoryPending struct {
+	msg         *Message
+	deliveredAt time.Time
+}
+
+// NewMemory creates an in-memory broker. Unset config fields take their
+// defaults, except ClaimIdle and ClaimInterval, which default to 100ms so
+// tests see retries quickly.
+func NewMemory(config Config) *Memory {
+	if config.ClaimIdle <= 0 {
+		config.ClaimIdle = 100 * time.Millisecond
+	}
+	if config.ClaimInterval <= 0 {
+		config.ClaimInterval = config.ClaimIdle
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Memory{
+		config: config.withDefaults(),
+		ctx:    ctx,
+		cancel: cancel,
+		topics: make(map[string]*memoryTopic),
+	}
+}
+
+func (m *Memory) topic(name string) *memoryTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memoryTopic{groups: make(map[string]*memoryGroup)}
+		m.topics[name] = t
+	}
+	return t
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, payload interface{}) (string, error) {
+	if topic == "" {
+		return "", errors.New("broker: topic is required")
+	}
+	data, err := encode(payload)
+	if err != nil {
+		return "", err
+	}
+	msg := &Message{Topic: topic, Payload: data}
+	if headers := headersFrom(ctx); len(headers) > 0 {
+		msg.Headers = headers
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return "", ErrClosed
+	}
+	return m.publish(msg), nil
+}
+
+func (m *Memory) publish(msg *Message) string {
+	m.seq++
+	msg.ID = fmt.Sprintf("%d-0", m.seq)
+	t := m.topic(msg.Topic)
+	t.messages = append(t.messages, msg)
+	for _, g := range t.groups {
+		close(g.wake)
+		g.wake = make(chan struct{})
+	}
+	return msg.ID
+}
+
+func (m *Memory) Subscribe(topic, group string, handler Handler) error {
+	if err := validate(topic, group); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	t := m.topic(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = &memoryGroup{next: len(t.messages), pending: make(map[string]*memoryPending), wake: make(chan struct{})}
+		t.groups[group] = g
+	}
+
+	m.wg.Add(1)
+	go m.consume(topic, group, g, handler)
+	return nil
+}
+
+func (m *Memory) consume(topic, group string, g *memoryGroup, handler Handler) {
+	defer m.wg.Done()
+
+	for m.ctx.Err() == nil {
+		m.mu.Lock()
+		msg, deliveries := m.take(topic, g)
+		wake := g.wake
+		m.mu.Unlock()
+
+		if msg == nil {
+			select {
+			case <-m.ctx.Done():
+			case <-wake:
+			case <-time.After(m.config.ClaimInterval):
+			}
+			continue
+		}
+
+		delivered := *msg
+		delivered.Deliveries = deliveries
+		err := handle(m.ctx, handler, &delivered)
+
+		m.mu.Lock()
+		switch {
+		case err == nil:
+			delete(g.pending, msg.ID)
+		case m.config.MaxDeliveries > 0 && deliveries >= m.config.MaxDeliveries:
+			delete(g.pending, msg.ID)
+			m.logError("Moving message %s of %s to %s after %d deliveries: %v", msg.ID, topic, deadTopic(topic), deliveries, err)
+			m.publish(&Message{Topic: deadTopic(topic), Payload: msg.Payload, Headers: msg.Headers})
+		default:
+			m.logError("Handler for %s in %s failed on message %s: %v", topic, group, msg.ID, err)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// take returns the next message for the group: a pending one that has been
+// idle for ClaimIdle, or else a new one. It must be called with m.mu held.
+func (m *Memory) take(topic string, g *memoryGroup) (*Message, int) {
+	for _, p := range g.pending {
+		if time.Since(p.deliveredAt) >= m.config.ClaimIdle {
+			p.msg.Deliveries++
+			p.deliveredAt = time.Now()
+			return p.msg, p.msg.Deliveries
+		}
+	}
+
+	t := m.topics[topic]
+	if g.next >= len(t.messages) {
+		return nil, 0
+	}
+	msg := *t.messages[g.next]
+	g.next++
+	msg.Deliveries = 1
+	g.pending[msg.ID] = &memoryPending{msg: &msg, deliveredAt: time.Now()}
+	return &msg, 1
+}
+
+// Published returns the messages published to topic so far, for assertions
+// in tests.
+func (m *Memory) Published(topic string) []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.topics[topic]
+	if !ok {
+		return nil
+	}
+	return append([]*Message(nil), t.messages...)
+}
+
+// Pending returns how many messages of topic the group has received but not
+// acknowledged.
+func (m *Memory) Pending(topic, group string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.topics[topic]; ok {
+		if g, ok := t.groups[group]; ok {
+			return len(g.pending)
+		}
+	}
+	return 0
+}
+
+func (m *Memory) logError(format string, args ...interface{}) {
+	if m.config.Logger != nil {
+		m.config.Logger.Error(format, args...)
+	}
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,231 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keeps each topic in a stream and each group in a stream consumer
+// group. Messages stay in the group's pending list until acknowledged, and
+// consumers take over messages that stayed pending for ClaimIdle.
+type Redis struct {
+	client redis.UniversalClient
+	config Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewRedis(client redis.UniversalClient, config Config) *Redis {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Redis{client: client, config: config.withDefaults(), ctx: ctx, cancel: cancel}
+}
+
+func (r *Redis) stream(topic string) string {
+	return r.config.Prefix + topic
+}
+
+func (r *Redis) Publish(ctx context.Context, topic string, payload interface{}) (string, error) {
+	if topic == "" {
+		return "", errors.New("broker: topic is required")
+	}
+	data, err := encode(payload)
+	if err != nil {
+		return "", err
+	}
+	values := map[string]interface{}{"payload": data}
+	if headers := headersFrom(ctx); len(headers) > 0 {
+		encoded, err := json.Marshal(headers)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode headers: %w", err)
+		}
+		values["headers"] = encoded
+	}
+	return r.add(ctx, topic, values)
+}
+
+func (r *Redis) add(ctx context.Context, topic string, values map[string]interface{}) (string, error) {
+	args := &redis.XAddArgs{Stream: r.stream(topic), Values: values}
+	if r.config.MaxLen > 0 {
+		args.MaxLen = r.config.MaxLen
+		args.Approx = true
+	}
+	id, err := r.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return id, nil
+}
+
+func (r *Redis) Subscribe(topic, group string, handler Handler) error {
+	if err := validate(topic, group); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+
+	err := r.client.XGroupCreateMkStream(r.ctx, r.stream(topic), group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create group %s for %s: %w", group, topic, err)
+	}
+
+	r.wg.Add(1)
+	go r.consume(topic, group, handler)
+	return nil
+}
+
+func (r *Redis) consume(topic, group string, handler Handler) {
+	defer r.wg.Done()
+
+	var lastClaim time.Time
+	for r.ctx.Err() == nil {
+		if time.Since(lastClaim) >= r.config.ClaimInterval {
+			r.reclaim(topic, group, handler)
+			lastClaim = time.Now()
+		}
+
+		streams, err := r.client.XReadGroup(r.ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: r.config.Consumer,
+			Streams:  []string{r.stream(topic), ">"},
+			Count:    r.config.BatchSize,
+			Block:    r.config.Block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || r.ctx.Err() != nil {
+				continue
+			}
+			r.logError("Failed to read %s for %s: %v", topic, group, err)
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				r.process(topic, group, handler, msg, 1)
+			}
+		}
+	}
+}
+
+// reclaim takes over messages of the group that stayed unacknowledged for
+// ClaimIdle, including this consumer's own failed ones.
+func (r *Redis) reclaim(topic, group string, handler Handler) {
+	start := "0-0"
+	for r.ctx.Err() == nil {
+		msgs, next, err := r.client.XAutoClaim(r.ctx, &redis.XAutoClaimArgs{
+			Stream:   r.stream(topic),
+			Group:    group,
+			Consumer: r.config.Consumer,
+			MinIdle:  r.config.ClaimIdle,
+			Start:    start,
+			Count:    r.config.BatchSize,
+		}).Result()
+		if err != nil {
+			if r.ctx.Err() == nil {
+				r.logError("Failed to claim pending messages of %s for %s: %v", topic, group, err)
+			}
+			return
+		}
+		for _, msg := range msgs {
+			r.process(topic, group, handler, msg, r.deliveries(topic, group, msg.ID))
+		}
+		if next == "0-0" || len(msgs) == 0 {
+			return
+		}
+		start = next
+	}
+}
+
+func (r *Redis) deliveries(topic, group, id string) int {
+	pending, err := r.client.XPendingExt(r.ctx, &redis.XPendingExtArgs{
+		Stream: r.stream(topic),
+		Group:  group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return int(pending[0].RetryCount)
+}
+
+func (r *Redis) process(topic, group string, handler Handler, entry redis.XMessage, deliveries int) {
+	// Entries trimmed from the stream while pending come back without data.
+	if entry.Values == nil {
+		r.ack(topic, group, entry.ID)
+		return
+	}
+
+	msg := &Message{ID: entry.ID, Topic: topic, Deliveries: deliveries}
+	if payload, ok := entry.Values["payload"].(string); ok {
+		msg.Payload = []byte(payload)
+	}
+	if headers, ok := entry.Values["headers"].(string); ok {
+		_ = json.Unmarshal([]byte(headers), &msg.Headers)
+	}
+
+	err := handle(r.ctx, handler, msg)
+	if err == nil {
+		r.ack(topic, group, msg.ID)
+		return
+	}
+	if r.config.MaxDeliveries <= 0 || deliveries < r.config.MaxDeliveries {
+		r.logError("Handler for %s in %s failed on message %s: %v", topic, group, msg.ID, err)
+		return
+	}
+
+	r.logError("Moving message %s of %s to %s after %d deliveries: %v", msg.ID, topic, deadTopic(topic), deliveries, err)
+	values := map[string]interface{}{"id": msg.ID, "group": group, "error": err.Error()}
+	for k, v := range entry.Values {
+		values[k] = v
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.add(ctx, deadTopic(topic), values); err != nil {
+		r.logError("%v", err)
+		return
+	}
+	r.ack(topic, group, msg.ID)
+}
+
+func (r *Redis) ack(topic, group, id string) {
+	// Acknowledge even while closing so finished work is not redone.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.client.XAck(ctx, r.stream(topic), group, id).Err(); err != nil {
+		r.logError("Failed to acknowledge message %s of %s: %v", id, topic, err)
+	}
+}
+
+func (r *Redis) logError(format string, args ...interface{}) {
+	if r.config.Logger != nil {
+		r.config.Logger.Error(format, args...)
+	}
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
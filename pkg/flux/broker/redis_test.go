@@ -0,0 +1,133 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisClient(t *testing.T) redis.UniversalClient {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// newTestRedis returns a broker that polls quickly, standing in for one
+// process named consumer.
+func newTestRedis(t *testing.T, client redis.UniversalClient, consumer string, config Config) *Redis {
+	config.Consumer = consumer
+	config.Block = 20 * time.Millisecond
+	if config.ClaimInterval == 0 {
+		config.ClaimInterval = 10 * time.Millisecond
+	}
+	b := NewRedis(client, config)
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestRedisDeliversOncePerGroup(t *testing.T) {
+	client := newRedisClient(t)
+	first := newTestRedis(t, client, "first", Config{})
+	second := newTestRedis(t, client, "second", Config{})
+
+	_, err := first.Publish(context.Background(), "orders.placed", orderPlaced{ID: 0})
+	require.NoError(t, err)
+
+	billing, shippingA, shippingB := &collector{}, &collector{}, &collector{}
+	require.NoError(t, first.Subscribe("orders.placed", "billing", billing.handle))
+	require.NoError(t, first.Subscribe("orders.placed", "shipping", shippingA.handle))
+	require.NoError(t, second.Subscribe("orders.placed", "shipping", shippingB.handle))
+
+	ctx := WithHeaders(context.Background(), map[string]string{"trace_id": "abc"})
+	for i := 1; i <= 10; i++ {
+		_, err := second.Publish(ctx, "orders.placed", orderPlaced{ID: i, Total: 9.5})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return billing.count() == 10 && shippingA.count()+shippingB.count() == 10
+	}, 2*time.Second, 10*time.Millisecond)
+	// Messages published before a group existed are not delivered to it.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 10, billing.count())
+	assert.Equal(t, 10, shippingA.count()+shippingB.count())
+
+	var order orderPlaced
+	msg := billing.messages[0]
+	require.NoError(t, msg.Decode(&order))
+	assert.Equal(t, orderPlaced{ID: 1, Total: 9.5}, order)
+	assert.Equal(t, "abc", msg.Headers["trace_id"])
+	assert.Equal(t, 1, msg.Deliveries)
+
+	pending, err := client.XPending(context.Background(), "flux:broker:orders.placed", "billing").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, pending.Count)
+}
+
+func TestRedisRedeliversUntilAcknowledged(t *testing.T) {
+	client := newRedisClient(t)
+	b := newTestRedis(t, client, "mailer-1", Config{ClaimIdle: 20 * time.Millisecond, MaxDeliveries: 5})
+
+	var mu sync.Mutex
+	var deliveries []int
+	require.NoError(t, b.Subscribe("mail", "mailer", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, msg.Deliveries)
+		if msg.Deliveries < 3 {
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	}))
+
+	_, err := b.Publish(context.Background(), "mail", []byte(`{"to":"a@example.com"}`))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(deliveries) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{1, 2, 3}, deliveries)
+
+	require.Eventually(t, func() bool {
+		pending, err := client.XPending(context.Background(), "flux:broker:mail", "mailer").Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRedisMovesFailingMessagesToDeadTopic(t *testing.T) {
+	client := newRedisClient(t)
+	b := newTestRedis(t, client, "mailer-1", Config{ClaimIdle: 10 * time.Millisecond, MaxDeliveries: 2})
+
+	dead := &collector{}
+	require.NoError(t, b.Subscribe("mail:dead", "ops", dead.handle))
+	require.NoError(t, b.Subscribe("mail", "mailer", func(ctx context.Context, msg *Message) error {
+		panic("boom")
+	}))
+
+	id, err := b.Publish(context.Background(), "mail", map[string]string{"to": "a@example.com"})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return dead.count() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.JSONEq(t, `{"to":"a@example.com"}`, string(dead.messages[0].Payload))
+
+	entries, err := client.XRange(context.Background(), "flux:broker:mail:dead", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, id, entries[0].Values["id"])
+	assert.Equal(t, "mailer", entries[0].Values["group"])
+	assert.Contains(t, entries[0].Values["error"], "boom")
+
+	pending, err := client.XPending(context.Background(), "flux:broker:mail", "mailer").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, pending.Count)
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	stateClosed = iota
+	stateHalfOpen
+	stateOpen
+)
+
+// breaker opens after FailureThreshold consecutive failures, rejects calls
+// for OpenTimeout, then lets a single probe through: success closes it,
+// failure opens it again.
+type breaker struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	state    int
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (c *Client) breaker(target string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[target]
+	if !ok {
+		b = &breaker{config: c.config.Breaker}
+		c.breakers[target] = b
+	}
+	return b
+}
+
+func (b *breaker) allow() bool {
+	if b.config.FailureThreshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return true
+	case stateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *breaker) record(success bool) {
+	if b.config.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.state = stateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) currentState() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+type clientMetrics struct {
+	requests *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	state    *prometheus.GaugeVec
+}
+
+func newClientMetrics(registerer prometheus.Registerer) (*clientMetrics, error) {
+	m := &clientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_requests_total",
+			Help: "Outgoing HTTP requests by target, method and status (0 for network errors)",
+		}, []string{"target", "method", "status"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_retries_total",
+			Help: "Retried outgoing HTTP requests by target",
+		}, []string{"target"}),
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_client_circuit_state",
+			Help: "Circuit breaker state by target: 0 closed, 1 half-open, 2 open",
+		}, []string{"target"}),
+	}
+
+	var err error
+	m.requests, err = register(registerer, m.requests)
+	if err != nil {
+		return nil, err
+	}
+	m.retries, err = register(registerer, m.retries)
+	if err != nil {
+		return nil, err
+	}
+	m.state, err = register(registerer, m.state)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register reuses a collector already registered by another client.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+func (m *clientMetrics) request(target, method string, status int) {
+	if m != nil {
+		m.requests.WithLabelValues(target, method, strconv.Itoa(status)).Inc()
+	}
+}
+
+func (m *clientMetrics) retry(target string) {
+	if m != nil {
+		m.retries.WithLabelValues(target).Inc()
+	}
+}
+
+func (m *clientMetrics) circuit(target string, b *breaker) {
+	if m != nil {
+		m.state.WithLabelValues(target).Set(float64(b.currentState()))
+	}
+}
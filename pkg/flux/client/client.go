@@ -0,0 +1,447 @@
+// Package client is an HTTP client for calls between flux services. It
+// propagates trace and request IDs, retries idempotent requests with
+// jittered backoff, trips a circuit breaker per target and turns error
+// responses back into *flux.AppError.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrCircuitOpen = flux.DefineError("CIRCUIT_OPEN", http.StatusServiceUnavailable, "upstream service is unavailable")
+
+type RetryConfig struct {
+	// MaxAttempts includes the first try; 1 disables retries.
+	MaxAttempts    int           `yaml:"max_attempts" json:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	// RetryOn lists response statuses worth retrying. Network errors are
+	// always retried.
+	RetryOn []int `yaml:"retry_on" json:"retry_on"`
+}
+
+type BreakerConfig struct {
+	// FailureThreshold consecutive failures open the circuit; 0 disables it.
+	FailureThreshold int           `yaml:"failure_threshold" json:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" json:"open_timeout"`
+}
+
+// Resolver maps a service name used as URL host, as in http://orders/items,
+// to the base URLs of its healthy instances. Only dot-less hosts without a
+// port are resolved; api.stripe.com, IPs and localhost are called directly.
+type Resolver interface {
+	Resolve(ctx context.Context, service string) ([]string, error)
+}
+
+type Config struct {
+	// BaseURL is prepended to relative request URLs.
+	BaseURL string
+	Timeout time.Duration
+	// Timeouts overrides Timeout per target host, e.g. {"reports": 30 * time.Second}.
+	Timeouts map[string]time.Duration
+	Retry    RetryConfig
+	Breaker  BreakerConfig
+	Headers  map[string]string
+	Resolver Resolver
+	// Metrics registers request, retry and circuit state collectors when set.
+	Metrics    prometheus.Registerer
+	HTTPClient *http.Client
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout: 10 * time.Second,
+		Retry: RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     2 * time.Second,
+			RetryOn:        []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		},
+		Breaker: BreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+		},
+	}
+}
+
+type Client struct {
+	config   Config
+	http     *http.Client
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	next     uint64
+	metrics  *clientMetrics
+}
+
+func New(config Config) (*Client, error) {
+	defaults := DefaultConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry.MaxAttempts = 1
+	}
+	if config.Retry.InitialBackoff <= 0 {
+		config.Retry.InitialBackoff = defaults.Retry.InitialBackoff
+	}
+	if config.Retry.MaxBackoff <= 0 {
+		config.Retry.MaxBackoff = defaults.Retry.MaxBackoff
+	}
+	if config.Breaker.OpenTimeout <= 0 {
+		config.Breaker.OpenTimeout = defaults.Breaker.OpenTimeout
+	}
+
+	c := &Client{config: config, http: config.HTTPClient, breakers: make(map[string]*breaker)}
+	if c.http == nil {
+		c.http = &http.Client{}
+	}
+	if config.Metrics != nil {
+		m, err := newClientMetrics(config.Metrics)
+		if err != nil {
+			return nil, err
+		}
+		c.metrics = m
+	}
+	return c, nil
+}
+
+type contextKey int
+
+const (
+	traceIDKey contextKey = iota
+	requestIDKey
+	headersKey
+)
+
+// Context returns the request's context carrying its trace and request
+// IDs, which the client sends on as X-Trace-ID and X-Request-ID.
+func Context(c *flux.Context) context.Context {
+	ctx := c.UserContext()
+	if traceID, ok := c.Locals("trace_id").(string); ok && traceID != "" {
+		ctx = WithTraceID(ctx, traceID)
+	}
+	if requestID, ok := c.Locals("requestid").(string); ok && requestID != "" {
+		ctx = WithRequestID(ctx, requestID)
+	} else if requestID := c.Get("X-Request-ID"); requestID != "" {
+		ctx = WithRequestID(ctx, requestID)
+	}
+	return ctx
+}
+
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey, traceID)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// WithHeader adds a header to every request sent with ctx, such as an
+// Idempotency-Key or a header parameter of a generated client.
+func WithHeader(ctx context.Context, name, value string) context.Context {
+	headers := http.Header{}
+	if existing, ok := ctx.Value(headersKey).(http.Header); ok {
+		headers = existing.Clone()
+	}
+	headers.Set(name, value)
+	return context.WithValue(ctx, headersKey, headers)
+}
+
+func (c *Client) Get(ctx context.Context, url string, out interface{}) error {
+	return c.Do(ctx, http.MethodGet, url, nil, out)
+}
+
+func (c *Client) Post(ctx context.Context, url string, body, out interface{}) error {
+	return c.Do(ctx, http.MethodPost, url, body, out)
+}
+
+func (c *Client) Put(ctx context.Context, url string, body, out interface{}) error {
+	return c.Do(ctx, http.MethodPut, url, body, out)
+}
+
+func (c *Client) Patch(ctx context.Context, url string, body, out interface{}) error {
+	return c.Do(ctx, http.MethodPatch, url, body, out)
+}
+
+func (c *Client) Delete(ctx context.Context, url string, out interface{}) error {
+	return c.Do(ctx, http.MethodDelete, url, nil, out)
+}
+
+// Do sends body as JSON and decodes a successful JSON response into out,
+// which may be nil. Error responses are returned as *flux.AppError.
+func (c *Client) Do(ctx context.Context, method, url string, body, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.resolveBase(url), payload)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.DoRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return DecodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) resolveBase(rawURL string) string {
+	if c.config.BaseURL != "" && !strings.Contains(rawURL, "://") {
+		return strings.TrimSuffix(c.config.BaseURL, "/") + "/" + strings.TrimPrefix(rawURL, "/")
+	}
+	return rawURL
+}
+
+// DoRequest sends req with propagation headers, per-target timeout, retries
+// and the circuit breaker. The caller closes the response body. Requests
+// with a body are only retried when req.GetBody is set.
+func (c *Client) DoRequest(req *http.Request) (*http.Response, error) {
+	target := req.URL.Host
+	b := c.breaker(target)
+
+	ctx := req.Context()
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if headers, ok := ctx.Value(headersKey).(http.Header); ok {
+		for name, values := range headers {
+			req.Header[name] = values
+		}
+	}
+	retryable := isIdempotent(req) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	if traceID, ok := ctx.Value(traceIDKey).(string); ok {
+		req.Header.Set("X-Trace-ID", traceID)
+	}
+	if requestID, ok := ctx.Value(requestIDKey).(string); ok {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+
+	timeout := c.config.Timeout
+	if t, ok := c.config.Timeouts[target]; ok {
+		timeout = t
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if !b.allow() {
+			c.metrics.circuit(target, b)
+			if lastErr != nil {
+				return nil, ErrCircuitOpen.WithError(lastErr)
+			}
+			return nil, ErrCircuitOpen.WithDetail("target", target)
+		}
+
+		resp, err := c.attempt(req, target, timeout)
+		failed := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		b.record(!failed)
+		c.metrics.circuit(target, b)
+
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		c.metrics.request(target, req.Method, statusCode)
+
+		shouldRetry := retryable && attempt < c.config.Retry.MaxAttempts && ctx.Err() == nil &&
+			(err != nil || containsInt(c.config.Retry.RetryOn, statusCode))
+		if !shouldRetry {
+			if err != nil {
+				return nil, fmt.Errorf("failed to call %s: %w", target, err)
+			}
+			return resp, nil
+		}
+
+		wait := c.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s responded %d", target, statusCode)
+		} else {
+			lastErr = err
+		}
+		c.metrics.retry(target)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to call %s: %w", target, ctx.Err())
+		case <-time.After(wait):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// attempt sends one try, resolving a service name host to an instance.
+func (c *Client) attempt(req *http.Request, target string, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	out := req.Clone(ctx)
+	out.Body = req.Body
+
+	if c.config.Resolver != nil && isServiceName(out.URL) {
+		instances, err := c.config.Resolver.Resolve(ctx, target)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to resolve %s: %w", target, err)
+		}
+		if len(instances) > 0 {
+			base, err := url.Parse(instances[atomic.AddUint64(&c.next, 1)%uint64(len(instances))])
+			if err != nil {
+				cancel()
+				return nil, fmt.Errorf("failed to parse instance URL: %w", err)
+			}
+			out.URL.Scheme = base.Scheme
+			out.URL.Host = base.Host
+			out.Host = base.Host
+		}
+	}
+
+	resp, err := c.http.Do(out)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// isServiceName reports whether u's host names a registered service rather
+// than a real host.
+func isServiceName(u *url.URL) bool {
+	host := u.Hostname()
+	return u.Port() == "" && host != "" && host != "localhost" && !strings.ContainsAny(host, ".:")
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// backoff is full jitter over an exponential ceiling, or Retry-After when
+// the server sent one, capped at MaxBackoff.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	max := c.config.Retry.MaxBackoff
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if wait := time.Duration(seconds) * time.Second; wait < max {
+				return wait
+			}
+			return max
+		}
+	}
+
+	ceiling := c.config.Retry.InitialBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > max {
+		ceiling = max
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// isIdempotent reports whether req may be sent twice. POST and PATCH
+// qualify when they carry an Idempotency-Key.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeError turns an error response from a flux service, in either the
+// JSON or the problem details format, back into an *flux.AppError.
+func DecodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	appErr := flux.NewAppError(http.StatusText(resp.StatusCode), resp.StatusCode)
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		if text := strings.TrimSpace(string(data)); text != "" {
+			appErr.Message = text
+		}
+		return appErr
+	}
+
+	if code, ok := body["code"].(string); ok {
+		appErr.Code = code
+	}
+	for _, key := range []string{"detail", "message", "title"} {
+		if msg, ok := body[key].(string); ok && msg != "" {
+			appErr.Message = msg
+			break
+		}
+	}
+
+	if details, ok := body["details"].(map[string]interface{}); ok {
+		appErr.Details = details
+	} else if _, isProblem := body["status"]; isProblem {
+		for k, v := range body {
+			switch k {
+			case "type", "title", "status", "detail", "instance", "code", "trace_id":
+			default:
+				appErr.Details[k] = v
+			}
+		}
+	}
+	return appErr
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetries() Config {
+	config := DefaultConfig()
+	config.Retry.InitialBackoff = time.Millisecond
+	config.Retry.MaxBackoff = 5 * time.Millisecond
+	return config
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer upstream.Close()
+
+	registry := prometheus.NewRegistry()
+	config := fastRetries()
+	config.BaseURL = upstream.URL
+	config.Metrics = registry
+	c, err := New(config)
+	require.NoError(t, err)
+
+	var out struct{ ID int }
+	require.NoError(t, c.Get(context.Background(), "/orders/7", &out))
+	assert.Equal(t, 7, out.ID)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+	assert.Equal(t, 2.0, testutil.ToFloat64(c.metrics.retries))
+
+	// POST without an Idempotency-Key is sent once.
+	atomic.StoreInt32(&calls, 0)
+	err = c.Post(context.Background(), "/orders", map[string]int{"qty": 1}, nil)
+	var appErr *flux.AppError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, http.StatusServiceUnavailable, appErr.StatusCode)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// A key set through the context makes it retryable.
+	atomic.StoreInt32(&calls, 0)
+	ctx := WithHeader(context.Background(), "Idempotency-Key", "order-1")
+	require.NoError(t, c.Post(ctx, "/orders", map[string]int{"qty": 1}, nil))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	healthy := int32(0)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer upstream.Close()
+
+	config := fastRetries()
+	config.BaseURL = upstream.URL
+	config.Retry.MaxAttempts = 1
+	config.Breaker = BreakerConfig{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond}
+	c, err := New(config)
+	require.NoError(t, err)
+
+	c.Get(context.Background(), "/", nil)
+	c.Get(context.Background(), "/", nil)
+	err = c.Get(context.Background(), "/", nil)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&healthy, 1)
+	assert.NoError(t, c.Get(context.Background(), "/", nil))
+	assert.NoError(t, c.Get(context.Background(), "/", nil))
+}
+
+func TestOpenCircuitUnderConcurrentLoad(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	config := fastRetries()
+	config.BaseURL = upstream.URL
+	config.Retry.MaxAttempts = 1
+	config.Breaker = BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}
+	c, err := New(config)
+	require.NoError(t, err)
+	c.Get(context.Background(), "/", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.Get(context.Background(), "/", nil)
+			assert.True(t, errors.Is(err, ErrCircuitOpen))
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, ErrCircuitOpen.Details)
+}
+
+func TestDecodesFluxErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/problem" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"Validation failed","code":"VALIDATION_FAILED","errors":[{"field":"qty"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":true,"message":"order not found","code":"NOT_FOUND"}`))
+	}))
+	defer upstream.Close()
+
+	c, err := New(Config{BaseURL: upstream.URL})
+	require.NoError(t, err)
+
+	err = c.Get(context.Background(), "/orders/1", nil)
+	assert.True(t, errors.Is(err, flux.ErrNotFound))
+	assert.Equal(t, "order not found", err.Error())
+
+	err = c.Get(context.Background(), "/problem", nil)
+	var appErr *flux.AppError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, "VALIDATION_FAILED", appErr.Code)
+	assert.Equal(t, 422, appErr.StatusCode)
+	assert.Contains(t, appErr.Details, "errors")
+}
+
+type staticResolver map[string][]string
+
+func (r staticResolver) Resolve(_ context.Context, service string) ([]string, error) {
+	if _, ok := r[service]; !ok {
+		return nil, errors.New("no instances for " + service)
+	}
+	return r[service], nil
+}
+
+func TestPropagatesIDsAndResolvesServices(t *testing.T) {
+	var traceID, requestID atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID.Store(r.Header.Get("X-Trace-ID"))
+		requestID.Store(r.Header.Get("X-Request-ID"))
+		w.Write([]byte(`"ok"`))
+	}))
+	defer upstream.Close()
+
+	c, err := New(Config{Resolver: staticResolver{"orders": {upstream.URL}}})
+	require.NoError(t, err)
+
+	// Real hosts bypass the resolver.
+	var direct string
+	require.NoError(t, c.Get(context.Background(), upstream.URL+"/direct", &direct))
+	assert.Equal(t, "ok", direct)
+	assert.True(t, isServiceName(&url.URL{Host: "orders"}))
+	assert.False(t, isServiceName(&url.URL{Host: "api.stripe.com"}))
+	assert.False(t, isServiceName(&url.URL{Host: "localhost"}))
+
+	app, err := flux.New(&flux.Config{Name: "gateway", Version: "1.0.0"})
+	require.NoError(t, err)
+	app.AddTracing()
+	app.Get().Get("/checkout", func(fc *fiber.Ctx) error {
+		ctx := flux.NewContext(fc, app)
+		var out string
+		if err := c.Get(Context(ctx), "http://orders/orders", &out); err != nil {
+			return err
+		}
+		return ctx.Text(out)
+	})
+
+	req := httptest.NewRequest("GET", "/checkout", nil)
+	req.Header.Set("X-Trace-ID", "trace-123")
+	req.Header.Set("X-Request-ID", "req-456")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "trace-123", traceID.Load())
+	assert.Equal(t, "req-456", requestID.Load())
+}
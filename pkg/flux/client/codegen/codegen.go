@@ -0,0 +1,242 @@
+// Package codegen generates typed Go clients from OpenAPI 3 specs. The
+// generated clients call services through pkg/flux/client, so they get its
+// retries, circuit breaking and trace propagation.
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Header marks generated files. Files without it are never overwritten.
+const Header = "// Code generated by flux make:client. DO NOT EDIT."
+
+const (
+	GeneratedFile = "client_gen.go"
+	UserFile      = "client.go"
+)
+
+type Options struct {
+	// Package is the Go package name of the client, e.g. ordersclient.
+	Package string
+	// Service is the host of the default base URL written to the user file.
+	// It defaults to Package without a "client" suffix.
+	Service string
+}
+
+func (o Options) service() string {
+	if o.Service != "" {
+		return o.Service
+	}
+	if s := strings.TrimSuffix(o.Package, "client"); s != "" {
+		return s
+	}
+	return o.Package
+}
+
+// Write generates client_gen.go in dir and creates client.go for user code
+// if it does not exist yet. It returns the files it wrote.
+func Write(dir string, spec []byte, opts Options) ([]string, error) {
+	generated, err := Generate(spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	genPath := filepath.Join(dir, GeneratedFile)
+	if existing, err := os.ReadFile(genPath); err == nil && !IsGenerated(existing) {
+		return nil, fmt.Errorf("%s was not generated by flux; refusing to overwrite it", genPath)
+	}
+	if err := os.WriteFile(genPath, generated, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", genPath, err)
+	}
+	written := []string{genPath}
+
+	userPath := filepath.Join(dir, UserFile)
+	if _, err := os.Stat(userPath); os.IsNotExist(err) {
+		src, err := Scaffold(opts)
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(userPath, src, 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", userPath, err)
+		}
+		written = append(written, userPath)
+	}
+	return written, nil
+}
+
+func IsGenerated(src []byte) bool {
+	return bytes.HasPrefix(src, []byte(Header))
+}
+
+// Scaffold returns the user-owned file with the client constructor.
+func Scaffold(opts Options) ([]byte, error) {
+	src := fmt.Sprintf(`package %s
+
+import "github.com/Fluxgo/flux/pkg/flux/client"
+
+// This file was created by flux make:client and is yours to edit. It is
+// never overwritten; %s is regenerated from the spec.
+
+// New creates a client for the %s service. Set config.Resolver to find the
+// service through a registry.
+func New(config client.Config) (*Client, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = %q
+	}
+	http, err := client.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(http), nil
+}
+`, opts.Package, GeneratedFile, opts.service(), "http://"+opts.service())
+	return format.Source([]byte(src))
+}
+
+// Generate returns the source of client_gen.go for a JSON or YAML spec.
+func Generate(spec []byte, opts Options) ([]byte, error) {
+	if !isIdentifier(opts.Package) {
+		return nil, fmt.Errorf("invalid package name %q", opts.Package)
+	}
+
+	doc, err := parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	g := newGenerator(doc)
+	if err := g.run(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(Header + "\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", opts.Package)
+	out.WriteString("import (\n")
+	for _, path := range g.importList() {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString("\n\t\"github.com/Fluxgo/flux/pkg/flux/client\"\n")
+	out.WriteString(")\n\n")
+
+	title := doc.Info.Title
+	if title == "" {
+		title = opts.service()
+	}
+	fmt.Fprintf(&out, "// Client calls the %s API.\ntype Client struct {\n\tHTTP *client.Client\n}\n\n", title)
+	out.WriteString("// NewClient wraps a flux HTTP client.\nfunc NewClient(http *client.Client) *Client {\n\treturn &Client{HTTP: http}\n}\n\n")
+	out.Write(g.methods.Bytes())
+	for _, name := range g.typeOrder {
+		out.WriteString(g.types[name])
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated client: %w", err)
+	}
+	return src, nil
+}
+
+func parse(spec []byte) (*document, error) {
+	trimmed := bytes.TrimSpace(spec)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		var raw interface{}
+		if err := yaml.Unmarshal(spec, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse spec: %w", err)
+		}
+		converted, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse spec: %w", err)
+		}
+		trimmed = converted
+	}
+
+	var doc document
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("spec has no paths")
+	}
+	return &doc, nil
+}
+
+type document struct {
+	Info struct {
+		Title string `json:"title"`
+	} `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []*parameter        `json:"parameters"`
+	RequestBody *content            `json:"requestBody"`
+	Responses   map[string]*content `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type content struct {
+	Content map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+func (c *content) jsonSchema() *schema {
+	if c == nil {
+		return nil
+	}
+	for mediaType, media := range c.Content {
+		if strings.Contains(mediaType, "json") {
+			return media.Schema
+		}
+	}
+	return nil
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Title                string             `json:"title"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AllOf                []*schema          `json:"allOf"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+func (d *document) sortedPaths() []string {
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
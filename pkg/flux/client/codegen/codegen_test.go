@@ -0,0 +1,98 @@
+package codegen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	spec, err := os.ReadFile("testdata/orders.json")
+	require.NoError(t, err)
+
+	src, err := Generate(spec, Options{Package: "ordersclient"})
+	require.NoError(t, err)
+	typeCheck(t, src)
+
+	out := string(src)
+	for _, want := range []string{
+		"func (c *Client) ListOrders(ctx context.Context, params ListOrdersParams) (*ListOrdersResponse, error)",
+		"func (c *Client) CreateOrder(ctx context.Context, body CreateOrderRequest) (*Order, error)",
+		"func (c *Client) GetOrdersByID(ctx context.Context, id int64) (*Order, error)",
+		"func (c *Client) DeleteOrder(ctx context.Context, id int64) error",
+		"func (c *Client) GetOrdersTagsByID(ctx context.Context, id string) ([]string, error)",
+		`path := "/orders/" + url.PathEscape(id) + "/tags"`,
+		`ctx = client.WithHeader(ctx, "X-Tenant-ID", params.XTenantID)`,
+		`query.Add("since", (*params.Since).Format(time.RFC3339))`,
+		"func (c *Client) GetOrderNote(ctx context.Context, id int64, note int) (string, error)",
+		"type Status string",
+		"ShippingAddress *Address",
+		"CreatedAt       time.Time",
+		"Data []Order",
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+// typeCheck compiles the generated file against the real client package,
+// catching code that parses but does not build.
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, GeneratedFile, src, 0)
+	require.NoError(t, err)
+
+	// Export data comes from the go command, so module packages resolve.
+	lookup := func(path string) (io.ReadCloser, error) {
+		out, err := exec.Command("go", "list", "-export", "-f", "{{.Export}}", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("go list %s: %w", path, err)
+		}
+		return os.Open(strings.TrimSpace(string(out)))
+	}
+	config := types.Config{Importer: importer.ForCompiler(fset, "gc", lookup)}
+	_, err = config.Check("ordersclient", fset, []*ast.File{file}, nil)
+	require.NoError(t, err, string(src))
+}
+
+func TestWriteKeepsUserCode(t *testing.T) {
+	spec, err := os.ReadFile("testdata/orders.json")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	opts := Options{Package: "ordersclient"}
+
+	written, err := Write(dir, spec, opts)
+	require.NoError(t, err)
+	assert.Len(t, written, 2)
+
+	user := filepath.Join(dir, UserFile)
+	userCode, err := os.ReadFile(user)
+	require.NoError(t, err)
+	assert.Contains(t, string(userCode), `config.BaseURL = "http://orders"`)
+	require.NoError(t, os.WriteFile(user, []byte("package ordersclient\n// edited\n"), 0644))
+
+	written, err = Write(dir, spec, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, GeneratedFile)}, written)
+	userCode, _ = os.ReadFile(user)
+	assert.Contains(t, string(userCode), "// edited")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, GeneratedFile), []byte("package ordersclient\n"), 0644))
+	_, err = Write(dir, spec, opts)
+	assert.Error(t, err)
+
+	_, err = Generate(spec, Options{Package: "client"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,641 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type generator struct {
+	doc       *document
+	methods   bytes.Buffer
+	types     map[string]string
+	typeOrder []string
+	structs   map[string]bool
+	named     map[*schema]string
+	shapes    map[string]string
+	names     map[string]bool
+	opNames   map[string]bool
+	imports   map[string]bool
+}
+
+func newGenerator(doc *document) *generator {
+	return &generator{
+		doc:     doc,
+		types:   make(map[string]string),
+		structs: make(map[string]bool),
+		named:   make(map[*schema]string),
+		shapes:  make(map[string]string),
+		names:   map[string]bool{"Client": true},
+		opNames: make(map[string]bool),
+		imports: make(map[string]bool),
+	}
+}
+
+func (g *generator) importList() []string {
+	list := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (g *generator) run() error {
+	components := make([]string, 0, len(g.doc.Components.Schemas))
+	for name := range g.doc.Components.Schemas {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+
+	// Reserve component names first so inline types cannot take them.
+	for _, name := range components {
+		g.names[goName(name)] = true
+	}
+	for _, name := range components {
+		g.component(name)
+	}
+
+	for _, path := range g.doc.sortedPaths() {
+		item := g.doc.Paths[path]
+
+		var shared []*parameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return fmt.Errorf("failed to parse parameters of %s: %w", path, err)
+			}
+		}
+
+		for _, method := range methodOrder {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return fmt.Errorf("failed to parse %s %s: %w", strings.ToUpper(method), path, err)
+			}
+			op.Parameters = mergeParameters(shared, op.Parameters)
+			g.operation(strings.ToUpper(method), path, &op)
+		}
+	}
+	return nil
+}
+
+func mergeParameters(shared, own []*parameter) []*parameter {
+	out := append([]*parameter(nil), own...)
+	for _, p := range shared {
+		overridden := false
+		for _, o := range own {
+			if o.Name == p.Name && o.In == p.In {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (g *generator) component(name string) {
+	s := g.doc.Components.Schemas[name]
+	typeName := goName(name)
+	if _, done := g.types[typeName]; done || s == nil {
+		return
+	}
+	if isStructSchema(s) {
+		g.named[s] = typeName
+		g.declareStruct(typeName, s)
+		return
+	}
+
+	// Placeholder against self-referencing aliases.
+	g.addType(typeName, "")
+	underlying := g.typeOf(s, typeName+"Value")
+	g.addType(typeName, fmt.Sprintf("%stype %s %s\n\n", comment(typeName, s.Description), typeName, underlying))
+}
+
+func (g *generator) addType(name, src string) {
+	if _, exists := g.types[name]; !exists {
+		g.typeOrder = append(g.typeOrder, name)
+	}
+	g.types[name] = src
+}
+
+func (g *generator) unique(name string) string {
+	if name == "" {
+		name = "Object"
+	}
+	candidate := name
+	for i := 2; g.names[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	g.names[candidate] = true
+	return candidate
+}
+
+func isStructSchema(s *schema) bool {
+	return len(s.Properties) > 0 || len(s.AllOf) > 0
+}
+
+func (g *generator) resolve(s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		s = g.doc.Components.Schemas[refName(s.Ref)]
+	}
+	return s
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// typeOf returns the Go type for s, declaring a struct named after hint
+// when s is an inline object.
+func (g *generator) typeOf(s *schema, hint string) string {
+	if s == nil {
+		return "interface{}"
+	}
+	if s.Ref != "" {
+		name := goName(refName(s.Ref))
+		g.component(refName(s.Ref))
+		return name
+	}
+	if isStructSchema(s) {
+		if name, ok := g.named[s]; ok {
+			return name
+		}
+		// Specs that inline the same model in every operation share one type.
+		shape, _ := json.Marshal(s)
+		if name, ok := g.shapes[string(shape)]; ok {
+			g.named[s] = name
+			return name
+		}
+		if s.Title != "" {
+			hint = goName(s.Title)
+		}
+		name := g.unique(hint)
+		g.named[s] = name
+		g.shapes[string(shape)] = name
+		g.declareStruct(name, s)
+		return name
+	}
+
+	var t string
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			t = "time.Time"
+		case "byte":
+			t = "[]byte"
+		default:
+			t = "string"
+		}
+	case "integer":
+		switch s.Format {
+		case "int32":
+			t = "int32"
+		case "int64":
+			t = "int64"
+		default:
+			t = "int"
+		}
+	case "number":
+		if s.Format == "float" {
+			t = "float32"
+		} else {
+			t = "float64"
+		}
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + g.typeOf(s.Items, hint+"Item")
+	case "object":
+		var additional schema
+		if len(s.AdditionalProperties) > 0 && json.Unmarshal(s.AdditionalProperties, &additional) == nil {
+			return "map[string]" + g.typeOf(&additional, hint+"Value")
+		}
+		return "map[string]interface{}"
+	default:
+		return "interface{}"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *generator) declareStruct(name string, s *schema) {
+	properties := make(map[string]*schema)
+	required := make(map[string]bool)
+	collect := func(part *schema) {
+		for prop, ps := range part.Properties {
+			properties[prop] = ps
+		}
+		for _, r := range part.Required {
+			required[r] = true
+		}
+	}
+	for _, part := range s.AllOf {
+		if resolved := g.resolve(part); resolved != nil {
+			collect(resolved)
+		}
+	}
+	collect(s)
+
+	// Reserve the name before fields so recursive types resolve to it.
+	g.structs[name] = true
+	g.addType(name, "")
+
+	props := make([]string, 0, len(properties))
+	for prop := range properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+
+	var b strings.Builder
+	b.WriteString(comment(name, s.Description))
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	fields := make(map[string]bool)
+	for _, prop := range props {
+		field := goName(prop)
+		for i := 2; fields[field]; i++ {
+			field = fmt.Sprintf("%s%d", goName(prop), i)
+		}
+		fields[field] = true
+
+		t := g.typeOf(properties[prop], name+goName(prop))
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+			if g.structs[t] || t == "time.Time" {
+				t = "*" + t
+			}
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", field, t, tag)
+	}
+	b.WriteString("}\n\n")
+	g.types[name] = b.String()
+}
+
+func comment(name, description string) string {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return ""
+	}
+	lines := strings.Split(description, "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		if i == 0 {
+			fmt.Fprintf(&b, "// %s: %s\n", name, line)
+		} else {
+			fmt.Fprintf(&b, "// %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+type opParam struct {
+	*parameter
+	ident string
+	goT   string
+}
+
+func (g *generator) operation(method, path string, op *operation) {
+	name := goName(op.OperationID)
+	if name == "" {
+		name = operationName(method, path)
+	}
+	base := name
+	for i := 2; g.opNames[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	g.opNames[name] = true
+
+	used := map[string]bool{"ctx": true, "params": true, "body": true, "out": true, "path": true, "query": true, "err": true}
+	var pathParams, optionParams []opParam
+	byName := make(map[string]*parameter)
+	for _, p := range op.Parameters {
+		if p.In == "path" {
+			byName[p.Name] = p
+		}
+	}
+
+	// Path parameters become arguments in the order they appear.
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		p := byName[m[1]]
+		if p == nil {
+			p = &parameter{Name: m[1], In: "path", Required: true, Schema: &schema{Type: "string"}}
+		}
+		ident := lowerName(p.Name)
+		for i := 2; used[ident] || isReserved(ident); i++ {
+			ident = fmt.Sprintf("%s%d", lowerName(p.Name), i)
+		}
+		used[ident] = true
+		// A path segment cannot be null, so nullable path parameters are
+		// taken by value rather than as a pointer.
+		goT := strings.TrimPrefix(g.typeOf(p.Schema, name+goName(p.Name)), "*")
+		pathParams = append(pathParams, opParam{parameter: p, ident: ident, goT: goT})
+	}
+
+	fields := make(map[string]bool)
+	for _, p := range op.Parameters {
+		if p.In != "query" && p.In != "header" {
+			continue
+		}
+		field := goName(p.Name)
+		for i := 2; fields[field]; i++ {
+			field = fmt.Sprintf("%s%d", goName(p.Name), i)
+		}
+		fields[field] = true
+		optionParams = append(optionParams, opParam{parameter: p, ident: field, goT: g.typeOf(p.Schema, name+field)})
+	}
+
+	args := []string{"ctx context.Context"}
+	g.imports["context"] = true
+	for _, p := range pathParams {
+		args = append(args, p.ident+" "+p.goT)
+	}
+
+	bodyType := ""
+	if s := op.RequestBody.jsonSchema(); s != nil {
+		bodyType = g.typeOf(s, name+"Request")
+		args = append(args, "body "+bodyType)
+	}
+
+	paramsType := ""
+	if len(optionParams) > 0 {
+		paramsType = g.unique(name + "Params")
+		var b strings.Builder
+		fmt.Fprintf(&b, "// %s holds the query and header parameters of %s.\ntype %s struct {\n", paramsType, name, paramsType)
+		for _, p := range optionParams {
+			fmt.Fprintf(&b, "\t%s %s\n", p.ident, p.goT)
+		}
+		b.WriteString("}\n\n")
+		g.addType(paramsType, b.String())
+		args = append(args, "params "+paramsType)
+	}
+
+	resultType := ""
+	if s := successSchema(op.Responses); s != nil {
+		resultType = g.typeOf(s, name+"Response")
+	}
+	isStruct := g.structs[resultType]
+
+	w := &g.methods
+	fmt.Fprintf(w, "// %s sends %s %s.", name, method, path)
+	if summary := strings.TrimSpace(op.Summary); summary != "" {
+		fmt.Fprintf(w, "\n// %s", strings.ReplaceAll(summary, "\n", " "))
+	}
+	fmt.Fprintf(w, "\nfunc (c *Client) %s(%s) ", name, strings.Join(args, ", "))
+	switch {
+	case resultType == "":
+		w.WriteString("error {\n")
+	case isStruct:
+		fmt.Fprintf(w, "(*%s, error) {\n", resultType)
+	default:
+		fmt.Fprintf(w, "(%s, error) {\n", resultType)
+	}
+
+	fmt.Fprintf(w, "\tpath := %s\n", g.pathExpr(path, pathParams))
+
+	var queries, headers []opParam
+	for _, p := range optionParams {
+		if p.In == "query" {
+			queries = append(queries, p)
+		} else {
+			headers = append(headers, p)
+		}
+	}
+	if len(queries) > 0 {
+		g.imports["net/url"] = true
+		w.WriteString("\tquery := url.Values{}\n")
+		for _, p := range queries {
+			g.writeParam(w, p, fmt.Sprintf("query.Add(%q, %%s)", p.Name))
+		}
+		w.WriteString("\tif len(query) > 0 {\n\t\tpath += \"?\" + query.Encode()\n\t}\n")
+	}
+	for _, p := range headers {
+		g.writeParam(w, p, fmt.Sprintf("ctx = client.WithHeader(ctx, %q, %%s)", p.Name))
+	}
+
+	bodyArg := "nil"
+	if bodyType != "" {
+		bodyArg = "body"
+	}
+	switch {
+	case resultType == "":
+		fmt.Fprintf(w, "\treturn c.HTTP.Do(ctx, %q, path, %s, nil)\n}\n\n", method, bodyArg)
+	case isStruct:
+		fmt.Fprintf(w, "\tvar out %s\n\tif err := c.HTTP.Do(ctx, %q, path, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n\n", resultType, method, bodyArg)
+	default:
+		fmt.Fprintf(w, "\tvar out %s\n\terr := c.HTTP.Do(ctx, %q, path, %s, &out)\n\treturn out, err\n}\n\n", resultType, method, bodyArg)
+	}
+}
+
+func (g *generator) pathExpr(path string, params []opParam) string {
+	var parts []string
+	rest := path
+	for _, p := range params {
+		placeholder := "{" + p.Name + "}"
+		i := strings.Index(rest, placeholder)
+		if i < 0 {
+			continue
+		}
+		if i > 0 {
+			parts = append(parts, fmt.Sprintf("%q", rest[:i]))
+		}
+		g.imports["net/url"] = true
+		parts = append(parts, fmt.Sprintf("url.PathEscape(%s)", g.stringify(p.ident, p.goT)))
+		rest = rest[i+len(placeholder):]
+	}
+	if rest != "" || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%q", rest))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func (g *generator) stringify(expr, goT string) string {
+	switch goT {
+	case "string":
+		return expr
+	case "time.Time":
+		return expr + ".Format(time.RFC3339)"
+	}
+	g.imports["fmt"] = true
+	return "fmt.Sprint(" + expr + ")"
+}
+
+// writeParam emits stmt (with %s for the value) for a query or header
+// parameter. Optional parameters are skipped when they hold the zero value.
+func (g *generator) writeParam(w *bytes.Buffer, p opParam, stmt string) {
+	field := "params." + p.ident
+	goT := p.goT
+
+	if strings.HasPrefix(goT, "[]") && goT != "[]byte" {
+		fmt.Fprintf(w, "\tfor _, v := range %s {\n\t\t%s\n\t}\n", field, fmt.Sprintf(stmt, g.stringify("v", goT[2:])))
+		return
+	}
+
+	value := field
+	condition := ""
+	switch {
+	case strings.HasPrefix(goT, "*"):
+		condition = field + " != nil"
+		value = "(*" + field + ")"
+		goT = goT[1:]
+	case goT == "string":
+		condition = field + ` != ""`
+	case goT == "bool":
+		condition = field
+	case goT == "time.Time":
+		condition = "!" + field + ".IsZero()"
+	case strings.HasPrefix(goT, "int") || strings.HasPrefix(goT, "float"):
+		condition = field + " != 0"
+	case strings.HasPrefix(goT, "map[") || goT == "interface{}" || goT == "[]byte":
+		condition = field + " != nil"
+	}
+
+	line := fmt.Sprintf(stmt, g.stringify(value, goT))
+	if p.Required || condition == "" {
+		fmt.Fprintf(w, "\t%s\n", line)
+		return
+	}
+	fmt.Fprintf(w, "\tif %s {\n\t\t%s\n\t}\n", condition, line)
+}
+
+func successSchema(responses map[string]*content) *schema {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if s := responses[code].jsonSchema(); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// operationName names operations without an operationId after the method
+// and path: GET /orders/{id}/items becomes GetOrdersItemsByID.
+func operationName(method, path string) string {
+	var words, params []string
+	for _, segment := range strings.Split(path, "/") {
+		if m := pathParam.FindStringSubmatch(segment); m != nil {
+			params = append(params, goName(m[1]))
+		} else if segment != "" {
+			words = append(words, goName(segment))
+		}
+	}
+	name := goName(strings.ToLower(method)) + strings.Join(words, "")
+	if len(params) > 0 {
+		name += "By" + strings.Join(params, "And")
+	}
+	return name
+}
+
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+func words(s string) []string {
+	var out []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, string(current))
+			current = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return out
+}
+
+// goName turns an OpenAPI name into an exported Go identifier.
+func goName(s string) string {
+	name := capitalize(words(s))
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+func capitalize(ws []string) string {
+	var b strings.Builder
+	for _, w := range ws {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(w)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	return b.String()
+}
+
+// lowerName turns an OpenAPI name into an unexported Go identifier.
+func lowerName(s string) string {
+	ws := words(s)
+	if len(ws) == 0 {
+		return "v"
+	}
+	name := strings.ToLower(ws[0]) + capitalize(ws[1:])
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "v" + name
+	}
+	return name
+}
+
+var reserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true,
+	"var": true, "c": true, "client": true, "context": true, "fmt": true, "time": true, "url": true,
+}
+
+func isReserved(name string) bool {
+	return reserved[name]
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || reserved[name] {
+		return false
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,638 @@
+package flux
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnsupportedValue is returned by a Codec that cannot encode or decode the
+// given value, so negotiation can move on to the next acceptable media type.
+var ErrUnsupportedValue = errors.New("codec does not support this value")
+
+var ErrNotAcceptable = DefineError("NOT_ACCEPTABLE", http.StatusNotAcceptable, "Not Acceptable")
+
+// Codec encodes response bodies and decodes request bodies for a set of media
+// types. The first media type is the canonical one.
+type Codec interface {
+	MediaTypes() []string
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+// CodecRegistry holds the codecs used by Context.Negotiate and Context.Bind.
+// Codecs registered first win ties, so JSON stays the default.
+type CodecRegistry struct {
+	codecs []Codec
+	mu     sync.RWMutex
+}
+
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	return &CodecRegistry{codecs: codecs}
+}
+
+func DefaultCodecRegistry() *CodecRegistry {
+	return NewCodecRegistry(
+		JSONCodec{},
+		XMLCodec{},
+		YAMLCodec{},
+		CSVCodec{},
+		MsgPackCodec{},
+		TextCodec{},
+	)
+}
+
+var defaultCodecs = DefaultCodecRegistry()
+
+// Register adds codec, replacing any codec already serving its media types.
+func (r *CodecRegistry) Register(codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.codecs {
+		if existing.MediaTypes()[0] == codec.MediaTypes()[0] {
+			r.codecs[i] = codec
+			return
+		}
+	}
+	r.codecs = append(r.codecs, codec)
+}
+
+// ForContentType returns the codec registered for a Content-Type header value.
+func (r *CodecRegistry) ForContentType(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, codec := range r.codecs {
+		for _, mt := range codec.MediaTypes() {
+			if mt == mediaType {
+				return codec, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Negotiated is a codec chosen for an Accept header together with the media
+// type that matched, which is what the response should advertise.
+type Negotiated struct {
+	Codec
+	MediaType string
+}
+
+// Negotiate returns the codecs acceptable for an Accept header, best first.
+func (r *CodecRegistry) Negotiate(accept string) []Negotiated {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+	ranges := parseAccept(accept)
+
+	type candidate struct {
+		Negotiated
+		q     float64
+		order int
+	}
+	var candidates []candidate
+
+	for i, codec := range r.codecs {
+		best, matched := 0.0, ""
+		for _, mt := range codec.MediaTypes() {
+			if q := acceptQuality(ranges, mt); q > best {
+				best, matched = q, mt
+			}
+		}
+		if best > 0 {
+			candidates = append(candidates, candidate{
+				Negotiated: Negotiated{Codec: codec, MediaType: matched},
+				q:          best,
+				order:      i,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].order < candidates[j].order
+	})
+
+	result := make([]Negotiated, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.Negotiated
+	}
+	return result
+}
+
+// MediaTypes lists every media type the registry can produce.
+func (r *CodecRegistry) MediaTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var types []string
+	for _, codec := range r.codecs {
+		types = append(types, codec.MediaTypes()[0])
+	}
+	return types
+}
+
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			if part != "*" {
+				continue
+			}
+			mediaType = "*/*"
+		}
+		if mediaType == "*" {
+			mediaType = "*/*"
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				continue
+			}
+			q = parsed
+		}
+
+		ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the q-value of the most specific range matching
+// mediaType, or 0 when nothing matches.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) MediaTypes() []string { return []string{"application/json"} }
+
+func (JSONCodec) Encode(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Decode(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type XMLCodec struct{}
+
+func (XMLCodec) MediaTypes() []string { return []string{"application/xml", "text/xml"} }
+
+func (XMLCodec) Encode(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		// maps and other values encoding/xml cannot represent
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedValue, err)
+	}
+	return data, nil
+}
+
+func (XMLCodec) Decode(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+type YAMLCodec struct{}
+
+func (YAMLCodec) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml"}
+}
+
+// Encode goes through JSON first so struct json tags decide the field names.
+func (YAMLCodec) Encode(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(unwrapNumbers(generic))
+}
+
+func (YAMLCodec) Decode(data []byte, v interface{}) error {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
+
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (MsgPackCodec) Encode(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	return msgp.AppendIntf(nil, generic)
+}
+
+func (MsgPackCodec) Decode(data []byte, v interface{}) error {
+	generic, _, err := msgp.ReadIntfBytes(data)
+	if err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
+
+// TextCodec only handles strings, byte slices and fmt.Stringer values.
+type TextCodec struct{}
+
+func (TextCodec) MediaTypes() []string { return []string{"text/plain"} }
+
+func (TextCodec) Encode(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	case fmt.Stringer:
+		return []byte(value.String()), nil
+	}
+	return nil, ErrUnsupportedValue
+}
+
+func (TextCodec) Decode(data []byte, v interface{}) error {
+	switch target := v.(type) {
+	case *string:
+		*target = string(data)
+		return nil
+	case *[]byte:
+		*target = append((*target)[:0], data...)
+		return nil
+	}
+	return ErrUnsupportedValue
+}
+
+// CSVCodec encodes slices of structs (one row per element, json tag names as
+// the header) and decodes them back into a pointer to a slice of structs.
+type CSVCodec struct{}
+
+func (CSVCodec) MediaTypes() []string { return []string{"text/csv"} }
+
+func (CSVCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if rows, ok := v.([][]string); ok {
+		if err := w.WriteAll(rows); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() == reflect.Struct {
+		slice := reflect.MakeSlice(reflect.SliceOf(value.Type()), 0, 1)
+		value = reflect.Append(slice, value)
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, ErrUnsupportedValue
+	}
+
+	elemType := value.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedValue
+	}
+
+	columns := csvColumns(elemType)
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(columns))
+	for i := 0; i < value.Len(); i++ {
+		row := reflect.Indirect(value.Index(i))
+		for j, col := range columns {
+			record[j] = ""
+			if row.IsValid() {
+				record[j] = formatCSVValue(row.FieldByIndex(col.index))
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (CSVCodec) Decode(data []byte, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Slice {
+		return ErrUnsupportedValue
+	}
+	slice := target.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return ErrUnsupportedValue
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]csvColumn)
+	for _, col := range csvColumns(elemType) {
+		byName[col.name] = col
+	}
+
+	header := records[0]
+	for line, record := range records[1:] {
+		elem := reflect.New(elemType).Elem()
+		for i, cell := range record {
+			if i >= len(header) {
+				break
+			}
+			col, ok := byName[header[i]]
+			if !ok {
+				continue
+			}
+			if err := parseCSVValue(elem.FieldByIndex(col.index), cell); err != nil {
+				return fmt.Errorf("line %d, column %s: %w", line+2, header[i], err)
+			}
+		}
+		if isPtr {
+			elem = elem.Addr()
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	return nil
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := field.Tag.Get("csv")
+		if tag == "" {
+			tag = field.Tag.Get("json")
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for _, nested := range csvColumns(field.Type) {
+				nested.index = append([]int{i}, nested.index...)
+				columns = append(columns, nested)
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: []int{i}})
+	}
+	return columns
+}
+
+func formatCSVValue(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(data)
+}
+
+func parseCSVValue(field reflect.Value, cell string) error {
+	if cell == "" {
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	if _, ok := field.Interface().(time.Time); ok {
+		t, err := time.Parse(time.RFC3339, cell)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(cell), field.Addr().Interface())
+	}
+	return nil
+}
+
+// toGeneric converts v into maps, slices and json.Number values so encoders
+// without struct support honour the json tags used everywhere else.
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+func fromGeneric(generic interface{}, v interface{}) error {
+	data, err := json.Marshal(normalizeGeneric(generic))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// unwrapNumbers replaces json.Number values with int64 or float64 so
+// encoders that do not know json.Number emit numbers rather than strings.
+func unwrapNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = unwrapNumbers(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = unwrapNumbers(item)
+		}
+		return value
+	}
+	return v
+}
+
+// normalizeGeneric turns map[interface{}]interface{} values (which
+// encoding/json rejects) into map[string]interface{}.
+func normalizeGeneric(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalizeGeneric(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalizeGeneric(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeGeneric(item)
+		}
+		return value
+	}
+	return v
+}
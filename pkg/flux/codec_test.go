@@ -0,0 +1,104 @@
+package flux
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type codecRow struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Secret string `json:"-"`
+}
+
+func TestCodecRegistryNegotiate(t *testing.T) {
+	registry := DefaultCodecRegistry()
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/xml;q=0.9, */*;q=0.1", "application/xml"},
+		{"text/xml; charset=utf-8", "text/xml"},
+		{"application/json;q=0.5, application/yaml", "application/yaml"},
+		{"text/*", "text/xml"},
+		{"text/csv, text/plain;q=0.5", "text/csv"},
+		{"application/x-msgpack", "application/x-msgpack"},
+	}
+
+	for _, tt := range tests {
+		codecs := registry.Negotiate(tt.accept)
+		if assert.NotEmpty(t, codecs, tt.accept) {
+			assert.Equal(t, tt.want, codecs[0].MediaType, tt.accept)
+		}
+	}
+
+	assert.Empty(t, registry.Negotiate("image/png"))
+	assert.Empty(t, registry.Negotiate("application/json;q=0"))
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	rows := []codecRow{{ID: 1, Name: "Ada, Countess"}, {ID: 2, Name: "Grace"}}
+
+	data, err := CSVCodec{}.Encode(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name\n1,\"Ada, Countess\"\n2,Grace\n", string(data))
+
+	var decoded []codecRow
+	assert.NoError(t, CSVCodec{}.Decode(data, &decoded))
+	assert.Equal(t, rows, decoded)
+
+	for _, codec := range []Codec{YAMLCodec{}, MsgPackCodec{}} {
+		data, err := codec.Encode(rows)
+		assert.NoError(t, err)
+
+		var decoded []codecRow
+		assert.NoError(t, codec.Decode(data, &decoded))
+		assert.Equal(t, rows, decoded)
+	}
+}
+
+func TestNegotiateAndBind(t *testing.T) {
+	app := newTestApplication()
+	app.codecs = DefaultCodecRegistry()
+
+	app.Get().Get("/rows", func(c *fiber.Ctx) error {
+		return NewContext(c, app).Negotiate([]codecRow{{ID: 1, Name: "Ada"}})
+	})
+	app.Get().Post("/rows", func(c *fiber.Ctx) error {
+		ctx := NewContext(c, app)
+		var row codecRow
+		if err := ctx.Bind(&row); err != nil {
+			return err
+		}
+		return ctx.JSON(row)
+	})
+
+	req := httptest.NewRequest("GET", "/rows", nil)
+	req.Header.Set("Accept", "text/csv;q=0.8, application/json;q=0.5")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "id,name\n1,Ada\n", string(body))
+
+	req = httptest.NewRequest("GET", "/rows", nil)
+	req.Header.Set("Accept", "image/png")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 406, resp.StatusCode)
+
+	req = httptest.NewRequest("POST", "/rows", strings.NewReader("id: 3\nname: Linus\n"))
+	req.Header.Set("Content-Type", "application/yaml")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"id":3,"name":"Linus"}`, string(body))
+}
@@ -1,7 +1,9 @@
 package flux
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -13,8 +15,6 @@ import (
 )
 
 
-var validate = validator.New()
-
 
 type Context struct {
 	*fiber.Ctx
@@ -53,6 +53,11 @@ func (c *Context) App() *Application {
 	return c.app
 }
 
+// Dispatch sends event to the app's listeners with the request's context.
+func (c *Context) Dispatch(event interface{}) error {
+	return c.app.Events().DispatchContext(c.UserContext(), event)
+}
+
 
 func (c *Context) JSON(data interface{}) error {
 	return c.Ctx.JSON(data)
@@ -80,41 +85,69 @@ func (c *Context) Text(text string) error {
 }
 
 
-func (c *Context) Negotiate(data interface{}) error {
-	accept := c.Ctx.Get("Accept")
-
-	switch {
-	case accept == "application/xml" || accept == "text/xml":
-		return c.XML(data)
-	case accept == "text/plain":
-		
-		if str, ok := data.(string); ok {
-			return c.Text(str)
-		}
-		return c.JSON(data) 
-	default:
-		return c.JSON(data) 
+func (c *Context) codecs() *CodecRegistry {
+	if c.app != nil && c.app.codecs != nil {
+		return c.app.codecs
 	}
+	return defaultCodecs
 }
 
+// Negotiate encodes data with the best codec for the Accept header and
+// responds 406 Not Acceptable when no registered codec matches.
+func (c *Context) Negotiate(data interface{}) error {
+	c.Ctx.Vary(fiber.HeaderAccept)
 
-func (c *Context) Bind(v interface{}) error {
-	
-	if err := c.Ctx.BodyParser(v); err != nil {
-		return err
+	for _, codec := range c.codecs().Negotiate(c.Ctx.Get(fiber.HeaderAccept)) {
+		body, err := codec.Encode(data)
+		if errors.Is(err, ErrUnsupportedValue) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		contentType := codec.MediaType
+		if strings.HasPrefix(contentType, "text/") {
+			contentType += "; charset=utf-8"
+		}
+		c.Ctx.Set(fiber.HeaderContentType, contentType)
+		return c.Ctx.Send(body)
 	}
 
-	
-	if err := validate.Struct(v); err != nil {
+	return ErrNotAcceptable.WithDetail("supported", c.codecs().MediaTypes())
+}
+
+// Bind decodes the body with the codec registered for its Content-Type,
+// falling back to Fiber's parser for forms, then validates it.
+func (c *Context) Bind(v interface{}) error {
+	if codec, ok := c.codecs().ForContentType(c.Ctx.Get(fiber.HeaderContentType)); ok {
+		if err := codec.Decode(c.Ctx.Body(), v); err != nil {
+			return err
+		}
+	} else if err := c.Ctx.BodyParser(v); err != nil {
 		return err
 	}
 
-	return nil
+	return c.Validate(v)
 }
 
 
+// Validate checks v against its `validate` tags and the rules registered on
+// the application. Failures are validator.ValidationErrors; handlers can
+// return them as they are or wrap them with ValidationFailed. A unique or
+// exists rule that is misconfigured or whose query fails makes Validate
+// return a 500 error instead.
 func (c *Context) Validate(v interface{}) error {
-	return validate.Struct(v)
+	if err := checkRules(c.app, v); err != nil {
+		return &ruleError{err: err}
+	}
+	state := &ruleState{}
+	ctx := context.WithValue(c.Ctx.UserContext(), ruleStateKey{}, state)
+	err := c.validator().StructCtx(ctx, v)
+	if state.err != nil {
+		return state.err
+	}
+	return err
 }
 
 
@@ -123,26 +156,7 @@ func (c *Context) ValidateWithDetails(i interface{}) ValidationErrors {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
 			errors := make(ValidationErrors)
 			for _, e := range validationErrors {
-				fieldName := e.Field()
-				
-				if len(fieldName) > 0 && fieldName[0] >= 'A' && fieldName[0] <= 'Z' {
-					fieldName = string(fieldName[0]+32) + fieldName[1:]
-				}
-
-				switch e.Tag() {
-				case "required":
-					errors[fieldName] = fmt.Sprintf("The %s field is required", fieldName)
-				case "email":
-					errors[fieldName] = fmt.Sprintf("The %s must be a valid email address", fieldName)
-				case "min":
-					errors[fieldName] = fmt.Sprintf("The %s must be at least %s characters", fieldName, e.Param())
-				case "max":
-					errors[fieldName] = fmt.Sprintf("The %s must not be greater than %s characters", fieldName, e.Param())
-				case "url":
-					errors[fieldName] = fmt.Sprintf("The %s must be a valid URL", fieldName)
-				default:
-					errors[fieldName] = fmt.Sprintf("The %s field is invalid (failed %s validation)", fieldName, e.Tag())
-				}
+				errors[fieldPath(e)] = translateValidation(localizerFor(c.Ctx), e)
 			}
 			return errors
 		}
@@ -152,6 +166,56 @@ func (c *Context) ValidateWithDetails(i interface{}) ValidationErrors {
 }
 
 
+// validationMessage is the human readable message for a failed validation rule.
+func validationMessage(e validator.FieldError) string {
+	fieldName := fieldPath(e)
+	switch e.Tag() {
+	case "required":
+		return fmt.Sprintf("The %s field is required", fieldName)
+	case "email":
+		return fmt.Sprintf("The %s must be a valid email address", fieldName)
+	case "min":
+		return fmt.Sprintf("The %s must be at least %s characters", fieldName, e.Param())
+	case "max":
+		return fmt.Sprintf("The %s must not be greater than %s characters", fieldName, e.Param())
+	case "url":
+		return fmt.Sprintf("The %s must be a valid URL", fieldName)
+	case "oneof":
+		return fmt.Sprintf("The %s must be one of: %s", fieldName, strings.ReplaceAll(e.Param(), " ", ", "))
+	case "eqfield":
+		return fmt.Sprintf("The %s must match %s", fieldName, lowerFirst(e.Param()))
+	case "nefield":
+		return fmt.Sprintf("The %s must be different from %s", fieldName, lowerFirst(e.Param()))
+	case "gtfield", "gtefield", "ltfield", "ltefield":
+		return fmt.Sprintf("The %s must be %s %s", fieldName, comparisons[e.Tag()], lowerFirst(e.Param()))
+	case "required_if", "required_with", "required_without":
+		return fmt.Sprintf("The %s field is required", fieldName)
+	case "unique":
+		return fmt.Sprintf("The %s has already been taken", fieldName)
+	case "exists":
+		return fmt.Sprintf("The selected %s is invalid", fieldName)
+	default:
+		return fmt.Sprintf("The %s field is invalid (failed %s validation)", fieldName, e.Tag())
+	}
+}
+
+
+var comparisons = map[string]string{
+	"gtfield":  "greater than",
+	"gtefield": "greater than or equal to",
+	"ltfield":  "less than",
+	"ltefield": "less than or equal to",
+}
+
+
+func lowerFirst(s string) string {
+	if len(s) > 0 && s[0] >= 'A' && s[0] <= 'Z' {
+		return string(s[0]+32) + s[1:]
+	}
+	return s
+}
+
+
 func (c *Context) RespondWithValidationErrors(errors ValidationErrors) error {
 	return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
 		"error":   true,
@@ -163,11 +227,15 @@ func (c *Context) RespondWithValidationErrors(errors ValidationErrors) error {
 
 func (c *Context) BindAndValidate(v interface{}) error {
 	if err := c.Bind(v); err != nil {
+		if fieldErrors(err) != nil {
+			return ValidationFailed(err)
+		}
+		var ruleErr *ruleError
+		if errors.As(err, &ruleErr) {
+			return err
+		}
 		return NewAppError("Invalid request body", 400).WithError(err)
 	}
-	if err := c.Validate(v); err != nil {
-		return NewAppError("Validation failed", 422).WithError(err)
-	}
 	return nil
 }
 
@@ -233,14 +301,15 @@ func (c *Context) FormValue(key string) string {
 
 
 func (c *Context) Error(err error) error {
-	if appErr, ok := err.(*AppError); ok {
-		code := 500
-		if appErr.Code != "" {
-			code = appErr.StatusCode
-		}
-		return c.Status(code).JSON(appErr)
+	if c.problemFormat() {
+		return writeProblem(c.Ctx, NewProblem(c.Ctx, err))
 	}
-	return c.Status(500).JSON(NewAppError("Internal Server Error", 500).WithError(err))
+	return HandleError(c, err)
+}
+
+
+func (c *Context) problemFormat() bool {
+	return c.app != nil && c.app.config != nil && c.app.config.ErrorFormat == ErrorFormatProblem
 }
 
 
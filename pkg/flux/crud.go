@@ -0,0 +1,527 @@
+package flux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// CRUDHook runs around a write. record is a pointer to the model; returning
+// an error aborts the request (and, for before hooks, the write).
+type CRUDHook func(ctx *Context, record interface{}) error
+
+type CRUDHooks struct {
+	BeforeCreate CRUDHook
+	AfterCreate  CRUDHook
+	BeforeUpdate CRUDHook
+	AfterUpdate  CRUDHook
+	BeforeDelete CRUDHook
+	AfterDelete  CRUDHook
+}
+
+type CRUDOptions struct {
+	// Actions limits the generated routes to a subset of "list", "show",
+	// "create", "update" and "delete". All are served when empty.
+	Actions []string
+	// Writable lists the JSON fields clients may set on create and update.
+	// When empty every field except primary keys, timestamps and the
+	// soft-delete column is writable.
+	Writable []string
+	// Query whitelists filters and sorts for the list route.
+	Query      QueryOptions
+	Pagination PaginationConfig
+	// Scope restricts every query, e.g. to the current tenant.
+	Scope func(ctx *Context, db *gorm.DB) *gorm.DB
+	Hooks CRUDHooks
+	// AllowTrashed lets list and show include soft-deleted rows with
+	// ?trashed=with or ?trashed=only.
+	AllowTrashed bool
+	// RequireIfMatch rejects updates and deletes without an If-Match header
+	// with 428, so clients cannot overwrite changes they have not seen.
+	RequireIfMatch bool
+	Middleware     []fiber.Handler
+	// Tag groups the routes in the OpenAPI document. Defaults to the model name.
+	Tag string
+}
+
+type crudResource struct {
+	app       *Application
+	path      string
+	modelType reflect.Type
+	schema    *schema.Schema
+	primary   *schema.Field
+	writable  map[string]*schema.Field
+	deletedAt *schema.Field
+	version   *schema.Field
+	opts      CRUDOptions
+}
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// CRUD serves list, show, create, update and delete routes for a GORM model:
+//
+//	GET    /products       paginated, filtered and sorted list
+//	GET    /products/:id
+//	POST   /products       validated with `validate` struct tags
+//	PUT    /products/:id   partial update (PATCH is accepted too)
+//	DELETE /products/:id   soft delete when the model has gorm.DeletedAt
+//
+// Responses carry an ETag from the model's Version or UpdatedAt field; show
+// honours If-None-Match, and update and delete honour If-Match and
+// If-Unmodified-Since. Models with a Version field are updated with
+// optimistic locking. The routes are also added to the OpenAPI document.
+func (app *Application) CRUD(path string, model interface{}, opts ...CRUDOptions) error {
+	if app.database == nil {
+		return fmt.Errorf("CRUD resources require a database")
+	}
+
+	var options CRUDOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	s, err := parseModel(model, app.database.DB.NamingStrategy)
+	if err != nil {
+		return err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return fmt.Errorf("model %s has no primary key", s.Name)
+	}
+	if err := app.CheckValidationRules(model); err != nil {
+		return err
+	}
+	if options.Tag == "" {
+		options.Tag = s.Name
+	}
+
+	r := &crudResource{
+		app:       app,
+		path:      "/" + strings.Trim(path, "/"),
+		modelType: s.ModelType,
+		schema:    s,
+		primary:   s.PrioritizedPrimaryField,
+		writable:  make(map[string]*schema.Field),
+		opts:      options,
+	}
+
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAtType {
+			r.deletedAt = field
+		}
+		if field.Name == "Version" && field.DBName != "" {
+			if _, ok := modelVersion(reflect.New(s.ModelType).Interface()); ok {
+				r.version = field
+				continue
+			}
+		}
+		name := jsonFieldName(field)
+		if field.DBName == "" || name == "" {
+			continue
+		}
+		if len(options.Writable) > 0 {
+			if containsString(options.Writable, name) {
+				r.writable[name] = field
+			}
+			continue
+		}
+		if field.PrimaryKey || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 || field.FieldType == deletedAtType {
+			continue
+		}
+		r.writable[name] = field
+	}
+
+	handlers := make([]interface{}, len(options.Middleware))
+	for i, m := range options.Middleware {
+		handlers[i] = m
+	}
+	group := app.server.Group(r.path)
+	if len(handlers) > 0 {
+		group.Use(handlers...)
+	}
+
+	if r.enabled("list") {
+		group.Get("/", r.wrap(r.list))
+	}
+	if r.enabled("show") {
+		group.Get("/:id", r.wrap(r.show))
+	}
+	if r.enabled("create") {
+		group.Post("/", r.wrap(r.create))
+	}
+	if r.enabled("update") {
+		group.Put("/:id", r.wrap(r.update))
+		group.Patch("/:id", r.wrap(r.update))
+	}
+	if r.enabled("delete") {
+		group.Delete("/:id", r.wrap(r.delete))
+	}
+
+	r.document()
+	return nil
+}
+
+func jsonFieldName(field *schema.Field) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func (r *crudResource) enabled(action string) bool {
+	return len(r.opts.Actions) == 0 || containsString(r.opts.Actions, action)
+}
+
+func (r *crudResource) wrap(handler func(*Context) error) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return handler(NewContext(c, r.app))
+	}
+}
+
+func (r *crudResource) query(ctx *Context) *gorm.DB {
+	db := r.app.database.DB.WithContext(ctx.Context()).Model(reflect.New(r.modelType).Interface())
+	if r.deletedAt != nil && r.opts.AllowTrashed {
+		switch ctx.Ctx.Query("trashed") {
+		case "with":
+			db = db.Unscoped()
+		case "only":
+			db = db.Unscoped().Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: r.deletedAt.DBName}, Value: nil})
+		}
+	}
+	if r.opts.Scope != nil {
+		db = r.opts.Scope(ctx, db)
+	}
+	return db
+}
+
+func (r *crudResource) list(ctx *Context) error {
+	// Keyset pages must be ordered by the cursor field alone, or rows are
+	// skipped or repeated between pages.
+	queryOpts := r.opts.Query
+	if r.opts.Pagination.Cursor {
+		if ctx.Ctx.Query("sort") != "" {
+			return ErrInvalidQuery.WithDetail("sort", "sorting is not supported with cursor pagination")
+		}
+		queryOpts.DefaultSort = ""
+	}
+
+	scope, err := queryScope(ctx, reflect.New(r.modelType).Interface(), queryOpts)
+	if err != nil {
+		return err
+	}
+
+	rows := reflect.New(reflect.SliceOf(r.modelType))
+	rows.Elem().Set(reflect.MakeSlice(reflect.SliceOf(r.modelType), 0, 0))
+
+	query := r.query(ctx).Scopes(scope)
+	if ctx.Ctx.Query("sort") == "" && r.opts.Query.DefaultSort == "" && !r.opts.Pagination.Cursor {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: r.primary.DBName}})
+	}
+
+	meta, err := paginate(ctx, query, rows.Interface(), r.opts.Pagination)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(fiber.Map{"data": rows.Elem().Interface(), "meta": meta})
+}
+
+func (r *crudResource) find(ctx *Context, db *gorm.DB) (interface{}, error) {
+	id, err := convertScalar(r.primary.IndirectFieldType, ctx.Ctx.Params("id"))
+	if err != nil {
+		return nil, NotFoundError(r.schema.Name)
+	}
+
+	record := reflect.New(r.modelType).Interface()
+	err = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.primary.DBName}, Value: id}).First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, NotFoundError(r.schema.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *crudResource) show(ctx *Context) error {
+	record, err := r.find(ctx, r.query(ctx))
+	if err != nil {
+		return err
+	}
+	r.setETag(ctx, record)
+	if ctx.NotModified() {
+		return nil
+	}
+	return ctx.JSON(record)
+}
+
+func (r *crudResource) setETag(ctx *Context, record interface{}) {
+	if tag := ModelETag(record); tag != "" {
+		ctx.Set(fiber.HeaderETag, tag)
+	}
+}
+
+// reload re-reads record after a write when its ETag comes from UpdatedAt.
+// The database may keep less precision than the in-memory time, so the
+// stored row is what later If-Match headers are compared against.
+func (r *crudResource) reload(ctx *Context, record interface{}) error {
+	if r.version != nil {
+		return nil
+	}
+	if _, ok := modelUpdatedAt(record); !ok {
+		return nil
+	}
+	id, _ := r.primary.ValueOf(ctx.Context(), reflect.ValueOf(record).Elem())
+	err := r.query(ctx).Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.primary.DBName}, Value: id}).First(record).Error
+	if err != nil {
+		return fmt.Errorf("failed to reload %s: %w", r.schema.Name, err)
+	}
+	return nil
+}
+
+func (r *crudResource) checkPreconditions(ctx *Context, record interface{}) error {
+	if r.opts.RequireIfMatch && ctx.Get(fiber.HeaderIfMatch) == "" {
+		return ErrPreconditionRequired
+	}
+	return ctx.CheckPreconditions(record)
+}
+
+// decode reads the request body into record, rejecting fields that are not
+// writable, and returns the columns that were set.
+func (r *crudResource) decode(ctx *Context, record interface{}) ([]string, error) {
+	codec, ok := ctx.codecs().ForContentType(ctx.Ctx.Get(fiber.HeaderContentType))
+	if !ok {
+		codec = JSONCodec{}
+	}
+
+	var generic map[string]interface{}
+	if err := codec.Decode(ctx.Ctx.Body(), &generic); err != nil {
+		return nil, NewAppError("Invalid request body", http.StatusBadRequest).WithError(err)
+	}
+
+	body := make(map[string]json.RawMessage, len(generic))
+	rejected := make(map[string]interface{})
+	var columns []string
+	for key, value := range generic {
+		field, ok := r.writable[key]
+		if !ok {
+			rejected[key] = "field is not writable"
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, ErrBadRequest.WithError(err)
+		}
+		body[key] = raw
+		columns = append(columns, field.DBName)
+	}
+	if len(rejected) > 0 {
+		return nil, ErrBadRequest.WithDetails(rejected)
+	}
+
+	data, _ := json.Marshal(body)
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, NewAppError("Invalid request body", http.StatusBadRequest).WithError(err)
+	}
+
+	if err := ctx.Validate(record); err != nil {
+		if fieldErrors(err) != nil {
+			return nil, ValidationFailed(err)
+		}
+		return nil, err
+	}
+
+	sort.Strings(columns)
+	return columns, nil
+}
+
+func runHook(hook CRUDHook, ctx *Context, record interface{}) error {
+	if hook == nil {
+		return nil
+	}
+	return hook(ctx, record)
+}
+
+func (r *crudResource) create(ctx *Context) error {
+	record := reflect.New(r.modelType).Interface()
+	if _, err := r.decode(ctx, record); err != nil {
+		return err
+	}
+	if err := runHook(r.opts.Hooks.BeforeCreate, ctx, record); err != nil {
+		return err
+	}
+
+	if err := r.query(ctx).Create(record).Error; err != nil {
+		return fmt.Errorf("failed to create %s: %w", r.schema.Name, err)
+	}
+
+	if err := r.reload(ctx, record); err != nil {
+		return err
+	}
+	if err := runHook(r.opts.Hooks.AfterCreate, ctx, record); err != nil {
+		return err
+	}
+	r.setETag(ctx, record)
+	return ctx.Status(http.StatusCreated).JSON(record)
+}
+
+func (r *crudResource) update(ctx *Context) error {
+	record, err := r.find(ctx, r.query(ctx))
+	if err != nil {
+		return err
+	}
+	if err := r.checkPreconditions(ctx, record); err != nil {
+		return err
+	}
+
+	columns, err := r.decode(ctx, record)
+	if err != nil {
+		return err
+	}
+	if err := runHook(r.opts.Hooks.BeforeUpdate, ctx, record); err != nil {
+		return err
+	}
+
+	if len(columns) > 0 && r.version != nil {
+		if err := r.app.database.UpdateVersioned(ctx.Context(), record, columns...); err != nil {
+			return err
+		}
+	} else if len(columns) > 0 {
+		if err := r.app.database.DB.WithContext(ctx.Context()).Model(record).Select(columns).Updates(record).Error; err != nil {
+			return fmt.Errorf("failed to update %s: %w", r.schema.Name, err)
+		}
+	}
+
+	if err := r.reload(ctx, record); err != nil {
+		return err
+	}
+	if err := runHook(r.opts.Hooks.AfterUpdate, ctx, record); err != nil {
+		return err
+	}
+	r.setETag(ctx, record)
+	return ctx.JSON(record)
+}
+
+func (r *crudResource) delete(ctx *Context) error {
+	record, err := r.find(ctx, r.query(ctx))
+	if err != nil {
+		return err
+	}
+	if err := r.checkPreconditions(ctx, record); err != nil {
+		return err
+	}
+	if err := runHook(r.opts.Hooks.BeforeDelete, ctx, record); err != nil {
+		return err
+	}
+
+	db := r.app.database.DB.WithContext(ctx.Context())
+	if r.version != nil {
+		if err := deleteVersioned(db, record, r.version.DBName); err != nil {
+			if errors.Is(err, ErrPreconditionFailed) {
+				return err
+			}
+			return fmt.Errorf("failed to delete %s: %w", r.schema.Name, err)
+		}
+	} else if err := db.Delete(record).Error; err != nil {
+		return fmt.Errorf("failed to delete %s: %w", r.schema.Name, err)
+	}
+
+	if err := runHook(r.opts.Hooks.AfterDelete, ctx, record); err != nil {
+		return err
+	}
+	return ctx.Ctx.SendStatus(http.StatusNoContent)
+}
+
+func (r *crudResource) document() {
+	base := strings.TrimSuffix(r.app.config.Server.BasePath, "/") + r.path
+	item := "/{id}"
+	model := generateSchemaFromType(r.modelType)
+	name := r.schema.Name
+	idParam := &Parameter{Name: "id", In: "path", Required: true, Schema: generateSchemaFromType(r.primary.IndirectFieldType)}
+
+	jsonResponse := func(description string, s *Schema) *Response {
+		return &Response{Description: description, Content: map[string]MediaTypeObject{"application/json": {Schema: s}}}
+	}
+
+	if r.enabled("list") {
+		params := PaginationParameters(r.opts.Pagination.Cursor)
+		if !r.opts.Pagination.Cursor {
+			params = append(params, &Parameter{Name: "sort", In: "query", Description: "Comma separated fields, prefix with - for descending: " + strings.Join(r.opts.Query.Sorts, ", "), Schema: &Schema{Type: "string"}})
+		}
+		fields := make([]string, 0, len(r.opts.Query.Filters))
+		for field := range r.opts.Query.Filters {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			for _, op := range r.opts.Query.Filters[field] {
+				params = append(params, &Parameter{Name: fmt.Sprintf("filter[%s][%s]", field, op), In: "query", Schema: &Schema{Type: "string"}})
+			}
+		}
+		r.app.DocumentRoute("GET", base, &Operation{
+			Summary:     "List " + name,
+			OperationID: "list" + name,
+			Tags:        []string{r.opts.Tag},
+			Parameters:  params,
+			Responses:   map[string]*Response{"200": jsonResponse("Successful operation", PageSchema(r.modelType))},
+		})
+	}
+
+	if r.enabled("show") {
+		r.app.DocumentRoute("GET", base+item, &Operation{
+			Summary:     "Get " + name,
+			OperationID: "get" + name,
+			Tags:        []string{r.opts.Tag},
+			Parameters:  []*Parameter{idParam},
+			Responses:   map[string]*Response{"200": jsonResponse("Successful operation", model), "304": {Description: "Not modified"}, "404": {Description: name + " not found"}},
+		})
+	}
+
+	input := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for name, field := range r.writable {
+		input.Properties[name] = generateSchemaFromType(field.FieldType)
+	}
+	body := &RequestBody{Required: true, Content: map[string]MediaTypeObject{"application/json": {Schema: input}}}
+
+	if r.enabled("create") {
+		r.app.DocumentRoute("POST", base, &Operation{
+			Summary:     "Create " + name,
+			OperationID: "create" + name,
+			Tags:        []string{r.opts.Tag},
+			RequestBody: body,
+			Responses:   map[string]*Response{"201": jsonResponse("Created", model), "422": {Description: "Validation failed"}},
+		})
+	}
+
+	if r.enabled("update") {
+		r.app.DocumentRoute("PUT", base+item, &Operation{
+			Summary:     "Update " + name,
+			OperationID: "update" + name,
+			Tags:        []string{r.opts.Tag},
+			Parameters:  []*Parameter{idParam},
+			RequestBody: body,
+			Responses:   map[string]*Response{"200": jsonResponse("Successful operation", model), "404": {Description: name + " not found"}, "412": {Description: "Precondition failed"}, "422": {Description: "Validation failed"}},
+		})
+	}
+
+	if r.enabled("delete") {
+		r.app.DocumentRoute("DELETE", base+item, &Operation{
+			Summary:     "Delete " + name,
+			OperationID: "delete" + name,
+			Tags:        []string{r.opts.Tag},
+			Parameters:  []*Parameter{idParam},
+			Responses:   map[string]*Response{"204": {Description: "Deleted"}, "404": {Description: name + " not found"}, "412": {Description: "Precondition failed"}},
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package flux
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type crudProduct struct {
+	gorm.Model
+	Name   string  `json:"name" validate:"required"`
+	Price  float64 `json:"price" validate:"gte=0"`
+	Status string  `json:"status"`
+	Secret string  `json:"secret"`
+}
+
+func TestCRUD(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &crudProduct{})
+
+	var hooks []string
+	err := app.CRUD("/products", &crudProduct{}, CRUDOptions{
+		Writable: []string{"name", "price", "status"},
+		Query: QueryOptions{
+			Filters: map[string][]FilterOperator{"status": {OpEq}},
+			Sorts:   []string{"price"},
+		},
+		AllowTrashed: true,
+		Hooks: CRUDHooks{
+			BeforeCreate: func(ctx *Context, record interface{}) error {
+				p := record.(*crudProduct)
+				if p.Name == "forbidden" {
+					return ErrForbidden
+				}
+				p.Status = "draft"
+				return nil
+			},
+			AfterDelete: func(ctx *Context, record interface{}) error {
+				hooks = append(hooks, "deleted:"+record.(*crudProduct).Name)
+				return nil
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	send := func(method, url, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		var out map[string]interface{}
+		json.Unmarshal(data, &out)
+		return resp.StatusCode, out
+	}
+
+	status, created := send("POST", "/products", `{"name":"Lamp","price":30}`)
+	assert.Equal(t, 201, status)
+	assert.Equal(t, "draft", created["status"])
+	send("POST", "/products", `{"name":"Desk","price":120}`)
+
+	status, _ = send("POST", "/products", `{"name":"Chair","secret":"x"}`)
+	assert.Equal(t, 400, status, "non-writable field")
+	status, _ = send("POST", "/products", `{"price":-1}`)
+	assert.Equal(t, 422, status)
+	status, _ = send("POST", "/products", `{"name":"forbidden"}`)
+	assert.Equal(t, 403, status)
+
+	status, updated := send("PATCH", "/products/1", `{"status":"active"}`)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "active", updated["status"])
+	assert.Equal(t, "Lamp", updated["name"])
+
+	status, list := send("GET", "/products?filter[status]=draft&sort=-price", "")
+	assert.Equal(t, 200, status)
+	data := list["data"].([]interface{})
+	assert.Len(t, data, 1)
+	assert.Equal(t, "Desk", data[0].(map[string]interface{})["name"])
+
+	status, _ = send("DELETE", "/products/2", "")
+	assert.Equal(t, 204, status)
+	assert.Equal(t, []string{"deleted:Desk"}, hooks)
+
+	status, _ = send("GET", "/products/2", "")
+	assert.Equal(t, 404, status)
+	status, list = send("GET", "/products?trashed=only", "")
+	assert.Equal(t, 200, status)
+	assert.Len(t, list["data"], 1)
+
+	var deleted crudProduct
+	err = app.DB().Unscoped().First(&deleted, 2).Error
+	assert.False(t, errors.Is(err, gorm.ErrRecordNotFound), "soft deleted")
+
+	spec, err := app.GenerateOpenAPI()
+	assert.NoError(t, err)
+	assert.NotNil(t, spec.Paths["/products"].Get)
+	assert.NotNil(t, spec.Paths["/products/{id}"].Put)
+	assert.Contains(t, spec.Paths["/products"].Post.RequestBody.Content["application/json"].Schema.Properties, "price")
+	assert.NotContains(t, spec.Paths["/products"].Post.RequestBody.Content["application/json"].Schema.Properties, "secret")
+	assert.Contains(t, spec.Paths["/products/{id}"].Get.Responses["200"].Content["application/json"].Schema.Properties, "ID")
+}
+
+func TestCRUDCursorPaginationIgnoresSorting(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &crudProduct{})
+	assert.NoError(t, app.CRUD("/products", &crudProduct{}, CRUDOptions{
+		Query:      QueryOptions{Sorts: []string{"name"}, DefaultSort: "name"},
+		Pagination: PaginationConfig{Cursor: true, DefaultPerPage: 2},
+	}))
+	for _, name := range []string{"e", "a", "d", "b", "c"} {
+		assert.NoError(t, app.DB().Create(&crudProduct{Name: name}).Error)
+	}
+
+	get := func(url string) (int, map[string]interface{}) {
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		assert.NoError(t, err)
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, _ := get("/products?sort=name")
+	assert.Equal(t, 400, status)
+
+	// DefaultSort is ignored so every row shows up exactly once.
+	var ids []float64
+	url := "/products"
+	for i := 0; i < 5 && url != ""; i++ {
+		status, page := get(url)
+		assert.Equal(t, 200, status)
+		for _, row := range page["data"].([]interface{}) {
+			ids = append(ids, row.(map[string]interface{})["ID"].(float64))
+		}
+		url = ""
+		if cursor, ok := page["meta"].(map[string]interface{})["next_cursor"].(string); ok {
+			url = "/products?cursor=" + cursor
+		}
+	}
+	assert.Equal(t, []float64{1, 2, 3, 4, 5}, ids)
+}
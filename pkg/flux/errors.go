@@ -4,17 +4,110 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 var (
-	ErrNotFound      = NewAppError("not found", http.StatusNotFound)
-	ErrUnauthorized  = NewAppError("unauthorized", http.StatusUnauthorized)
-	ErrForbidden     = NewAppError("forbidden", http.StatusForbidden)
-	ErrBadRequest    = NewAppError("bad request", http.StatusBadRequest)
-	ErrInternalError = NewAppError("internal server error", http.StatusInternalServerError)
-	ErrValidation    = NewAppError("validation error", http.StatusBadRequest)
+	ErrNotFound         = DefineError("NOT_FOUND", http.StatusNotFound, "not found")
+	ErrUnauthorized     = DefineError("UNAUTHORIZED", http.StatusUnauthorized, "unauthorized")
+	ErrForbidden        = DefineError("FORBIDDEN", http.StatusForbidden, "forbidden")
+	ErrBadRequest       = DefineError("BAD_REQUEST", http.StatusBadRequest, "bad request")
+	ErrInternalError    = DefineError("INTERNAL_ERROR", http.StatusInternalServerError, "internal server error")
+	ErrValidation       = DefineError("VALIDATION_ERROR", http.StatusBadRequest, "validation error")
+	ErrValidationFailed = DefineError("VALIDATION_FAILED", http.StatusUnprocessableEntity, "Validation failed")
 )
 
+var catalog = struct {
+	sync.RWMutex
+	codes    map[string]*AppError
+	mappings []errorMapping
+}{codes: make(map[string]*AppError)}
+
+type errorMapping struct {
+	target error
+	appErr *AppError
+}
+
+func init() {
+	MapError(gorm.ErrRecordNotFound, ErrNotFound)
+}
+
+// DefineError registers an error with a stable code clients can rely on.
+// Define errors once at package level; defining a code twice panics.
+//
+//	var ErrUserNotFound = flux.DefineError("USER_NOT_FOUND", 404, "user not found")
+func DefineError(code string, statusCode int, message string) *AppError {
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	if _, exists := catalog.codes[code]; exists {
+		panic(fmt.Sprintf("flux: error code %s is already defined", code))
+	}
+	appErr := NewAppError(message, statusCode).WithCode(code)
+	catalog.codes[code] = appErr
+	return appErr
+}
+
+func LookupError(code string) (*AppError, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	appErr, ok := catalog.codes[code]
+	return appErr, ok
+}
+
+// ErrorCatalog returns every defined error sorted by code, e.g. for docs.
+func ErrorCatalog() []*AppError {
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	errs := make([]*AppError, 0, len(catalog.codes))
+	for _, e := range catalog.codes {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Code < errs[j].Code })
+	return errs
+}
+
+// MapError makes handlers that return target (or an error wrapping it)
+// respond with appErr. gorm.ErrRecordNotFound maps to ErrNotFound.
+func MapError(target error, appErr *AppError) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	catalog.mappings = append(catalog.mappings, errorMapping{target: target, appErr: appErr})
+}
+
+// resolveError turns any handler error into the AppError that is shown to
+// the client, keeping the original error in Err.
+func resolveError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return NewAppError(fiberErr.Message, fiberErr.Code)
+	}
+
+	catalog.RLock()
+	for _, m := range catalog.mappings {
+		if errors.Is(err, m.target) {
+			catalog.RUnlock()
+			return m.appErr.WithError(err)
+		}
+	}
+	catalog.RUnlock()
+
+	if fieldErrors(err) != nil {
+		return ValidationFailed(err)
+	}
+	return ErrInternalError.WithError(err)
+}
+
 type AppError struct {
 	Message    string                 `json:"message"`
 	StatusCode int                    `json:"-"`
@@ -38,22 +131,43 @@ func (e *AppError) Error() string {
 	return e.Message
 }
 
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches another AppError with the same code, so errors.Is(err,
+// ErrNotFound) holds for copies made by WithError or decoded by the client.
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
 func (e *AppError) WithError(err error) *AppError {
 	clone := *e
 	clone.Err = err
 	return &clone
 }
 
+// WithDetails returns a copy whose details are replaced by a copy of details.
 func (e *AppError) WithDetails(details map[string]interface{}) *AppError {
 	clone := *e
-	clone.Details = details
+	clone.Details = nil
+	if details != nil {
+		clone.Details = make(map[string]interface{}, len(details))
+		for k, v := range details {
+			clone.Details[k] = v
+		}
+	}
 	return &clone
 }
 
+// WithDetail returns a copy with key set. The receiver's details are copied,
+// never written, so it is safe to call on shared DefineError sentinels.
 func (e *AppError) WithDetail(key string, value interface{}) *AppError {
 	clone := *e
-	if clone.Details == nil {
-		clone.Details = make(map[string]interface{})
+	clone.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		clone.Details[k] = v
 	}
 	clone.Details[key] = value
 	return &clone
@@ -66,11 +180,7 @@ func (e *AppError) WithCode(code string) *AppError {
 }
 
 func AsAppError(err error) *AppError {
-	var appErr *AppError
-	if errors.As(err, &appErr) {
-		return appErr
-	}
-	return ErrInternalError.WithError(err)
+	return resolveError(err)
 }
 
 func ValidationError(errors map[string]string) *AppError {
@@ -90,6 +200,9 @@ func NotFoundError(entity string) *AppError {
 }
 
 func HandleError(ctx *Context, err error) error {
-	appErr := AsAppError(err)
+	if ctx.problemFormat() {
+		return writeProblem(ctx.Ctx, NewProblem(ctx.Ctx, err))
+	}
+	appErr, _ := publicError(ctx.Ctx, err)
 	return ctx.Status(appErr.StatusCode).JSON(appErr)
 }
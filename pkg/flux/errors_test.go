@@ -0,0 +1,76 @@
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var errTestOrderLocked = DefineError("TEST_ORDER_LOCKED", 409, "order is locked")
+
+func TestErrorCatalog(t *testing.T) {
+	found, ok := LookupError("TEST_ORDER_LOCKED")
+	assert.True(t, ok)
+	assert.Same(t, errTestOrderLocked, found)
+	assert.Contains(t, ErrorCatalog(), ErrNotFound)
+	assert.Panics(t, func() { DefineError("TEST_ORDER_LOCKED", 400, "again") })
+
+	assert.Equal(t, ErrNotFound.Code, AsAppError(fmt.Errorf("load user: %w", gorm.ErrRecordNotFound)).Code)
+	assert.Equal(t, 404, AsAppError(gorm.ErrRecordNotFound).StatusCode)
+}
+
+func TestErrorScrubbingInProduction(t *testing.T) {
+	app := newTestApplication()
+	app.Get().Get("/db", func(c *fiber.Ctx) error {
+		return fmt.Errorf("query failed: %w", fmt.Errorf("pq: password authentication failed for user \"admin\""))
+	})
+	app.Get().Get("/locked", func(c *fiber.Ctx) error {
+		return errTestOrderLocked.WithError(fmt.Errorf("row lock held by tx 42"))
+	})
+
+	get := func(path string) (int, map[string]interface{}) {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		assert.NoError(t, err)
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	_, body := get("/db")
+	assert.Contains(t, body["message"], "password authentication failed", "development shows the error chain")
+
+	t.Setenv("ENVIRONMENT", "production")
+
+	status, body := get("/db")
+	assert.Equal(t, 500, status)
+	assert.Equal(t, "internal server error", body["message"])
+	assert.Equal(t, "INTERNAL_ERROR", body["code"])
+
+	status, body = get("/locked")
+	assert.Equal(t, 409, status)
+	assert.Equal(t, "order is locked", body["message"])
+	assert.Equal(t, "TEST_ORDER_LOCKED", body["code"])
+}
+
+func TestWithDetailDoesNotMutateSentinels(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			appErr := ValidationFailed(defaultValidator.Struct(validationAddress{}))
+			assert.Len(t, appErr.Details["errors"], 1)
+		}()
+	}
+	wg.Wait()
+
+	ErrBadRequest.WithDetail("cursor", "bad")
+	assert.Empty(t, ErrBadRequest.Details)
+	assert.Empty(t, ErrValidationFailed.Details)
+}
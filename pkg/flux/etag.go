@@ -0,0 +1,244 @@
+package flux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrPreconditionFailed   = DefineError("PRECONDITION_FAILED", http.StatusPreconditionFailed, "the resource was modified since it was read")
+	ErrPreconditionRequired = DefineError("PRECONDITION_REQUIRED", http.StatusPreconditionRequired, "If-Match header is required")
+)
+
+type ETagConfig struct {
+	// Weak marks generated ETags as weak (W/"..."), for bodies that may
+	// differ byte-wise while meaning the same, e.g. after compression.
+	Weak bool
+	// Next skips the middleware when it returns true.
+	Next func(c *fiber.Ctx) bool
+}
+
+func DefaultETagConfig() ETagConfig {
+	return ETagConfig{}
+}
+
+// ETag adds an ETag hashed from the body to successful GET and HEAD
+// responses that do not set one, and turns them into 304 Not Modified when
+// it matches If-None-Match.
+//
+//	app.Use(flux.ETag())
+func ETag(config ...ETagConfig) fiber.Handler {
+	cfg := DefaultETagConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		method := c.Method()
+		resp := c.Response()
+		if (method != fiber.MethodGet && method != fiber.MethodHead) || resp.StatusCode() != fiber.StatusOK || resp.IsBodyStream() {
+			return nil
+		}
+
+		if len(resp.Header.Peek(fiber.HeaderETag)) == 0 {
+			sum := sha256.Sum256(resp.Body())
+			setETag(c, hex.EncodeToString(sum[:16]), cfg.Weak)
+		}
+		notModified(c)
+		return nil
+	}
+}
+
+func setETag(c *fiber.Ctx, value string, weak bool) {
+	tag := strconv.Quote(value)
+	if weak {
+		tag = "W/" + tag
+	}
+	c.Set(fiber.HeaderETag, tag)
+}
+
+func notModified(c *fiber.Ctx) bool {
+	if !c.Fresh() {
+		return false
+	}
+	c.Status(fiber.StatusNotModified)
+	c.Response().ResetBody()
+	return true
+}
+
+// SetETag sets the ETag response header, quoting value.
+func (c *Context) SetETag(value string, weak bool) {
+	setETag(c.Ctx, value, weak)
+}
+
+// NotModified reports whether the client's copy is current according to the
+// ETag and Last-Modified headers already set, and if so responds 304. Call
+// it before doing expensive work:
+//
+//	ctx.SetETag(flux.ModelETag(post), false)
+//	if ctx.NotModified() {
+//		return nil
+//	}
+func (c *Context) NotModified() bool {
+	method := c.Method()
+	if method != fiber.MethodGet && method != fiber.MethodHead {
+		return false
+	}
+	return notModified(c.Ctx)
+}
+
+// CheckPreconditions compares If-Match, or else If-Unmodified-Since, with
+// the model's version and update time, and returns ErrPreconditionFailed
+// when the client's copy is stale. Requests without either header pass.
+func (c *Context) CheckPreconditions(model interface{}) error {
+	if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" {
+		if ifMatch == "*" {
+			return nil
+		}
+		current := ModelETag(model)
+		for _, tag := range strings.Split(ifMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			// If-Match uses the strong comparison, so weak tags never match.
+			if current != "" && !strings.HasPrefix(tag, "W/") && tag == current {
+				return nil
+			}
+		}
+		return ErrPreconditionFailed
+	}
+
+	if since := c.Get(fiber.HeaderIfUnmodifiedSince); since != "" {
+		t, err := http.ParseTime(since)
+		if err != nil {
+			return nil
+		}
+		if updated, ok := modelUpdatedAt(model); ok && updated.Truncate(time.Second).After(t) {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// ModelETag returns the quoted strong ETag of a model, derived from its
+// Version field or else its UpdatedAt field, or "" when it has neither.
+// An UpdatedAt-based ETag must come from the stored row: reload the model
+// after saving it, since the database may truncate the timestamp.
+func ModelETag(model interface{}) string {
+	if version, ok := modelVersion(model); ok {
+		return strconv.Quote("v" + strconv.FormatInt(version, 10))
+	}
+	if updated, ok := modelUpdatedAt(model); ok {
+		return strconv.Quote(strconv.FormatInt(updated.UnixNano(), 36))
+	}
+	return ""
+}
+
+func modelField(model interface{}, name string) (reflect.Value, bool) {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	f := v.FieldByName(name)
+	return f, f.IsValid()
+}
+
+func modelVersion(model interface{}) (int64, bool) {
+	f, ok := modelField(model, "Version")
+	if !ok {
+		return 0, false
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(f.Uint()), true
+	}
+	return 0, false
+}
+
+func modelUpdatedAt(model interface{}) (time.Time, bool) {
+	f, ok := modelField(model, "UpdatedAt")
+	if !ok {
+		return time.Time{}, false
+	}
+	updated, ok := f.Interface().(time.Time)
+	return updated, ok && !updated.IsZero()
+}
+
+// UpdateVersioned saves columns of model (every column when none are given)
+// only if the row still has the Version the model was read with, and
+// increments it. It returns ErrPreconditionFailed when another write got
+// there first.
+func (d *Database) UpdateVersioned(ctx context.Context, model interface{}, columns ...string) error {
+	s, err := parseModel(model, d.DB.NamingStrategy)
+	if err != nil {
+		return err
+	}
+	field := s.LookUpField("Version")
+	if field == nil {
+		return fmt.Errorf("model %s has no Version field", s.Name)
+	}
+
+	current, _ := modelVersion(model)
+	rv := reflect.ValueOf(model)
+	if err := field.Set(ctx, rv, current+1); err != nil {
+		return fmt.Errorf("failed to set version: %w", err)
+	}
+
+	result := d.DB.WithContext(ctx).Model(model).
+		Where(field.DBName+" = ?", current).
+		Select(versionedColumns(s, field, columns)).
+		Updates(model)
+	if result.Error == nil && result.RowsAffected == 1 {
+		return nil
+	}
+
+	_ = field.Set(ctx, rv, current)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update %s: %w", s.Name, result.Error)
+	}
+	return ErrPreconditionFailed
+}
+
+func versionedColumns(s *schema.Schema, version *schema.Field, columns []string) []string {
+	if len(columns) == 0 {
+		return []string{"*"}
+	}
+	out := append([]string{version.DBName}, columns...)
+	for _, f := range s.Fields {
+		if f.AutoUpdateTime > 0 && !containsString(out, f.DBName) {
+			out = append(out, f.DBName)
+		}
+	}
+	return out
+}
+
+// deleteVersioned deletes model only if its version is unchanged.
+func deleteVersioned(db *gorm.DB, model interface{}, versionColumn string) error {
+	current, _ := modelVersion(model)
+	result := db.Where(versionColumn+" = ?", current).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
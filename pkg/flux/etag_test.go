@@ -0,0 +1,166 @@
+package flux
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+)
+
+type etagDocument struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	Title   string `json:"title"`
+	Version int    `json:"version"`
+}
+
+// secondsSerializer stores times with second precision, like a DATETIME
+// column without fractional seconds.
+type secondsSerializer struct{}
+
+func (secondsSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var t sql.NullTime
+	if err := t.Scan(dbValue); err != nil || !t.Valid {
+		return err
+	}
+	return field.Set(ctx, dst, t.Time)
+}
+
+func (secondsSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	return fieldValue.(time.Time).Truncate(time.Second), nil
+}
+
+func init() {
+	schema.RegisterSerializer("etag_seconds", secondsSerializer{})
+}
+
+type etagNote struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `gorm:"serializer:etag_seconds" json:"updated_at"`
+}
+
+func etagRequest(t *testing.T, app *Application, method, url, body string, headers map[string]string) (int, string, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.server.Test(req)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get("ETag"), string(data)
+}
+
+func TestETagMiddleware(t *testing.T) {
+	app := newTestApplication()
+	app.server.Use(ETag())
+	app.server.Get("/report", func(c *fiber.Ctx) error {
+		return NewContext(c, app).JSON(H{"total": 42})
+	})
+	app.server.Post("/report", func(c *fiber.Ctx) error {
+		return c.SendString("created")
+	})
+
+	status, etag, body := etagRequest(t, app, "GET", "/report", "", nil)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, `{"total":42}`, body)
+	require.NotEmpty(t, etag)
+
+	status, _, body = etagRequest(t, app, "GET", "/report", "", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 304, status)
+	assert.Empty(t, body)
+
+	_, etag, _ = etagRequest(t, app, "POST", "/report", "", nil)
+	assert.Empty(t, etag)
+}
+
+func TestCRUDConditionalRequests(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &etagDocument{})
+	require.NoError(t, app.CRUD("/docs", &etagDocument{}, CRUDOptions{RequireIfMatch: true}))
+	require.NoError(t, app.database.Create(&etagDocument{Title: "draft"}))
+
+	status, etag, _ := etagRequest(t, app, "GET", "/docs/1", "", nil)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, `"v0"`, etag)
+
+	status, _, _ = etagRequest(t, app, "GET", "/docs/1", "", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 304, status)
+
+	status, _, _ = etagRequest(t, app, "PATCH", "/docs/1", `{"title":"final"}`, nil)
+	assert.Equal(t, 428, status)
+
+	status, newTag, body := etagRequest(t, app, "PATCH", "/docs/1", `{"title":"final"}`, map[string]string{"If-Match": etag})
+	assert.Equal(t, 200, status)
+	assert.Equal(t, `"v1"`, newTag)
+	assert.Contains(t, body, `"version":1`)
+
+	// A client still holding v0 cannot overwrite the change.
+	status, _, _ = etagRequest(t, app, "PATCH", "/docs/1", `{"title":"lost"}`, map[string]string{"If-Match": etag})
+	assert.Equal(t, 412, status)
+	status, _, _ = etagRequest(t, app, "DELETE", "/docs/1", "", map[string]string{"If-Match": etag})
+	assert.Equal(t, 412, status)
+
+	// The version column is not writable.
+	status, _, _ = etagRequest(t, app, "PATCH", "/docs/1", `{"version":7}`, map[string]string{"If-Match": newTag})
+	assert.Equal(t, 400, status)
+
+	status, _, _ = etagRequest(t, app, "DELETE", "/docs/1", "", map[string]string{"If-Match": newTag})
+	assert.Equal(t, 204, status)
+}
+
+func TestUpdateVersionedDetectsLostUpdates(t *testing.T) {
+	db := newTestDatabase(t, &etagDocument{})
+	require.NoError(t, db.Create(&etagDocument{Title: "a"}))
+
+	var first, second etagDocument
+	require.NoError(t, db.First(&first, 1))
+	require.NoError(t, db.First(&second, 1))
+
+	first.Title = "b"
+	require.NoError(t, db.UpdateVersioned(context.Background(), &first, "title"))
+	assert.Equal(t, 1, first.Version)
+
+	second.Title = "c"
+	err := db.UpdateVersioned(context.Background(), &second, "title")
+	assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	assert.Equal(t, 0, second.Version)
+
+	var stored etagDocument
+	require.NoError(t, db.First(&stored, 1))
+	assert.Equal(t, "b", stored.Title)
+	assert.Equal(t, 1, stored.Version)
+}
+
+func TestCRUDTimestampETagRoundTrip(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &etagNote{})
+	require.NoError(t, app.CRUD("/notes", &etagNote{}, CRUDOptions{}))
+
+	status, etag, _ := etagRequest(t, app, "POST", "/notes", `{"body":"a"}`, nil)
+	require.Equal(t, 201, status)
+
+	status, getTag, _ := etagRequest(t, app, "GET", "/notes/1", "", nil)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, etag, getTag)
+
+	status, etag, _ = etagRequest(t, app, "PATCH", "/notes/1", `{"body":"b"}`, map[string]string{"If-Match": etag})
+	require.Equal(t, 200, status)
+
+	// The ETag from the PATCH response matches what is stored.
+	status, _, _ = etagRequest(t, app, "PATCH", "/notes/1", `{"body":"c"}`, map[string]string{"If-Match": etag})
+	assert.Equal(t, 200, status)
+}
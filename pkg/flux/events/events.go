@@ -0,0 +1,311 @@
+// Package events dispatches domain events to listeners registered by event
+// type, so code that emits UserRegistered does not need to know who reacts.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/Fluxgo/flux/pkg/flux/queue"
+)
+
+// Named lets an event type choose its name. Other events are named after
+// their type, e.g. "UserRegistered". Names must stay stable while queued
+// listeners have jobs pending.
+type Named interface {
+	EventName() string
+}
+
+// ErrorPolicy decides what a listener error does to the rest of a dispatch.
+type ErrorPolicy int
+
+const (
+	// Stop skips the remaining listeners and returns the error.
+	Stop ErrorPolicy = iota
+	// Continue runs the remaining listeners and returns all errors joined.
+	Continue
+	// Ignore logs the error and carries on as if the listener succeeded.
+	Ignore
+)
+
+// Queue is the part of queue.Queue used for queued listeners.
+type Queue interface {
+	RegisterHandler(jobType string, handler queue.Handler)
+	Enqueue(jobType string, data map[string]interface{}, maxRetries int) (*queue.Job, error)
+}
+
+type Logger interface {
+	Error(format string, args ...interface{})
+}
+
+type Config struct {
+	// Queue runs queued listeners. Without it they run in a goroutine.
+	Queue Queue
+	// ErrorPolicy applies to listeners registered without OnError.
+	ErrorPolicy ErrorPolicy
+	// MaxRetries is how often a failed queued listener is retried.
+	MaxRetries int
+	Logger     Logger
+}
+
+func DefaultConfig() Config {
+	return Config{ErrorPolicy: Stop, MaxRetries: 3}
+}
+
+type Dispatcher struct {
+	config    Config
+	mu        sync.RWMutex
+	listeners map[string][]*listener
+	seq       int
+	fake      bool
+	recorded  []interface{}
+}
+
+type listener struct {
+	name     string
+	priority int
+	seq      int
+	queued   bool
+	policy   *ErrorPolicy
+	call     func(ctx context.Context, event interface{}) error
+	decode   func(data []byte) (interface{}, error)
+}
+
+func New(config Config) *Dispatcher {
+	return &Dispatcher{config: config, listeners: make(map[string][]*listener)}
+}
+
+type ListenOption func(*listener)
+
+// Priority runs higher priority listeners first. Listeners with equal
+// priority run in the order they were registered.
+func Priority(priority int) ListenOption {
+	return func(l *listener) { l.priority = priority }
+}
+
+// Queued runs the listener after the dispatch returns, through the queue.
+func Queued() ListenOption {
+	return func(l *listener) { l.queued = true }
+}
+
+// OnError overrides the dispatcher's error policy for the listener.
+func OnError(policy ErrorPolicy) ListenOption {
+	return func(l *listener) { l.policy = &policy }
+}
+
+// As names the listener. Queued listeners are found by name when their
+// job runs, so name them when the function name may change.
+func As(name string) ListenOption {
+	return func(l *listener) { l.name = name }
+}
+
+// Listen registers fn for events of type E. E may be the event type or a
+// pointer to it. Queued listeners of an event need distinct names; closures
+// made by the same function share one, so registering a second one panics
+// unless they are told apart with As.
+func Listen[E any](d *Dispatcher, fn func(ctx context.Context, event E) error, opts ...ListenOption) {
+	l := &listener{
+		name: runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name(),
+		call: func(ctx context.Context, event interface{}) error {
+			e, ok := convert[E](event)
+			if !ok {
+				return fmt.Errorf("events: cannot deliver %T to a listener for %T", event, *new(E))
+			}
+			return fn(ctx, e)
+		},
+		decode: func(data []byte) (interface{}, error) {
+			var e E
+			if err := json.Unmarshal(data, &e); err != nil {
+				return nil, err
+			}
+			return e, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	name := nameOfType(reflect.TypeOf((*E)(nil)).Elem())
+
+	d.mu.Lock()
+	if l.queued {
+		for _, other := range d.listeners[name] {
+			if other.queued && other.name == l.name {
+				d.mu.Unlock()
+				panic(fmt.Sprintf("events: queued listener %s for %s is already registered; name it with As", l.name, name))
+			}
+		}
+	}
+	d.seq++
+	l.seq = d.seq
+	list := append(d.listeners[name], l)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].priority != list[j].priority {
+			return list[i].priority > list[j].priority
+		}
+		return list[i].seq < list[j].seq
+	})
+	d.listeners[name] = list
+	d.mu.Unlock()
+
+	if l.queued && d.config.Queue != nil {
+		d.config.Queue.RegisterHandler(jobType(name, l.name), func(job *queue.Job) error {
+			data, _ := job.Data["event"].(string)
+			event, err := l.decode([]byte(data))
+			if err != nil {
+				return fmt.Errorf("failed to decode %s: %w", name, err)
+			}
+			return d.run(context.Background(), l, event)
+		})
+	}
+}
+
+func jobType(event, listener string) string {
+	return "events:" + event + ":" + listener
+}
+
+// Dispatch delivers event to its listeners. See DispatchContext.
+func (d *Dispatcher) Dispatch(event interface{}) error {
+	return d.DispatchContext(context.Background(), event)
+}
+
+// DispatchContext runs the synchronous listeners of event in order, then
+// hands the queued ones to the queue. Queued listeners are skipped when a
+// synchronous listener stops the dispatch.
+func (d *Dispatcher) DispatchContext(ctx context.Context, event interface{}) error {
+	if event == nil {
+		return errors.New("events: cannot dispatch nil")
+	}
+
+	d.mu.Lock()
+	if d.fake {
+		d.recorded = append(d.recorded, event)
+		d.mu.Unlock()
+		return nil
+	}
+	name := Name(event)
+	listeners := append([]*listener(nil), d.listeners[name]...)
+	d.mu.Unlock()
+
+	var errs []error
+	var queued []*listener
+	for _, l := range listeners {
+		if l.queued {
+			queued = append(queued, l)
+			continue
+		}
+		if err := d.run(ctx, l, event); err != nil {
+			switch d.policy(l) {
+			case Ignore:
+				d.logError("Listener %s for %s failed: %v", l.name, name, err)
+			case Continue:
+				errs = append(errs, err)
+			default:
+				return errors.Join(append(errs, err)...)
+			}
+		}
+	}
+
+	for _, l := range queued {
+		if err := d.enqueue(name, l, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) policy(l *listener) ErrorPolicy {
+	if l.policy != nil {
+		return *l.policy
+	}
+	return d.config.ErrorPolicy
+}
+
+func (d *Dispatcher) enqueue(name string, l *listener, event interface{}) error {
+	if d.config.Queue == nil {
+		go func() {
+			if err := d.run(context.Background(), l, event); err != nil {
+				d.logError("Listener %s for %s failed: %v", l.name, name, err)
+			}
+		}()
+		return nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	payload := map[string]interface{}{"event": string(data)}
+	if _, err := d.config.Queue.Enqueue(jobType(name, l.name), payload, d.config.MaxRetries); err != nil {
+		return fmt.Errorf("failed to queue listener %s: %w", l.name, err)
+	}
+	return nil
+}
+
+// run calls the listener, turning a panic into an error.
+func (d *Dispatcher) run(ctx context.Context, l *listener, event interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("listener %s panicked: %v", l.name, r)
+		}
+	}()
+	return l.call(ctx, event)
+}
+
+func (d *Dispatcher) logError(format string, args ...interface{}) {
+	if d.config.Logger != nil {
+		d.config.Logger.Error(format, args...)
+	}
+}
+
+// HasListeners reports whether any listener is registered for event.
+func (d *Dispatcher) HasListeners(event interface{}) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.listeners[Name(event)]) > 0
+}
+
+// Name returns the name listeners are registered under for event.
+func Name(event interface{}) string {
+	return nameOfType(reflect.TypeOf(event))
+}
+
+func nameOfType(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// EventName is called on a zero value; it must not depend on fields.
+	if reflect.PointerTo(t).Implements(namedType) {
+		return reflect.New(t).Interface().(Named).EventName()
+	}
+	return t.Name()
+}
+
+var namedType = reflect.TypeOf((*Named)(nil)).Elem()
+
+// convert delivers events dispatched as values to pointer listeners and the
+// other way round.
+func convert[E any](event interface{}) (E, bool) {
+	if e, ok := event.(E); ok {
+		return e, true
+	}
+
+	var zero E
+	target := reflect.TypeOf(&zero).Elem()
+	v := reflect.ValueOf(event)
+	switch {
+	case v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Type() == target:
+		return v.Elem().Interface().(E), true
+	case target.Kind() == reflect.Ptr && target.Elem() == v.Type():
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface().(E), true
+	}
+	return zero, false
+}
@@ -0,0 +1,197 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type UserRegistered struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type orderPlaced struct{ ID int }
+
+func (orderPlaced) EventName() string { return "orders.placed" }
+
+func TestDispatchOrderAndPointers(t *testing.T) {
+	d := New(DefaultConfig())
+	var calls []string
+
+	Listen(d, func(ctx context.Context, e UserRegistered) error {
+		calls = append(calls, "audit")
+		return nil
+	})
+	Listen(d, func(ctx context.Context, e *UserRegistered) error {
+		calls = append(calls, "mail:"+e.Email)
+		return nil
+	}, Priority(10))
+	Listen(d, func(ctx context.Context, e orderPlaced) error {
+		calls = append(calls, fmt.Sprint("order:", e.ID))
+		return nil
+	})
+
+	require.NoError(t, d.Dispatch(UserRegistered{UserID: 1, Email: "a@example.com"}))
+	require.NoError(t, d.Dispatch(&orderPlaced{ID: 7}))
+	assert.Equal(t, []string{"mail:a@example.com", "audit", "order:7"}, calls)
+	assert.Equal(t, "orders.placed", Name(orderPlaced{}))
+	assert.False(t, d.HasListeners(struct{}{}))
+}
+
+func TestErrorPolicies(t *testing.T) {
+	boom := errors.New("boom")
+	var ran []string
+	listener := func(name string, err error) func(context.Context, UserRegistered) error {
+		return func(context.Context, UserRegistered) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+
+	d := New(DefaultConfig())
+	Listen(d, listener("first", boom))
+	Listen(d, listener("second", nil))
+	err := d.Dispatch(UserRegistered{})
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, []string{"first"}, ran)
+
+	ran = nil
+	d = New(Config{ErrorPolicy: Continue})
+	Listen(d, listener("first", boom))
+	Listen(d, listener("ignored", errors.New("ignored")), OnError(Ignore))
+	Listen(d, func(context.Context, UserRegistered) error { panic("oops") })
+	Listen(d, listener("last", nil))
+	err = d.Dispatch(UserRegistered{})
+	assert.ErrorIs(t, err, boom)
+	assert.ErrorContains(t, err, "panicked: oops")
+	assert.NotContains(t, err.Error(), "ignored")
+	assert.Equal(t, []string{"first", "ignored", "last"}, ran)
+}
+
+type memoryQueue struct {
+	mu       sync.Mutex
+	handlers map[string]queue.Handler
+	jobs     []*queue.Job
+}
+
+func (q *memoryQueue) RegisterHandler(jobType string, handler queue.Handler) {
+	q.handlers[jobType] = handler
+}
+
+func (q *memoryQueue) Enqueue(jobType string, data map[string]interface{}, maxRetries int) (*queue.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := &queue.Job{Type: jobType, Data: data, MaxRetries: maxRetries}
+	q.jobs = append(q.jobs, job)
+	return job, nil
+}
+
+func TestQueuedListeners(t *testing.T) {
+	q := &memoryQueue{handlers: make(map[string]queue.Handler)}
+	d := New(Config{Queue: q, MaxRetries: 5})
+
+	received := make(chan UserRegistered, 1)
+	Listen(d, func(ctx context.Context, e UserRegistered) error {
+		received <- e
+		return nil
+	}, Queued(), As("welcome-mail"))
+	Listen(d, func(ctx context.Context, e UserRegistered) error { return errors.New("stop") })
+
+	// A failing synchronous listener keeps queued ones from being queued.
+	assert.Error(t, d.Dispatch(UserRegistered{UserID: 1}))
+	assert.Empty(t, q.jobs)
+
+	d = New(Config{Queue: q, MaxRetries: 5})
+	Listen(d, func(ctx context.Context, e UserRegistered) error {
+		received <- e
+		return nil
+	}, Queued(), As("welcome-mail"))
+	require.NoError(t, d.Dispatch(UserRegistered{UserID: 2, Email: "b@example.com"}))
+	require.Len(t, q.jobs, 1)
+	assert.Equal(t, "events:UserRegistered:welcome-mail", q.jobs[0].Type)
+	assert.Equal(t, 5, q.jobs[0].MaxRetries)
+	assert.Empty(t, received)
+
+	require.NoError(t, q.handlers[q.jobs[0].Type](q.jobs[0]))
+	assert.Equal(t, UserRegistered{UserID: 2, Email: "b@example.com"}, <-received)
+
+	// Without a queue, queued listeners run in the background.
+	d = New(DefaultConfig())
+	Listen(d, func(ctx context.Context, e UserRegistered) error {
+		received <- e
+		return nil
+	}, Queued())
+	require.NoError(t, d.Dispatch(UserRegistered{UserID: 3}))
+	select {
+	case e := <-received:
+		assert.Equal(t, 3, e.UserID)
+	case <-time.After(time.Second):
+		t.Fatal("background listener did not run")
+	}
+}
+
+func TestQueuedListenersNeedDistinctNames(t *testing.T) {
+	q := &memoryQueue{handlers: make(map[string]queue.Handler)}
+	d := New(Config{Queue: q})
+
+	var mu sync.Mutex
+	var ran []string
+	record := func(name string) func(ctx context.Context, e UserRegistered) error {
+		return func(ctx context.Context, e UserRegistered) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+			return nil
+		}
+	}
+
+	// Closures from one factory share a function name, so the second
+	// would take over the first one's job type.
+	assert.Panics(t, func() {
+		for _, name := range []string{"mail", "audit"} {
+			Listen(d, record(name), Queued())
+		}
+	})
+
+	Listen(d, record("audit"), Queued(), As("audit"))
+	require.NoError(t, d.Dispatch(UserRegistered{UserID: 1}))
+	require.Len(t, q.jobs, 2)
+	for _, job := range q.jobs {
+		require.NoError(t, q.handlers[job.Type](job))
+	}
+	assert.ElementsMatch(t, []string{"mail", "audit"}, ran)
+}
+
+type recordingT struct{ errors []string }
+
+func (r *recordingT) Helper() {}
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFake(t *testing.T) {
+	fake := NewFake()
+	Listen(fake, func(context.Context, UserRegistered) error { return errors.New("must not run") })
+
+	require.NoError(t, fake.Dispatch(UserRegistered{UserID: 1, Email: "a@example.com"}))
+	require.NoError(t, fake.Dispatch(&UserRegistered{UserID: 2}))
+
+	AssertDispatched[UserRegistered](t, fake)
+	AssertDispatched(t, fake, func(e UserRegistered) bool { return e.Email == "a@example.com" })
+	AssertDispatchedTimes[UserRegistered](t, fake, 2)
+	AssertNotDispatched[orderPlaced](t, fake)
+	assert.Len(t, Dispatched[*UserRegistered](fake), 2)
+
+	rt := &recordingT{}
+	AssertDispatched(rt, fake, func(e UserRegistered) bool { return e.UserID == 9 })
+	AssertNotDispatched[UserRegistered](rt, fake)
+	assert.Len(t, rt.errors, 2)
+}
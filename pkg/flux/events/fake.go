@@ -0,0 +1,89 @@
+package events
+
+import "fmt"
+
+// NewFake returns a dispatcher that records events instead of running
+// listeners, for asserting what code under test dispatched.
+//
+//	fake := events.NewFake()
+//	app.SetEvents(fake)
+//	...
+//	events.AssertDispatched(t, fake, func(e UserRegistered) bool { return e.Email == "a@b.c" })
+func NewFake() *Dispatcher {
+	d := New(DefaultConfig())
+	d.fake = true
+	return d
+}
+
+// Dispatched returns the recorded events of type E in dispatch order.
+func Dispatched[E any](d *Dispatcher) []E {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var out []E
+	for _, event := range d.recorded {
+		if e, ok := convert[E](event); ok {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// TestingT is the part of testing.TB the assertions use.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertDispatched fails unless an event of type E matching all of match
+// was dispatched.
+func AssertDispatched[E any](t TestingT, d *Dispatcher, match ...func(E) bool) {
+	t.Helper()
+	if countMatching(d, match) == 0 {
+		t.Errorf("expected %s to be dispatched%s", typeName[E](), matchSuffix(match))
+	}
+}
+
+// AssertDispatchedTimes fails unless exactly n matching events of type E
+// were dispatched.
+func AssertDispatchedTimes[E any](t TestingT, d *Dispatcher, n int, match ...func(E) bool) {
+	t.Helper()
+	if got := countMatching(d, match); got != n {
+		t.Errorf("expected %s to be dispatched %d times%s, got %d", typeName[E](), n, matchSuffix(match), got)
+	}
+}
+
+func AssertNotDispatched[E any](t TestingT, d *Dispatcher, match ...func(E) bool) {
+	t.Helper()
+	if got := countMatching(d, match); got > 0 {
+		t.Errorf("expected %s not to be dispatched%s, got %d", typeName[E](), matchSuffix(match), got)
+	}
+}
+
+func countMatching[E any](d *Dispatcher, match []func(E) bool) int {
+	count := 0
+	for _, e := range Dispatched[E](d) {
+		ok := true
+		for _, m := range match {
+			if !m(e) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			count++
+		}
+	}
+	return count
+}
+
+func typeName[E any]() string {
+	return fmt.Sprintf("%T", *new(E))
+}
+
+func matchSuffix[E any](match []func(E) bool) string {
+	if len(match) > 0 {
+		return " with a matching payload"
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package flux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ServeSignedFiles serves objects from the application storage under prefix,
+// but only through URLs created with SignedURL (or storage.SignedURL) that
+// have not expired. Byte ranges are supported so large files and media can be
+// resumed and seeked.
+func (app *Application) ServeSignedFiles(prefix string) error {
+	if app.config.Key == "" {
+		return fmt.Errorf("signed file URLs require Config.Key")
+	}
+
+	prefix = "/" + strings.Trim(prefix, "/")
+	base := strings.TrimSuffix(app.config.Server.BasePath, "/") + prefix
+	app.signer = storage.NewSigner([]byte(app.config.Key), base)
+	storage.SetDefaultSigner(app.signer)
+
+	app.server.Get(prefix+"/*", func(c *fiber.Ctx) error {
+		key, err := url.PathUnescape(c.Params("*"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		err = app.signer.Verify(key, c.Query("expires"), c.Query("signature"))
+		if errors.Is(err, storage.ErrExpiredSignature) {
+			return NewAppError("link expired", fiber.StatusForbidden)
+		}
+		if err != nil {
+			return ErrForbidden
+		}
+
+		if app.storage == nil {
+			return ErrInternalError.WithError(fmt.Errorf("storage is not configured"))
+		}
+		return NewContext(c, app).SendObject(key)
+	})
+	return nil
+}
+
+// SignedURL returns a download URL for key that expires after ttl.
+// ServeSignedFiles must have been called first.
+func (app *Application) SignedURL(key string, ttl time.Duration) (string, error) {
+	if app.signer == nil {
+		return "", fmt.Errorf("signed file URLs are not enabled, call ServeSignedFiles")
+	}
+	return app.signer.SignedURL(key, ttl)
+}
+
+// SendObject streams key from the application storage, honouring a single
+// Range header.
+func (c *Context) SendObject(key string) error {
+	if c.app == nil || c.app.storage == nil {
+		return ErrInternalError.WithError(fmt.Errorf("storage is not configured"))
+	}
+
+	r, obj, err := c.app.storage.Stream(c.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Ctx.Set(fiber.HeaderContentType, obj.ContentType)
+	c.Ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+	if !obj.ModTime.IsZero() {
+		c.Ctx.Set(fiber.HeaderLastModified, obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	header := c.Ctx.Get(fiber.HeaderRange)
+	if header == "" || obj.Size < 0 {
+		return c.Ctx.SendStream(r, int(obj.Size))
+	}
+
+	start, end, ok := parseByteRange(header, obj.Size)
+	if !ok {
+		r.Close()
+		c.Ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", obj.Size))
+		return c.Ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+
+	if seeker, ok := r.(io.Seeker); ok {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			r.Close()
+			return err
+		}
+	} else if _, err := io.CopyN(io.Discard, r, start); err != nil {
+		r.Close()
+		return err
+	}
+
+	length := end - start + 1
+	c.Ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, obj.Size))
+	c.Ctx.Status(fiber.StatusPartialContent)
+	return c.Ctx.SendStream(struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, length), r}, int(length))
+}
+
+// parseByteRange parses a single "bytes=" range against size. Multiple
+// ranges are not supported and are reported as unsatisfiable.
+func parseByteRange(header string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") || size == 0 {
+		return 0, 0, false
+	}
+
+	from, to, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if to != "" {
+		end, err = strconv.ParseInt(to, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
@@ -0,0 +1,61 @@
+package flux
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeSignedFiles(t *testing.T) {
+	app := newTestApplication()
+	app.config.Key = "test-key"
+	disk := storage.NewMemory()
+	app.SetStorage(disk)
+	assert.NoError(t, disk.Put(context.Background(), "exports/data.txt", strings.NewReader("0123456789"), "text/plain"))
+	assert.NoError(t, app.ServeSignedFiles("/files"))
+
+	signed, err := app.SignedURL("exports/data.txt", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "/files/exports/data.txt?"))
+
+	resp, err := app.Test(httptest.NewRequest("GET", signed, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	assert.True(t, strings.HasSuffix(resp.Header.Get("Last-Modified"), " GMT"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "0123456789", string(body))
+
+	ranges := map[string]string{"bytes=2-4": "234", "bytes=7-": "789", "bytes=-2": "89"}
+	for header, want := range ranges {
+		req := httptest.NewRequest("GET", signed, nil)
+		req.Header.Set("Range", header)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 206, resp.StatusCode, header)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, want, string(body), header)
+	}
+
+	req := httptest.NewRequest("GET", signed, nil)
+	req.Header.Set("Range", "bytes=20-")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 416, resp.StatusCode)
+	assert.Equal(t, "bytes */10", resp.Header.Get("Content-Range"))
+
+	tampered := strings.Replace(signed, "data.txt", "other.txt", 1)
+	resp, err = app.Test(httptest.NewRequest("GET", tampered, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/files/exports/data.txt", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+}
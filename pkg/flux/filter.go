@@ -0,0 +1,285 @@
+package flux
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type FilterOperator string
+
+const (
+	OpEq   FilterOperator = "eq"
+	OpNe   FilterOperator = "ne"
+	OpGt   FilterOperator = "gt"
+	OpGte  FilterOperator = "gte"
+	OpLt   FilterOperator = "lt"
+	OpLte  FilterOperator = "lte"
+	OpLike FilterOperator = "like"
+	OpIn   FilterOperator = "in"
+	OpNull FilterOperator = "null"
+)
+
+// ErrInvalidQuery is returned for filters or sorts that are not allowed or
+// whose values don't match the model field type.
+var ErrInvalidQuery = DefineError("INVALID_QUERY", http.StatusBadRequest, "invalid query")
+
+// QueryOptions whitelists what a list endpoint may filter and sort on. Fields
+// are named by their JSON name or database column.
+type QueryOptions struct {
+	// Filters maps a field to the operators allowed on it.
+	Filters map[string][]FilterOperator
+	// Sorts lists the fields that ?sort= may use.
+	Sorts []string
+	// DefaultSort applies when the request has no ?sort=, e.g. "-created_at".
+	DefaultSort string
+}
+
+type parsedFilter struct {
+	field *schema.Field
+	op    FilterOperator
+	value interface{}
+}
+
+// QueryScope turns ?filter[field][op]=value and ?sort=-field,field into a
+// GORM scope for model T. Only whitelisted fields and operators are accepted
+// and values are converted to the field's Go type, so neither column names
+// nor values ever reach the SQL as raw strings.
+//
+//	scope, err := flux.QueryScope[Product](ctx, flux.QueryOptions{
+//		Filters: map[string][]flux.FilterOperator{"status": {flux.OpEq, flux.OpIn}},
+//		Sorts:   []string{"created_at", "name"},
+//	})
+//	app.DB().Model(&Product{}).Scopes(scope).Find(&products)
+func QueryScope[T any](ctx *Context, opts QueryOptions) (func(*gorm.DB) *gorm.DB, error) {
+	return queryScope(ctx, new(T), opts)
+}
+
+func queryScope(ctx *Context, model interface{}, opts QueryOptions) (func(*gorm.DB) *gorm.DB, error) {
+	namer := schema.Namer(schema.NamingStrategy{})
+	if ctx.app != nil && ctx.app.database != nil {
+		namer = ctx.app.database.DB.NamingStrategy
+	}
+	s, err := parseModel(model, namer)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make(map[string]interface{})
+	var filters []parsedFilter
+	var orders []clause.OrderByColumn
+
+	ctx.Ctx.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		key, raw := string(k), string(v)
+		name, op, ok := parseFilterKey(key)
+		if !ok {
+			return
+		}
+
+		allowed, listed := opts.Filters[name]
+		field := lookupQueryField(s, name)
+		if !listed || field == nil {
+			problems[key] = "filtering on this field is not allowed"
+			return
+		}
+		if !containsOperator(allowed, op) {
+			problems[key] = fmt.Sprintf("operator %q is not allowed", op)
+			return
+		}
+
+		value, err := convertFilterValue(field, op, raw)
+		if err != nil {
+			problems[key] = err.Error()
+			return
+		}
+		filters = append(filters, parsedFilter{field: field, op: op, value: value})
+	})
+
+	sort := ctx.Ctx.Query("sort")
+	if sort == "" {
+		sort = opts.DefaultSort
+	}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name := strings.TrimPrefix(part, "-")
+		field := lookupQueryField(s, name)
+		if field == nil || !containsString(opts.Sorts, name) {
+			problems["sort"] = fmt.Sprintf("sorting on %q is not allowed", name)
+			continue
+		}
+		orders = append(orders, clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Desc:   strings.HasPrefix(part, "-"),
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, ErrInvalidQuery.WithDetails(problems)
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, f := range filters {
+			db = db.Where(filterExpression(f))
+		}
+		for _, order := range orders {
+			db = db.Order(order)
+		}
+		return db
+	}, nil
+}
+
+// parseFilterKey splits "filter[name]" and "filter[name][op]".
+func parseFilterKey(key string) (string, FilterOperator, bool) {
+	rest, ok := strings.CutPrefix(key, "filter[")
+	if !ok || !strings.HasSuffix(rest, "]") {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimSuffix(rest, "]"), "][")
+	switch len(parts) {
+	case 1:
+		return parts[0], OpEq, parts[0] != ""
+	case 2:
+		return parts[0], FilterOperator(parts[1]), parts[0] != ""
+	}
+	return "", "", false
+}
+
+func lookupQueryField(s *schema.Schema, name string) *schema.Field {
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonName == name || field.DBName == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func containsOperator(ops []FilterOperator, op FilterOperator) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// likeEscaper makes wildcards in a like filter match literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func convertFilterValue(field *schema.Field, op FilterOperator, raw string) (interface{}, error) {
+	switch op {
+	case OpNull:
+		return strconv.ParseBool(raw)
+	case OpLike:
+		if field.IndirectFieldType.Kind() != reflect.String {
+			return nil, fmt.Errorf("like only applies to text fields")
+		}
+		return "%" + likeEscaper.Replace(raw) + "%", nil
+	case OpIn:
+		var values []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			v, err := convertScalar(field.IndirectFieldType, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		return convertScalar(field.IndirectFieldType, raw)
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+func convertScalar(t reflect.Type, raw string) (interface{}, error) {
+	if t == timeType {
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if v, err := time.Parse(layout, raw); err == nil {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a date (YYYY-MM-DD) or RFC 3339 time")
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected a boolean")
+		}
+		return v, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("expected a non-negative integer")
+		}
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("filtering on this field type is not supported")
+}
+
+func filterExpression(f parsedFilter) clause.Expression {
+	col := clause.Column{Table: clause.CurrentTable, Name: f.field.DBName}
+	switch f.op {
+	case OpNe:
+		return clause.Neq{Column: col, Value: f.value}
+	case OpGt:
+		return clause.Gt{Column: col, Value: f.value}
+	case OpGte:
+		return clause.Gte{Column: col, Value: f.value}
+	case OpLt:
+		return clause.Lt{Column: col, Value: f.value}
+	case OpLte:
+		return clause.Lte{Column: col, Value: f.value}
+	case OpLike:
+		// The escape character is bound rather than written as a literal,
+		// since backslashes in string literals differ between databases.
+		return clause.Expr{SQL: "? LIKE ? ESCAPE ?", Vars: []interface{}{col, f.value, `\`}}
+	case OpIn:
+		return clause.IN{Column: col, Values: f.value.([]interface{})}
+	case OpNull:
+		if f.value.(bool) {
+			return clause.Eq{Column: col, Value: nil}
+		}
+		return clause.Neq{Column: col, Value: nil}
+	}
+	return clause.Eq{Column: col, Value: f.value}
+}
@@ -0,0 +1,89 @@
+package flux
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryScope(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &pageItem{})
+	seedPageItems(t, app.database, 12)
+
+	app.Get().Get("/items", func(c *fiber.Ctx) error {
+		ctx := NewContext(c, app)
+		scope, err := QueryScope[pageItem](ctx, QueryOptions{
+			Filters: map[string][]FilterOperator{
+				"status":     {OpEq, OpIn},
+				"name":       {OpLike},
+				"price":      {OpGte, OpLt},
+				"created_at": {OpGte},
+			},
+			Sorts:       []string{"price", "created_at"},
+			DefaultSort: "price",
+		})
+		if err != nil {
+			return err
+		}
+
+		var items []pageItem
+		if err := app.DB().Model(&pageItem{}).Scopes(scope).Find(&items).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		return ctx.JSON(ids)
+	})
+
+	get := func(query string) (int, []uint) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/items?"+query, nil))
+		assert.NoError(t, err)
+		var ids []uint
+		if resp.StatusCode == 200 {
+			json.NewDecoder(resp.Body).Decode(&ids)
+		}
+		return resp.StatusCode, ids
+	}
+
+	status, ids := get("filter[status]=archived&sort=-price")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []uint{12, 9, 6, 3}, ids)
+
+	status, ids = get(url.Values{
+		"filter[price][gte]":      {"4"},
+		"filter[price][lt]":       {"8"},
+		"filter[created_at][gte]": {"2024-01-05"},
+	}.Encode())
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []uint{5, 6, 7}, ids)
+
+	status, ids = get("filter[name][like]=item-1&filter[status][in]=active,archived")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []uint{10, 11, 12}, ids)
+
+	// Wildcards in the value match literally.
+	for _, value := range []string{"%", "item_1", `item\-1`} {
+		status, ids = get(url.Values{"filter[name][like]": {value}}.Encode())
+		assert.Equal(t, 200, status, value)
+		assert.Empty(t, ids, value)
+	}
+
+	for _, query := range []string{
+		"filter[id]=1",
+		"filter[status][gt]=a",
+		"filter[price][gte]=cheap",
+		"filter[name]=x",
+		"sort=name",
+		"filter[name)]=1;DROP TABLE page_items",
+	} {
+		status, _ := get(url.PathEscape(query))
+		assert.Equal(t, 400, status, query)
+	}
+}
@@ -0,0 +1,581 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/auth"
+	"github.com/Fluxgo/flux/pkg/flux/registry"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrBadGateway          = DefineError("BAD_GATEWAY", http.StatusBadGateway, "upstream service failed")
+	ErrUpstreamUnavailable = DefineError("UPSTREAM_UNAVAILABLE", http.StatusServiceUnavailable, "no healthy upstream available")
+	ErrRateLimited         = DefineError("RATE_LIMITED", http.StatusTooManyRequests, "rate limit exceeded")
+)
+
+const (
+	BalanceRoundRobin       = "round_robin"
+	BalanceLeastConnections = "least_connections"
+)
+
+// GatewayRoute sends requests under Prefix to a service. Upstreams are the
+// fixed Targets, or the instances the registry returns for Service.
+type GatewayRoute struct {
+	Prefix  string   `yaml:"prefix" json:"prefix"`
+	Service string   `yaml:"service" json:"service"`
+	Targets []string `yaml:"targets" json:"targets"`
+	// StripPrefix removes Prefix before forwarding: /orders/42 becomes /42.
+	StripPrefix bool `yaml:"strip_prefix" json:"strip_prefix"`
+	// Auth requires a valid JWT bearer token at the gateway.
+	Auth      bool             `yaml:"auth" json:"auth"`
+	RateLimit GatewayRateLimit `yaml:"rate_limit" json:"rate_limit"`
+	Timeout   time.Duration    `yaml:"timeout" json:"timeout"`
+}
+
+// GatewayRateLimit allows Max requests per client IP and Window. Zero Max
+// disables it.
+type GatewayRateLimit struct {
+	Max    int           `yaml:"max" json:"max"`
+	Window time.Duration `yaml:"window" json:"window"`
+}
+
+type GatewayHealthCheck struct {
+	Path     string        `yaml:"path" json:"path"`
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+	// UnhealthyAfter consecutive failed checks take an upstream out of rotation.
+	UnhealthyAfter int `yaml:"unhealthy_after" json:"unhealthy_after"`
+}
+
+type GatewayConfig struct {
+	Routes      []GatewayRoute     `yaml:"routes" json:"routes"`
+	Balancer    string             `yaml:"balancer" json:"balancer"`
+	HealthCheck GatewayHealthCheck `yaml:"health_check" json:"health_check"`
+	Timeout     time.Duration      `yaml:"timeout" json:"timeout"`
+	// OpenAPIPath serves the merged specs of all upstreams. UpstreamOpenAPIPath
+	// is where each upstream serves its own.
+	OpenAPIPath         string `yaml:"openapi_path" json:"openapi_path"`
+	UpstreamOpenAPIPath string `yaml:"upstream_openapi_path" json:"upstream_openapi_path"`
+	// Registry resolves routes that name a Service instead of Targets.
+	Registry registry.Registry `yaml:"-" json:"-"`
+	// JWT validates tokens for routes with Auth. Defaults to the app's.
+	JWT *auth.JWTManager `yaml:"-" json:"-"`
+}
+
+func DefaultGatewayConfig() GatewayConfig {
+	return GatewayConfig{
+		Balancer: BalanceRoundRobin,
+		HealthCheck: GatewayHealthCheck{
+			Path:           "/health",
+			Interval:       10 * time.Second,
+			Timeout:        2 * time.Second,
+			UnhealthyAfter: 2,
+		},
+		Timeout:             30 * time.Second,
+		OpenAPIPath:         "/openapi.json",
+		UpstreamOpenAPIPath: "/openapi.json",
+	}
+}
+
+// LoadGatewayConfig reads a route table from a YAML file on top of the
+// defaults:
+//
+//	balancer: least_connections
+//	routes:
+//	  - prefix: /orders
+//	    service: orders
+//	    auth: true
+//	    rate_limit: {max: 100, window: 1m}
+func LoadGatewayConfig(path string) (GatewayConfig, error) {
+	config := DefaultGatewayConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read gateway config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse gateway config: %w", err)
+	}
+	return config, nil
+}
+
+// Gateway reverse-proxies path prefixes to upstream services, so a flux
+// application can stand in front of several microservices.
+type Gateway struct {
+	app    *Application
+	config GatewayConfig
+	routes []*gatewayRoute
+	http   *http.Client
+	stop   chan struct{}
+	once   sync.Once
+}
+
+type gatewayRoute struct {
+	GatewayRoute
+	mu        sync.RWMutex
+	upstreams []*upstream
+	next      atomic.Uint64
+	limiter   *gatewayLimiter
+}
+
+type upstream struct {
+	address  string
+	active   atomic.Int64
+	failures atomic.Int32
+	healthy  atomic.Bool
+}
+
+func newUpstream(address string) *upstream {
+	u := &upstream{address: strings.TrimSuffix(address, "/")}
+	u.healthy.Store(true)
+	return u
+}
+
+// NewGateway mounts the routes of config on app. Call Start to begin health
+// checks.
+func NewGateway(app *Application, config GatewayConfig) (*Gateway, error) {
+	defaults := DefaultGatewayConfig()
+	if config.Balancer == "" {
+		config.Balancer = defaults.Balancer
+	}
+	if config.Balancer != BalanceRoundRobin && config.Balancer != BalanceLeastConnections {
+		return nil, fmt.Errorf("unknown gateway balancer %q", config.Balancer)
+	}
+	if config.HealthCheck.Path == "" {
+		config.HealthCheck.Path = defaults.HealthCheck.Path
+	}
+	if config.HealthCheck.Interval <= 0 {
+		config.HealthCheck.Interval = defaults.HealthCheck.Interval
+	}
+	if config.HealthCheck.Timeout <= 0 {
+		config.HealthCheck.Timeout = defaults.HealthCheck.Timeout
+	}
+	if config.HealthCheck.UnhealthyAfter <= 0 {
+		config.HealthCheck.UnhealthyAfter = defaults.HealthCheck.UnhealthyAfter
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.UpstreamOpenAPIPath == "" {
+		config.UpstreamOpenAPIPath = defaults.UpstreamOpenAPIPath
+	}
+	if config.JWT == nil && app.auth != nil {
+		config.JWT = app.auth.JWTManager
+	}
+
+	g := &Gateway{
+		app:    app,
+		config: config,
+		http:   &http.Client{Timeout: config.HealthCheck.Timeout},
+		stop:   make(chan struct{}),
+	}
+
+	for _, rc := range config.Routes {
+		if !strings.HasPrefix(rc.Prefix, "/") {
+			return nil, fmt.Errorf("gateway route prefix %q must start with /", rc.Prefix)
+		}
+		if len(rc.Targets) == 0 && (rc.Service == "" || config.Registry == nil) {
+			return nil, fmt.Errorf("gateway route %s needs targets or a service and a registry", rc.Prefix)
+		}
+		if rc.Auth && config.JWT == nil {
+			return nil, fmt.Errorf("gateway route %s requires auth but no JWT manager is configured", rc.Prefix)
+		}
+		rc.Prefix = strings.TrimSuffix(rc.Prefix, "/")
+
+		route := &gatewayRoute{GatewayRoute: rc}
+		for _, target := range rc.Targets {
+			route.upstreams = append(route.upstreams, newUpstream(target))
+		}
+		if rc.RateLimit.Max > 0 {
+			route.limiter = newGatewayLimiter(rc.RateLimit)
+		}
+		g.routes = append(g.routes, route)
+	}
+
+	// Longest prefix wins.
+	sort.SliceStable(g.routes, func(i, j int) bool { return len(g.routes[i].Prefix) > len(g.routes[j].Prefix) })
+
+	if config.OpenAPIPath != "" {
+		app.server.Get(config.OpenAPIPath, func(c *fiber.Ctx) error {
+			spec, err := g.OpenAPI(c.UserContext())
+			if err != nil {
+				return err
+			}
+			return c.JSON(spec)
+		})
+	}
+	app.server.Use(g.handle)
+	return g, nil
+}
+
+// Start resolves registry routes and runs health checks until Stop.
+func (g *Gateway) Start() {
+	g.check(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(g.config.HealthCheck.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.stop:
+				return
+			case <-ticker.C:
+				g.check(context.Background())
+			}
+		}
+	}()
+}
+
+func (g *Gateway) Stop() {
+	g.once.Do(func() { close(g.stop) })
+}
+
+func (g *Gateway) match(path string) *gatewayRoute {
+	for _, route := range g.routes {
+		if route.Prefix == "" || path == route.Prefix || strings.HasPrefix(path, route.Prefix+"/") {
+			return route
+		}
+	}
+	return nil
+}
+
+func (g *Gateway) handle(c *fiber.Ctx) error {
+	route := g.match(c.Path())
+	if route == nil {
+		return c.Next()
+	}
+
+	var quota limitStatus
+	if route.limiter != nil {
+		var ok bool
+		if quota, ok = route.limiter.take(c.IP()); !ok {
+			route.limiter.writeHeaders(c, quota)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(time.Until(quota.reset).Seconds())+1))
+			return ErrRateLimited
+		}
+	}
+
+	// Identity headers are only trusted when the gateway sets them.
+	c.Request().Header.Del("X-User-ID")
+	if route.Auth {
+		token := strings.TrimSpace(strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer"))
+		if token == "" {
+			return ErrUnauthorized
+		}
+		claims, err := g.config.JWT.ValidateToken(token)
+		if err != nil {
+			return ErrUnauthorized.WithError(err)
+		}
+		if userID, ok := claims["user_id"]; ok {
+			c.Request().Header.Set("X-User-ID", fmt.Sprint(userID))
+		}
+		c.Locals("claims", claims)
+	}
+
+	target := route.pick(g.config.Balancer)
+	if target == nil {
+		return ErrUpstreamUnavailable.WithDetail("service", route.name())
+	}
+
+	path := c.Path()
+	if route.StripPrefix {
+		path = strings.TrimPrefix(path, route.Prefix)
+		if path == "" {
+			path = "/"
+		}
+	}
+	url := target.address + path
+	if query := string(c.Request().URI().QueryString()); query != "" {
+		url += "?" + query
+	}
+
+	c.Request().Header.Set(fiber.HeaderXForwardedFor, c.IP())
+	c.Request().Header.Set(fiber.HeaderXForwardedHost, c.Hostname())
+	c.Request().Header.Set(fiber.HeaderXForwardedProto, c.Protocol())
+	if traceID, ok := c.Locals("trace_id").(string); ok {
+		c.Request().Header.Set("X-Trace-ID", traceID)
+	}
+
+	timeout := route.Timeout
+	if timeout <= 0 {
+		timeout = g.config.Timeout
+	}
+
+	target.active.Add(1)
+	err := proxy.DoTimeout(c, url, timeout)
+	target.active.Add(-1)
+	if err != nil {
+		// A failed connection counts against the upstream like a failed check.
+		g.markFailure(target)
+		g.app.Logger().Error("Gateway request to %s failed: %v", url, err)
+		return ErrBadGateway.WithDetail("service", route.name()).WithError(err)
+	}
+	// The upstream response replaced ours, headers included.
+	if route.limiter != nil {
+		route.limiter.writeHeaders(c, quota)
+	}
+	return nil
+}
+
+func (r *gatewayRoute) name() string {
+	if r.Service != "" {
+		return r.Service
+	}
+	return r.Prefix
+}
+
+func (r *gatewayRoute) pick(balancer string) *upstream {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	n := len(r.upstreams)
+	if n == 0 {
+		return nil
+	}
+
+	start := int(r.next.Add(1)-1) % n
+	var chosen *upstream
+	for i := 0; i < n; i++ {
+		u := r.upstreams[(start+i)%n]
+		if !u.healthy.Load() {
+			continue
+		}
+		if balancer != BalanceLeastConnections {
+			return u
+		}
+		if chosen == nil || u.active.Load() < chosen.active.Load() {
+			chosen = u
+		}
+	}
+	return chosen
+}
+
+func (g *Gateway) markFailure(u *upstream) {
+	if int(u.failures.Add(1)) >= g.config.HealthCheck.UnhealthyAfter {
+		u.healthy.Store(false)
+	}
+}
+
+// check refreshes registry routes and probes every upstream's health path.
+func (g *Gateway) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, route := range g.routes {
+		if len(route.Targets) == 0 {
+			g.refresh(ctx, route)
+		}
+
+		route.mu.RLock()
+		upstreams := append([]*upstream(nil), route.upstreams...)
+		route.mu.RUnlock()
+
+		for _, u := range upstreams {
+			wg.Add(1)
+			go func(u *upstream) {
+				defer wg.Done()
+				if g.probe(ctx, u) {
+					u.failures.Store(0)
+					u.healthy.Store(true)
+				} else {
+					g.markFailure(u)
+				}
+			}(u)
+		}
+	}
+	wg.Wait()
+}
+
+func (g *Gateway) refresh(ctx context.Context, route *gatewayRoute) {
+	instances, err := g.config.Registry.Instances(ctx, route.Service)
+	if err != nil {
+		g.app.Logger().Error("Gateway failed to resolve %s: %v", route.Service, err)
+		return
+	}
+
+	route.mu.Lock()
+	defer route.mu.Unlock()
+
+	known := make(map[string]*upstream, len(route.upstreams))
+	for _, u := range route.upstreams {
+		known[u.address] = u
+	}
+	upstreams := make([]*upstream, 0, len(instances))
+	for _, inst := range instances {
+		address := strings.TrimSuffix(inst.Address, "/")
+		if u, ok := known[address]; ok {
+			upstreams = append(upstreams, u)
+		} else {
+			upstreams = append(upstreams, newUpstream(address))
+		}
+	}
+	route.upstreams = upstreams
+}
+
+func (g *Gateway) probe(ctx context.Context, u *upstream) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.address+g.config.HealthCheck.Path, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// OpenAPI merges the specs served by one healthy upstream of each route.
+// Paths are rewritten to their public form and operations without tags are
+// tagged with the service name. Unreachable upstreams are left out.
+func (g *Gateway) OpenAPI(ctx context.Context) (*OpenAPISpec, error) {
+	spec := &OpenAPISpec{
+		OpenAPI: "3.0.0",
+		Info: OpenAPIInfo{
+			Title:       g.app.config.Name,
+			Description: g.app.config.Description,
+			Version:     g.app.config.Version,
+		},
+		Paths: make(map[string]PathItem),
+		Components: OpenAPIComponents{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+	}
+
+	for _, route := range g.routes {
+		target := route.pick(BalanceRoundRobin)
+		if target == nil {
+			continue
+		}
+
+		upstreamSpec, err := g.fetchSpec(ctx, target.address+g.config.UpstreamOpenAPIPath)
+		if err != nil {
+			g.app.Logger().Error("Gateway failed to load OpenAPI for %s: %v", route.name(), err)
+			continue
+		}
+
+		for path, item := range upstreamSpec.Paths {
+			public := path
+			if route.StripPrefix {
+				public = route.Prefix + path
+			} else if route.Prefix != "" && path != route.Prefix && !strings.HasPrefix(path, route.Prefix+"/") {
+				// Not reachable through this route.
+				continue
+			}
+			for _, op := range []*Operation{item.Get, item.Post, item.Put, item.Delete, item.Patch} {
+				if op == nil {
+					continue
+				}
+				if len(op.Tags) == 0 {
+					op.Tags = []string{route.name()}
+				}
+				if route.Auth && len(op.Security) == 0 {
+					op.Security = []map[string][]string{{"bearerAuth": {}}}
+				}
+			}
+			spec.Paths[public] = item
+		}
+		for name, schema := range upstreamSpec.Components.Schemas {
+			if _, exists := spec.Components.Schemas[name]; !exists {
+				spec.Components.Schemas[name] = schema
+			}
+		}
+		for name, scheme := range upstreamSpec.Components.SecuritySchemes {
+			spec.Components.SecuritySchemes[name] = scheme
+		}
+	}
+	return spec, nil
+}
+
+func (g *Gateway) fetchSpec(ctx context.Context, url string) (*OpenAPISpec, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var spec OpenAPISpec
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to decode spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// gatewayLimiter is a fixed-window counter per client IP.
+type gatewayLimiter struct {
+	config  GatewayRateLimit
+	mu      sync.Mutex
+	windows map[string]*limitWindow
+	swept   time.Time
+}
+
+type limitWindow struct {
+	count int
+	reset time.Time
+}
+
+func newGatewayLimiter(config GatewayRateLimit) *gatewayLimiter {
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	return &gatewayLimiter{config: config, windows: make(map[string]*limitWindow), swept: time.Now()}
+}
+
+type limitStatus struct {
+	remaining int
+	reset     time.Time
+}
+
+// take counts a request for key and reports whether it is within the limit.
+func (l *gatewayLimiter) take(key string) (limitStatus, bool) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) > l.config.Window {
+		for k, w := range l.windows {
+			if now.After(w.reset) {
+				delete(l.windows, k)
+			}
+		}
+		l.swept = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.After(w.reset) {
+		w = &limitWindow{reset: now.Add(l.config.Window)}
+		l.windows[key] = w
+	}
+	w.count++
+
+	status := limitStatus{remaining: l.config.Max - w.count, reset: w.reset}
+	if status.remaining < 0 {
+		status.remaining = 0
+	}
+	return status, w.count <= l.config.Max
+}
+
+func (l *gatewayLimiter) writeHeaders(c *fiber.Ctx, status limitStatus) {
+	c.Set("X-RateLimit-Limit", strconv.Itoa(l.config.Max))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(status.remaining))
+	c.Set("X-RateLimit-Reset", strconv.FormatInt(status.reset.Unix(), 10))
+}
@@ -0,0 +1,187 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/auth"
+	"github.com/Fluxgo/flux/pkg/flux/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUpstreamServer(t *testing.T, name string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"openapi": "3.0.0",
+			"paths": map[string]interface{}{
+				"/items": map[string]interface{}{"get": map[string]interface{}{"summary": "List " + name}},
+			},
+		})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Seen-User", r.Header.Get("X-User-ID"))
+		io.WriteString(w, r.URL.RequestURI())
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func gatewayGet(t *testing.T, app *Application, path string, header ...string) *http.Response {
+	req := httptest.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestGatewayBalancesAndStripsPrefix(t *testing.T) {
+	a, b := newUpstreamServer(t, "a"), newUpstreamServer(t, "b")
+	app := newTestApplication()
+
+	_, err := NewGateway(app, GatewayConfig{Routes: []GatewayRoute{
+		{Prefix: "/orders", Targets: []string{a.URL, b.URL}, StripPrefix: true},
+	}})
+	assert.NoError(t, err)
+
+	var seen []string
+	for i := 0; i < 4; i++ {
+		resp := gatewayGet(t, app, "/orders/42?expand=items")
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "/42?expand=items", string(body))
+		seen = append(seen, resp.Header.Get("X-Upstream"))
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, seen)
+
+	assert.Equal(t, 404, gatewayGet(t, app, "/ordersx").StatusCode)
+}
+
+func TestGatewayHealthChecksAndRegistry(t *testing.T) {
+	healthy := newUpstreamServer(t, "healthy")
+	down := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(down.Close)
+
+	reg := registry.NewMemory()
+	reg.Register(context.Background(), registry.Instance{ID: "1", Service: "orders", Address: down.URL})
+	reg.Register(context.Background(), registry.Instance{ID: "2", Service: "orders", Address: healthy.URL})
+
+	app := newTestApplication()
+	config := DefaultGatewayConfig()
+	config.Registry = reg
+	config.HealthCheck.UnhealthyAfter = 1
+	config.Routes = []GatewayRoute{{Prefix: "/", Service: "orders"}}
+	gateway, err := NewGateway(app, config)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 503, gatewayGet(t, app, "/items").StatusCode)
+
+	gateway.Start()
+	defer gateway.Stop()
+
+	for i := 0; i < 3; i++ {
+		resp := gatewayGet(t, app, "/items")
+		assert.Equal(t, "healthy", resp.Header.Get("X-Upstream"))
+	}
+}
+
+func TestGatewayConcurrentUpstreamFailures(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	app := newTestApplication()
+	config := DefaultGatewayConfig()
+	config.Registry = registry.NewMemory()
+	config.HealthCheck.UnhealthyAfter = 1000
+	config.Routes = []GatewayRoute{
+		{Prefix: "/down", Targets: []string{closed.URL}},
+		{Prefix: "/missing", Service: "missing"},
+	}
+	_, err := NewGateway(app, config)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 502, gatewayGet(t, app, "/down").StatusCode)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 503, gatewayGet(t, app, "/missing").StatusCode)
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, ErrBadGateway.Details)
+	assert.Empty(t, ErrUpstreamUnavailable.Details)
+}
+
+func TestGatewayAuthAndRateLimit(t *testing.T) {
+	upstream := newUpstreamServer(t, "a")
+	jwt, err := auth.New(auth.Config{SecretKey: "secret"})
+	assert.NoError(t, err)
+
+	app := newTestApplication()
+	_, err = NewGateway(app, GatewayConfig{
+		JWT: jwt.JWTManager,
+		Routes: []GatewayRoute{
+			{Prefix: "/private", Targets: []string{upstream.URL}, Auth: true},
+			{Prefix: "/public", Targets: []string{upstream.URL}, RateLimit: GatewayRateLimit{Max: 2, Window: time.Minute}},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 401, gatewayGet(t, app, "/private/me").StatusCode)
+	assert.Equal(t, 401, gatewayGet(t, app, "/private/me", "Authorization", "Bearer nope").StatusCode)
+
+	token, err := jwt.GenerateToken("7", nil)
+	assert.NoError(t, err)
+	resp := gatewayGet(t, app, "/private/me", "Authorization", "Bearer "+token)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "7", resp.Header.Get("X-Seen-User"))
+
+	// Clients cannot claim an identity on public routes.
+	resp = gatewayGet(t, app, "/public/x", "X-User-ID", "1")
+	assert.Equal(t, "", resp.Header.Get("X-Seen-User"))
+	assert.Equal(t, "1", resp.Header.Get("X-RateLimit-Remaining"))
+
+	assert.Equal(t, 200, gatewayGet(t, app, "/public/x").StatusCode)
+	resp = gatewayGet(t, app, "/public/x")
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+}
+
+func TestGatewayAggregatesOpenAPI(t *testing.T) {
+	orders, users := newUpstreamServer(t, "orders"), newUpstreamServer(t, "users")
+	app := newTestApplication()
+
+	_, err := NewGateway(app, GatewayConfig{
+		OpenAPIPath: "/openapi.json",
+		Routes: []GatewayRoute{
+			{Prefix: "/orders", Service: "orders", Targets: []string{orders.URL}, StripPrefix: true},
+			{Prefix: "/users", Service: "users", Targets: []string{users.URL}, StripPrefix: true},
+		},
+	})
+	assert.NoError(t, err)
+
+	resp := gatewayGet(t, app, "/openapi.json")
+	var spec OpenAPISpec
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+	assert.Len(t, spec.Paths, 2)
+	assert.Equal(t, "List orders", spec.Paths["/orders/items"].Get.Summary)
+	assert.Equal(t, []string{"users"}, spec.Paths["/users/items"].Get.Tags)
+}
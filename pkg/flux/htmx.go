@@ -0,0 +1,161 @@
+package flux
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	HXRequestHeader     = "HX-Request"
+	HXBoostedHeader     = "HX-Boosted"
+	HXTargetHeader      = "HX-Target"
+	HXTriggerHeader     = "HX-Trigger"
+	HXTriggerNameHeader = "HX-Trigger-Name"
+	HXCurrentURLHeader  = "HX-Current-URL"
+	HXPromptHeader      = "HX-Prompt"
+
+	HXRedirectHeader           = "HX-Redirect"
+	HXLocationHeader           = "HX-Location"
+	HXPushURLHeader            = "HX-Push-Url"
+	HXReplaceURLHeader         = "HX-Replace-Url"
+	HXRefreshHeader            = "HX-Refresh"
+	HXReswapHeader             = "HX-Reswap"
+	HXRetargetHeader           = "HX-Retarget"
+	HXTriggerAfterSettleHeader = "HX-Trigger-After-Settle"
+	HXTriggerAfterSwapHeader   = "HX-Trigger-After-Swap"
+)
+
+// IsHTMX reports whether the request was issued by htmx.
+func (c *Context) IsHTMX() bool {
+	return c.Ctx.Get(HXRequestHeader) == "true"
+}
+
+// IsHXBoosted reports whether the request came from an hx-boost element.
+func (c *Context) IsHXBoosted() bool {
+	return c.Ctx.Get(HXBoostedHeader) == "true"
+}
+
+func (c *Context) HXTarget() string {
+	return c.Ctx.Get(HXTargetHeader)
+}
+
+func (c *Context) HXTrigger() string {
+	return c.Ctx.Get(HXTriggerHeader)
+}
+
+func (c *Context) HXTriggerName() string {
+	return c.Ctx.Get(HXTriggerNameHeader)
+}
+
+func (c *Context) HXCurrentURL() string {
+	return c.Ctx.Get(HXCurrentURLHeader)
+}
+
+func (c *Context) HXPrompt() string {
+	return c.Ctx.Get(HXPromptHeader)
+}
+
+// HXTriggerEvent adds a client-side event to the HX-Trigger response header.
+// Calling it several times triggers several events.
+func (c *Context) HXTriggerEvent(name string, detail ...interface{}) *Context {
+	return c.addHXEvent(HXTriggerHeader, name, detail)
+}
+
+func (c *Context) HXTriggerAfterSettle(name string, detail ...interface{}) *Context {
+	return c.addHXEvent(HXTriggerAfterSettleHeader, name, detail)
+}
+
+func (c *Context) HXTriggerAfterSwap(name string, detail ...interface{}) *Context {
+	return c.addHXEvent(HXTriggerAfterSwapHeader, name, detail)
+}
+
+type hxEvents struct {
+	names   []string
+	details map[string]interface{}
+}
+
+func (c *Context) addHXEvent(header, name string, detail []interface{}) *Context {
+	key := "hx_events:" + header
+	events, ok := c.Locals(key).(*hxEvents)
+	if !ok {
+		events = &hxEvents{details: make(map[string]interface{})}
+		c.Locals(key, events)
+	}
+
+	if _, exists := events.details[name]; !exists {
+		events.names = append(events.names, name)
+	}
+	events.details[name] = nil
+	if len(detail) == 1 {
+		events.details[name] = detail[0]
+	} else if len(detail) > 1 {
+		events.details[name] = detail
+	}
+
+	hasDetail := false
+	for _, d := range events.details {
+		if d != nil {
+			hasDetail = true
+			break
+		}
+	}
+
+	if !hasDetail {
+		c.Ctx.Set(header, strings.Join(events.names, ", "))
+		return c
+	}
+
+	data, err := json.Marshal(events.details)
+	if err != nil {
+		c.Ctx.Set(header, strings.Join(events.names, ", "))
+		return c
+	}
+	c.Ctx.Set(header, string(data))
+	return c
+}
+
+// HXRedirect makes htmx perform a full client-side redirect to url.
+func (c *Context) HXRedirect(url string) error {
+	c.Ctx.Set(HXRedirectHeader, url)
+	return c.Ctx.SendStatus(200)
+}
+
+// HXLocation makes htmx load url with an AJAX request, without a full reload.
+func (c *Context) HXLocation(url string) error {
+	c.Ctx.Set(HXLocationHeader, url)
+	return c.Ctx.SendStatus(200)
+}
+
+// HXRefresh makes htmx reload the whole page.
+func (c *Context) HXRefresh() error {
+	c.Ctx.Set(HXRefreshHeader, "true")
+	return c.Ctx.SendStatus(200)
+}
+
+func (c *Context) HXPushURL(url string) *Context {
+	c.Ctx.Set(HXPushURLHeader, url)
+	return c
+}
+
+func (c *Context) HXReplaceURL(url string) *Context {
+	c.Ctx.Set(HXReplaceURLHeader, url)
+	return c
+}
+
+// HXReswap overrides the hx-swap strategy, e.g. "outerHTML" or "beforeend".
+func (c *Context) HXReswap(strategy string) *Context {
+	c.Ctx.Set(HXReswapHeader, strategy)
+	return c
+}
+
+// HXRetarget overrides the element the response is swapped into.
+func (c *Context) HXRetarget(selector string) *Context {
+	c.Ctx.Set(HXRetargetHeader, selector)
+	return c
+}
+
+// wantsPartial reports whether a rendered view should skip its layout.
+// Boosted links still expect the full page.
+func (c *Context) wantsPartial() bool {
+	return c.IsHTMX() && !c.IsHXBoosted()
+}
@@ -0,0 +1,332 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// Directory holds one file per locale, e.g. locales/en.yaml, locales/fr.json.
+	Directory string `yaml:"directory" json:"directory"`
+	// FS is used instead of Directory when set, e.g. an embed.FS.
+	FS            fs.FS  `yaml:"-" json:"-"`
+	DefaultLocale string `yaml:"default_locale" json:"default_locale"`
+	// QueryParam and Cookie name where an explicit locale choice is read
+	// from before falling back to Accept-Language.
+	QueryParam string `yaml:"query_param" json:"query_param"`
+	Cookie     string `yaml:"cookie" json:"cookie"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Directory:     "locales",
+		DefaultLocale: "en",
+		QueryParam:    "lang",
+		Cookie:        "lang",
+	}
+}
+
+// Args are named placeholders for a message, written as {name}. The "count"
+// argument also selects the plural form.
+type Args map[string]interface{}
+
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+// Bundle holds the messages of every locale.
+type Bundle struct {
+	mu            sync.RWMutex
+	defaultLocale string
+	messages      map[string]map[string]message
+}
+
+func NewBundle(defaultLocale string) *Bundle {
+	if defaultLocale == "" {
+		defaultLocale = "en"
+	}
+	return &Bundle{defaultLocale: normalizeLocale(defaultLocale), messages: make(map[string]map[string]message)}
+}
+
+// Load reads every .yaml, .yml and .json file in config.Directory (or
+// config.FS). The file name is the locale.
+func Load(config Config) (*Bundle, error) {
+	bundle := NewBundle(config.DefaultLocale)
+
+	fsys := config.FS
+	if fsys == nil {
+		fsys = os.DirFS(config.Directory)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translations: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		var messages map[string]interface{}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &messages)
+		} else {
+			err = yaml.Unmarshal(data, &messages)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+
+		bundle.AddMessages(strings.TrimSuffix(entry.Name(), ext), messages)
+	}
+
+	return bundle, nil
+}
+
+// AddMessages merges nested messages for locale. Nested keys are joined with
+// dots, and a map with an "other" key holds plural forms:
+//
+//	cart:
+//	  items:
+//	    one: "{count} item"
+//	    other: "{count} items"
+func (b *Bundle) AddMessages(locale string, messages map[string]interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	locale = normalizeLocale(locale)
+	if b.messages[locale] == nil {
+		b.messages[locale] = make(map[string]message)
+	}
+	flatten(b.messages[locale], "", messages)
+}
+
+var pluralForms = map[string]bool{"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true}
+
+func flatten(out map[string]message, prefix string, messages map[string]interface{}) {
+	for key, value := range messages {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			out[key] = message{text: fmt.Sprint(value)}
+			continue
+		}
+
+		if isPlural(nested) {
+			forms := make(map[string]string, len(nested))
+			for form, text := range nested {
+				forms[form] = fmt.Sprint(text)
+			}
+			out[key] = message{plural: forms}
+			continue
+		}
+		flatten(out, key, nested)
+	}
+}
+
+func isPlural(m map[string]interface{}) bool {
+	if _, ok := m["other"]; !ok {
+		return false
+	}
+	for form, value := range m {
+		if _, isString := value.(string); !pluralForms[form] || !isString {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bundle) DefaultLocale() string {
+	return b.defaultLocale
+}
+
+// Locales returns the loaded locales, sorted.
+func (b *Bundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	locales := make([]string, 0, len(b.messages))
+	for locale := range b.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Match returns the first candidate the bundle has messages for, trying
+// "pt-BR" before "pt", or the default locale when none match.
+func (b *Bundle) Match(candidates ...string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, candidate := range candidates {
+		for _, locale := range fallbacks(normalizeLocale(candidate)) {
+			if _, ok := b.messages[locale]; ok {
+				return locale
+			}
+		}
+	}
+	return b.defaultLocale
+}
+
+// Has reports whether key is translated for locale or one of its fallbacks.
+func (b *Bundle) Has(locale, key string) bool {
+	_, ok := b.lookup(locale, key)
+	return ok
+}
+
+func (b *Bundle) lookup(locale, key string) (message, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	chain := append(fallbacks(normalizeLocale(locale)), b.defaultLocale)
+	for _, l := range chain {
+		if msg, ok := b.messages[l][key]; ok {
+			return msg, true
+		}
+	}
+	return message{}, false
+}
+
+// Translate returns the message for key in locale, falling back to the base
+// language, then the default locale, then key itself. args may contain an
+// int (the plural count) and Args or other string-keyed maps.
+func (b *Bundle) Translate(locale, key string, args ...interface{}) string {
+	msg, ok := b.lookup(locale, key)
+	if !ok {
+		return key
+	}
+
+	named, count, hasCount := parseArgs(args)
+	text := msg.text
+	if msg.plural != nil {
+		form := "other"
+		if hasCount {
+			form = PluralForm(locale, count)
+			if count == 0 {
+				if _, ok := msg.plural["zero"]; ok {
+					form = "zero"
+				}
+			}
+		}
+		text, ok = msg.plural[form]
+		if !ok {
+			text = msg.plural["other"]
+		}
+	}
+	return interpolate(text, named)
+}
+
+func parseArgs(args []interface{}) (map[string]interface{}, int, bool) {
+	named := make(map[string]interface{})
+	count, hasCount := 0, false
+
+	for _, arg := range args {
+		v := reflect.ValueOf(arg)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			count, hasCount = int(v.Int()), true
+			named["count"] = count
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			count, hasCount = int(v.Uint()), true
+			named["count"] = count
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				continue
+			}
+			iter := v.MapRange()
+			for iter.Next() {
+				named[iter.Key().String()] = iter.Value().Interface()
+			}
+		}
+	}
+
+	if !hasCount {
+		if c, ok := named["count"]; ok {
+			if n, err := strconv.Atoi(fmt.Sprint(c)); err == nil {
+				count, hasCount = n, true
+			}
+		}
+	}
+	return named, count, hasCount
+}
+
+func interpolate(text string, args map[string]interface{}) string {
+	if len(args) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for k, v := range args {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Localizer is a Bundle bound to one locale.
+type Localizer struct {
+	bundle *Bundle
+	locale string
+}
+
+func (b *Bundle) Localizer(locale string) *Localizer {
+	return &Localizer{bundle: b, locale: b.Match(locale)}
+}
+
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+func (l *Localizer) T(key string, args ...interface{}) string {
+	return l.bundle.Translate(l.locale, key, args...)
+}
+
+func (l *Localizer) Has(key string) bool {
+	return l.bundle.Has(l.locale, key)
+}
+
+func normalizeLocale(locale string) string {
+	locale = strings.TrimSpace(strings.ReplaceAll(locale, "_", "-"))
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// fallbacks returns "zh-Hant-TW", "zh-Hant", "zh" for "zh-Hant-TW".
+func fallbacks(locale string) []string {
+	var chain []string
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return chain
+}
@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBundle(t *testing.T) *Bundle {
+	t.Helper()
+	bundle, err := Load(Config{
+		DefaultLocale: "en",
+		FS: fstest.MapFS{
+			"en.yaml": {Data: []byte(`
+greeting: "Hello, {name}!"
+cart:
+  items:
+    zero: "Your cart is empty"
+    one: "{count} item"
+    other: "{count} items"
+`)},
+			"fr.json": {Data: []byte(`{"greeting": "Bonjour, {name} !", "cart": {"items": {"one": "{count} article", "other": "{count} articles"}}}`)},
+			"ru.yml": {Data: []byte(`
+cart:
+  items:
+    one: "{count} товар"
+    few: "{count} товара"
+    many: "{count} товаров"
+    other: "{count} товара"
+`)},
+			"README.md": {Data: []byte("ignored")},
+		},
+	})
+	require.NoError(t, err)
+	return bundle
+}
+
+func TestLoadAndTranslate(t *testing.T) {
+	bundle := testBundle(t)
+
+	assert.Equal(t, []string{"en", "fr", "ru"}, bundle.Locales())
+	assert.Equal(t, "Hello, Ada!", bundle.Translate("en", "greeting", Args{"name": "Ada"}))
+	assert.Equal(t, "Bonjour, Ada !", bundle.Translate("fr-CA", "greeting", map[string]string{"name": "Ada"}))
+	// Missing in ru: falls back to the default locale, then the key.
+	assert.Equal(t, "Hello, {name}!", bundle.Translate("ru", "greeting"))
+	assert.Equal(t, "missing.key", bundle.Translate("en", "missing.key"))
+}
+
+func TestPlurals(t *testing.T) {
+	bundle := testBundle(t)
+
+	assert.Equal(t, "Your cart is empty", bundle.Translate("en", "cart.items", 0))
+	assert.Equal(t, "1 item", bundle.Translate("en", "cart.items", 1))
+	assert.Equal(t, "5 items", bundle.Translate("en", "cart.items", Args{"count": 5}))
+	assert.Equal(t, "0 article", bundle.Translate("fr", "cart.items", 0))
+	assert.Equal(t, "21 товар", bundle.Translate("ru", "cart.items", 21))
+	assert.Equal(t, "3 товара", bundle.Translate("ru", "cart.items", 3))
+	assert.Equal(t, "11 товаров", bundle.Translate("ru", "cart.items", 11))
+}
+
+func TestNegotiation(t *testing.T) {
+	bundle := testBundle(t)
+
+	assert.Equal(t, []string{"fr-CH", "fr", "en"}, ParseAcceptLanguage("en;q=0.5, fr-CH, fr;q=0.9, de;q=0, *;q=0.1"))
+	assert.Equal(t, "fr", bundle.Match(ParseAcceptLanguage("de-DE, fr-CH;q=0.8")...))
+	assert.Equal(t, "ru", bundle.Match("ru_RU"))
+	assert.Equal(t, "en", bundle.Match("ja"))
+	assert.Equal(t, "fr", bundle.Localizer("fr-BE").Locale())
+}
@@ -0,0 +1,112 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header
+// ordered by preference. Wildcards and q=0 entries are dropped.
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// PluralForm returns the CLDR plural category ("zero", "one", "two", "few",
+// "many" or "other") of n for the language of locale. Only integer counts and
+// the most common languages are covered; others use English rules.
+func PluralForm(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	lang := strings.ToLower(strings.SplitN(strings.ReplaceAll(locale, "_", "-"), "-", 2)[0])
+
+	switch lang {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "tr":
+		return "other"
+	case "fr", "pt":
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	case "ru", "uk", "be", "sr", "hr", "bs":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		default:
+			return "other"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case n%100 >= 3 && n%100 <= 10:
+			return "few"
+		case n%100 >= 11:
+			return "many"
+		default:
+			return "other"
+		}
+	}
+
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
@@ -0,0 +1,113 @@
+package flux
+
+import (
+	"github.com/Fluxgo/flux/pkg/flux/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// localize resolves the request locale from the query parameter, the cookie
+// and then Accept-Language, and stores a Localizer in Locals.
+func (app *Application) localize(c *fiber.Ctx) error {
+	bundle := app.i18n
+	if bundle == nil {
+		return c.Next()
+	}
+
+	var candidates []string
+	if name := app.i18nConfig().QueryParam; name != "" {
+		if v := c.Query(name); v != "" {
+			candidates = append(candidates, v)
+		}
+	}
+	if name := app.i18nConfig().Cookie; name != "" {
+		if v := c.Cookies(name); v != "" {
+			candidates = append(candidates, v)
+		}
+	}
+	candidates = append(candidates, i18n.ParseAcceptLanguage(c.Get(fiber.HeaderAcceptLanguage))...)
+
+	localizer := bundle.Localizer(bundle.Match(candidates...))
+	c.Locals("locale", localizer.Locale())
+	c.Locals("localizer", localizer)
+	c.Vary(fiber.HeaderAcceptLanguage)
+	c.Set(fiber.HeaderContentLanguage, localizer.Locale())
+	return c.Next()
+}
+
+func (app *Application) i18nConfig() i18n.Config {
+	config := i18n.DefaultConfig()
+	if app.config == nil {
+		return config
+	}
+	if app.config.I18n.QueryParam != "" {
+		config.QueryParam = app.config.I18n.QueryParam
+	}
+	if app.config.I18n.Cookie != "" {
+		config.Cookie = app.config.I18n.Cookie
+	}
+	return config
+}
+
+func (app *Application) I18n() *i18n.Bundle {
+	return app.i18n
+}
+
+// SetI18n replaces the translation bundle. Requests only get a locale when
+// the bundle was configured at startup or the localize middleware is in use.
+func (app *Application) SetI18n(bundle *i18n.Bundle) {
+	app.i18n = bundle
+	if app.mailer != nil && bundle != nil {
+		app.mailer.SetTranslator(bundle.Translate)
+	}
+}
+
+func localizerFor(c *fiber.Ctx) *i18n.Localizer {
+	localizer, _ := c.Locals("localizer").(*i18n.Localizer)
+	return localizer
+}
+
+// Locale is the negotiated locale of the request, or "" without i18n.
+func (c *Context) Locale() string {
+	if localizer := localizerFor(c.Ctx); localizer != nil {
+		return localizer.Locale()
+	}
+	return ""
+}
+
+// T translates key into the request locale. args may hold a count for
+// plural forms and i18n.Args (or H) for {name} placeholders. The key is
+// returned when there is no translation.
+func (c *Context) T(key string, args ...interface{}) string {
+	if localizer := localizerFor(c.Ctx); localizer != nil {
+		return localizer.T(key, args...)
+	}
+	if c.app != nil && c.app.i18n != nil {
+		return c.app.i18n.Translate(c.app.i18n.DefaultLocale(), key, args...)
+	}
+	return key
+}
+
+// translateValidation returns the "validation.<tag>" message for e in the
+// locale of localizer, or the built-in English message.
+func translateValidation(localizer *i18n.Localizer, e validator.FieldError) string {
+	key := "validation." + e.Tag()
+	if localizer == nil || !localizer.Has(key) {
+		return validationMessage(e)
+	}
+
+	field := fieldPath(e)
+	if localizer.Has("fields." + field) {
+		field = localizer.T("fields." + field)
+	}
+	return localizer.T(key, i18n.Args{"field": field, "param": e.Param()})
+}
+
+// translateError returns the "errors.<CODE>" message for appErr, or its
+// own message.
+func translateError(localizer *i18n.Localizer, appErr *AppError) string {
+	if localizer == nil || appErr.Code == "" || !localizer.Has("errors."+appErr.Code) {
+		return appErr.Message
+	}
+	return localizer.T("errors." + appErr.Code)
+}
@@ -0,0 +1,104 @@
+package flux
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Fluxgo/flux/pkg/flux/i18n"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLocalizedApplication(t *testing.T) *Application {
+	t.Helper()
+	app := newTestApplication()
+
+	bundle := i18n.NewBundle("en")
+	bundle.AddMessages("en", map[string]interface{}{
+		"welcome": "Welcome, {name}",
+	})
+	bundle.AddMessages("fr", map[string]interface{}{
+		"welcome": "Bienvenue, {name}",
+		"fields":  map[string]interface{}{"email": "adresse e-mail"},
+		"validation": map[string]interface{}{
+			"required": "Le champ {field} est obligatoire",
+		},
+		"errors": map[string]interface{}{
+			"VALIDATION_FAILED": "La validation a échoué",
+		},
+	})
+	app.SetI18n(bundle)
+	app.server.Use(app.localize)
+	return app
+}
+
+func TestContextT(t *testing.T) {
+	app := newLocalizedApplication(t)
+	app.server.Get("/welcome", func(c *fiber.Ctx) error {
+		ctx := NewContext(c, app)
+		return ctx.Text(ctx.Locale() + ": " + ctx.T("welcome", H{"name": "Ada"}))
+	})
+
+	cases := []struct {
+		url, header, cookie, want string
+	}{
+		{"/welcome", "", "", "en: Welcome, Ada"},
+		{"/welcome", "de-DE, fr;q=0.8", "", "fr: Bienvenue, Ada"},
+		{"/welcome", "fr", "en", "en: Welcome, Ada"},
+		{"/welcome?lang=fr", "en", "en", "fr: Bienvenue, Ada"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		if tc.header != "" {
+			req.Header.Set("Accept-Language", tc.header)
+		}
+		if tc.cookie != "" {
+			req.Header.Set("Cookie", "lang="+tc.cookie)
+		}
+		resp, err := app.server.Test(req)
+		require.NoError(t, err)
+
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		assert.Equal(t, tc.want, string(body[:n]), tc.url)
+		assert.Equal(t, strings.SplitN(tc.want, ":", 2)[0], resp.Header.Get("Content-Language"))
+		assert.Contains(t, resp.Header.Get("Vary"), "Accept-Language")
+	}
+}
+
+func TestTranslatedValidationErrors(t *testing.T) {
+	app := newLocalizedApplication(t)
+	app.config.ErrorFormat = ErrorFormatProblem
+	app.server.Post("/signup", func(c *fiber.Ctx) error {
+		var input struct {
+			Email string `json:"email" validate:"required"`
+			Name  string `json:"name" validate:"required,min=3"`
+		}
+		ctx := NewContext(c, app)
+		if err := ctx.BindAndValidate(&input); err != nil {
+			return ctx.Error(err)
+		}
+		return ctx.Success(input)
+	})
+
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(`{"name":"Al"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fr")
+	resp, err := app.server.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, 422, resp.StatusCode)
+
+	var body struct {
+		Detail string       `json:"detail"`
+		Errors []FieldError `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "La validation a échoué", body.Detail)
+	require.Len(t, body.Errors, 2)
+	assert.Equal(t, "Le champ adresse e-mail est obligatoire", body.Errors[0].Message)
+	// No French message for min: the English default is kept.
+	assert.Equal(t, "The name must be at least 3 characters", body.Errors[1].Message)
+}
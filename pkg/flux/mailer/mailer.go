@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/mail.v2"
 )
@@ -12,9 +13,15 @@ import (
 type Mailer struct {
 	dialer    *mail.Dialer
 	templates *template.Template
+	// source is never executed so SendLocalized can clone it.
+	source    *template.Template
 	from      string
+	translate Translator
 }
 
+// Translator translates key into locale, e.g. (*i18n.Bundle).Translate.
+type Translator func(locale, key string, args ...interface{}) string
+
 type Config struct {
 	Host     string
 	Port     int
@@ -34,7 +41,14 @@ func New(config Config) (*Mailer, error) {
 	}
 	s.Close()
 
-	templates, err := template.ParseGlob(filepath.Join(config.TemplateDir, "*.html"))
+	// "t" is rebound to the recipient's locale by SendLocalized.
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"t": func(key string, args ...interface{}) string { return key },
+	}).ParseGlob(filepath.Join(config.TemplateDir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load email templates: %w", err)
+	}
+	source, err := templates.Clone()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load email templates: %w", err)
 	}
@@ -42,6 +56,7 @@ func New(config Config) (*Mailer, error) {
 	return &Mailer{
 		dialer:    dialer,
 		templates: templates,
+		source:    source,
 		from:      config.From,
 	}, nil
 }
@@ -96,3 +111,69 @@ func (m *Mailer) SendWithAttachments(to, subject, templateName string, data inte
 
 	return nil
 } 
+
+func (m *Mailer) SetTranslator(translate Translator) {
+	m.translate = translate
+}
+
+// SendLocalized sends templateName rendered for locale. "welcome.html" is
+// looked up as "welcome.fr-CA.html", "welcome.fr.html" and then
+// "welcome.html", and the subject and {{t "key"}} calls are translated.
+func (m *Mailer) SendLocalized(to, subject, templateName, locale string, data interface{}) error {
+	body, err := m.renderLocalized(templateName, locale, data)
+	if err != nil {
+		return err
+	}
+	if m.translate != nil {
+		subject = m.translate(locale, subject)
+	}
+
+	msg := mail.NewMessage()
+	msg.SetHeader("From", m.from)
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/html", body)
+	if err := m.dialer.DialAndSend(msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) renderLocalized(templateName, locale string, data interface{}) (string, error) {
+	ext := filepath.Ext(templateName)
+	base := strings.TrimSuffix(templateName, ext)
+
+	name := templateName
+	for l := strings.ReplaceAll(locale, "_", "-"); l != ""; {
+		if m.source.Lookup(base+"."+l+ext) != nil {
+			name = base + "." + l + ext
+			break
+		}
+		i := strings.LastIndex(l, "-")
+		if i < 0 {
+			break
+		}
+		l = l[:i]
+	}
+
+	if m.source.Lookup(name) == nil {
+		return "", fmt.Errorf("template %s not found", templateName)
+	}
+
+	tmpl, err := m.source.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to clone templates: %w", err)
+	}
+	if m.translate != nil {
+		tmpl.Funcs(template.FuncMap{
+			"t": func(key string, args ...interface{}) string { return m.translate(locale, key, args...) },
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderLocalized(t *testing.T) {
+	source := template.Must(template.New("").Funcs(template.FuncMap{
+		"t": func(key string, args ...interface{}) string { return key },
+	}).Parse(`{{define "welcome.html"}}{{t "hello"}} {{.}}{{end}}{{define "welcome.fr.html"}}Salut {{.}}{{end}}`))
+
+	m := &Mailer{source: source}
+	m.SetTranslator(func(locale, key string, args ...interface{}) string { return locale + ":" + key })
+
+	body, err := m.renderLocalized("welcome.html", "fr-CA", "Ada")
+	require.NoError(t, err)
+	assert.Equal(t, "Salut Ada", body)
+
+	body, err = m.renderLocalized("welcome.html", "de", "Ada")
+	require.NoError(t, err)
+	assert.Equal(t, "de:hello Ada", body)
+
+	_, err = m.renderLocalized("missing.html", "en", nil)
+	assert.Error(t, err)
+}
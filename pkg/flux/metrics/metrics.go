@@ -111,6 +111,13 @@ func (m *Metrics) SetAppInfo(name, version string) {
 }
 
 
+// Registry is where the collectors are registered; pass it to other
+// components, such as the HTTP client, to expose their metrics too.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+
 func (m *Metrics) Handler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		
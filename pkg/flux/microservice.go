@@ -11,7 +11,9 @@ import (
 
 	"runtime"
 
+	"github.com/Fluxgo/flux/pkg/flux/broker"
 	"github.com/Fluxgo/flux/pkg/flux/logger"
+	"github.com/Fluxgo/flux/pkg/flux/registry"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -25,6 +27,15 @@ type Microservice struct {
 	config      *MicroserviceConfig
 	routes      []Route
 	isSetup     bool
+	registry    registry.Registry
+	instance    *registry.Instance
+	broker      broker.Broker
+	subscriptions []subscription
+}
+
+type subscription struct {
+	topic   string
+	handler broker.Handler
 }
 
 type MicroserviceConfig struct {
@@ -40,10 +51,14 @@ type MicroserviceConfig struct {
 	EnableTracing bool          `yaml:"enable_tracing" json:"enable_tracing"`
 	Metrics       bool          `yaml:"metrics" json:"metrics"`
 	HealthCheck   bool          `yaml:"health_check" json:"health_check"`
+	OpenAPI       bool          `yaml:"openapi" json:"openapi"`
 	WithDB        bool          `yaml:"with_db" json:"with_db"`
 	WithCache     bool          `yaml:"with_cache" json:"with_cache"`
 	WithQueue     bool          `yaml:"with_queue" json:"with_queue"`
 	WithAuth      bool          `yaml:"with_auth" json:"with_auth"`
+	// AdvertiseAddress is the base URL registered for other services,
+	// http://<hostname>:<port> by default.
+	AdvertiseAddress string `yaml:"advertise_address" json:"advertise_address"`
 }
 
 func DefaultMicroserviceConfig() *MicroserviceConfig {
@@ -58,6 +73,7 @@ func DefaultMicroserviceConfig() *MicroserviceConfig {
 		EnableTracing: true,
 		Metrics:       true,
 		HealthCheck:   true,
+		OpenAPI:       true,
 	}
 }
 
@@ -76,6 +92,95 @@ func (ms *Microservice) WithConfig(config *MicroserviceConfig) *Microservice {
 	return ms
 }
 
+// WithRegistry registers the service in reg on Start and deregisters it on
+// Stop.
+func (ms *Microservice) WithRegistry(reg registry.Registry) *Microservice {
+	ms.registry = reg
+	return ms
+}
+
+// WithBroker sets the broker used by Subscribe and Broker. The broker is
+// closed on Stop.
+func (ms *Microservice) WithBroker(b broker.Broker) *Microservice {
+	ms.broker = b
+	return ms
+}
+
+func (ms *Microservice) Broker() broker.Broker {
+	return ms.broker
+}
+
+// Subscribe consumes topic on Start, in a consumer group named after the
+// service, so each message is handled by one instance of the service.
+func (ms *Microservice) Subscribe(topic string, handler broker.Handler) *Microservice {
+	ms.subscriptions = append(ms.subscriptions, subscription{topic: topic, handler: handler})
+	return ms
+}
+
+func (ms *Microservice) subscribe() error {
+	if len(ms.subscriptions) == 0 {
+		return nil
+	}
+	if ms.broker == nil {
+		return fmt.Errorf("%s subscribes to topics but has no broker; use WithBroker", ms.Name)
+	}
+	for _, sub := range ms.subscriptions {
+		if err := ms.broker.Subscribe(sub.topic, ms.Name, sub.handler); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", sub.topic, err)
+		}
+	}
+	return nil
+}
+
+func (ms *Microservice) registryInstance() registry.Instance {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+
+	address := ms.config.AdvertiseAddress
+	if address == "" {
+		address = fmt.Sprintf("http://%s:%d", host, ms.config.Port)
+	}
+
+	return registry.Instance{
+		ID:       fmt.Sprintf("%s-%s-%d", ms.Name, host, ms.config.Port),
+		Service:  ms.Name,
+		Address:  address,
+		Metadata: map[string]string{"version": ms.Version},
+	}
+}
+
+func (ms *Microservice) register() error {
+	if ms.registry == nil {
+		return nil
+	}
+
+	instance := ms.registryInstance()
+	if err := ms.registry.Register(context.Background(), instance); err != nil {
+		return fmt.Errorf("failed to register service: %w", err)
+	}
+	ms.instance = &instance
+	ms.logger.Info("Registered %s as %s", instance.ID, instance.Address)
+	return nil
+}
+
+func (ms *Microservice) deregister() error {
+	if ms.registry == nil || ms.instance == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	instance := *ms.instance
+	ms.instance = nil
+	if err := ms.registry.Deregister(ctx, instance); err != nil {
+		return fmt.Errorf("failed to deregister service: %w", err)
+	}
+	return nil
+}
+
 func (ms *Microservice) AddRoute(method, path, description string, handler HandlerFunc) *Microservice {
 	ms.routes = append(ms.routes, Route{
 		Method:      method,
@@ -163,6 +268,14 @@ func (ms *Microservice) Setup() error {
 			ctx := NewContext(c, app)
 			return route.Handler(ctx)
 		})
+		app.DocumentRoute(route.Method, route.Path, &Operation{
+			Summary:   route.Description,
+			Responses: map[string]*Response{"200": {Description: "Successful operation"}},
+		})
+	}
+
+	if ms.config.OpenAPI {
+		app.EnableOpenAPI("/openapi.json")
 	}
 
 	ms.isSetup = true
@@ -179,9 +292,20 @@ func (ms *Microservice) Start() error {
 
 	ms.EnableGracefulShutdown()
 
+	if err := ms.subscribe(); err != nil {
+		return err
+	}
+	if err := ms.register(); err != nil {
+		return err
+	}
+
 	addr := fmt.Sprintf("%s:%d", ms.config.Host, ms.config.Port)
 	ms.logger.Info("Starting %s v%s on %s", ms.Name, ms.Version, addr)
-	return ms.app.server.Listen(addr)
+	if err := ms.app.server.Listen(addr); err != nil {
+		_ = ms.deregister()
+		return err
+	}
+	return nil
 }
 
 func (ms *Microservice) StartWithHotReload() error {
@@ -218,6 +342,15 @@ func (ms *Microservice) Stop() error {
 	if ms.app == nil {
 		return nil
 	}
+	// Leave the registry first so no new calls are routed here.
+	if err := ms.deregister(); err != nil {
+		ms.logger.Error("%v", err)
+	}
+	if ms.broker != nil {
+		if err := ms.broker.Close(); err != nil {
+			ms.logger.Error("Failed to close broker: %v", err)
+		}
+	}
 	return ms.app.Shutdown()
 }
 
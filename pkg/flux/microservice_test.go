@@ -0,0 +1,59 @@
+package flux
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/broker"
+	"github.com/Fluxgo/flux/pkg/flux/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMicroserviceRegistersInstance(t *testing.T) {
+	reg := registry.NewMemory()
+	config := DefaultMicroserviceConfig()
+	config.Port = 4100
+	config.AdvertiseAddress = "http://orders.internal:4100"
+
+	ms := NewMicroservice("orders", "1.2.0", "Orders").WithConfig(config).WithRegistry(reg)
+	assert.NoError(t, ms.Setup())
+	assert.NoError(t, ms.register())
+
+	instances, err := reg.Instances(context.Background(), "orders")
+	assert.NoError(t, err)
+	assert.Len(t, instances, 1)
+	assert.Equal(t, "http://orders.internal:4100", instances[0].Address)
+	assert.Equal(t, "1.2.0", instances[0].Metadata["version"])
+
+	assert.NoError(t, ms.Stop())
+	instances, _ = reg.Instances(context.Background(), "orders")
+	assert.Empty(t, instances)
+}
+
+func TestMicroserviceSubscribesAsServiceGroup(t *testing.T) {
+	b := broker.NewMemory(broker.DefaultConfig())
+	received := make(chan string, 1)
+
+	ms := NewMicroservice("billing", "1.0.0", "Billing").WithBroker(b).
+		Subscribe("orders.placed", func(ctx context.Context, msg *broker.Message) error {
+			received <- string(msg.Payload)
+			return nil
+		})
+	assert.NoError(t, ms.Setup())
+	assert.NoError(t, ms.subscribe())
+
+	_, err := b.Publish(context.Background(), "orders.placed", map[string]int{"id": 7})
+	assert.NoError(t, err)
+	select {
+	case payload := <-received:
+		assert.JSONEq(t, `{"id":7}`, payload)
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+	assert.Eventually(t, func() bool { return b.Pending("orders.placed", "billing") == 0 }, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, ms.Stop())
+	_, err = b.Publish(context.Background(), "orders.placed", "x")
+	assert.ErrorIs(t, err, broker.ErrClosed)
+}
@@ -0,0 +1,292 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux"
+)
+
+var (
+	ErrIdempotencyKeyRequired = flux.DefineError("IDEMPOTENCY_KEY_REQUIRED", http.StatusBadRequest, "Idempotency-Key header is required")
+	ErrIdempotencyKeyInvalid  = flux.DefineError("IDEMPOTENCY_KEY_INVALID", http.StatusBadRequest, "Idempotency-Key must be 1 to 255 characters")
+	ErrIdempotencyKeyInUse    = flux.DefineError("IDEMPOTENCY_KEY_IN_USE", http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+	ErrIdempotencyKeyReused   = flux.DefineError("IDEMPOTENCY_KEY_REUSED", http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+)
+
+// IdempotentResponse is the stored first response for an idempotency key.
+type IdempotentResponse struct {
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status"`
+	Headers     map[string][]string `json:"headers"`
+	Body        []byte              `json:"body"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// IdempotencyStore keeps locks and responses for idempotency keys. Lock must
+// be atomic across every instance sharing the store.
+type IdempotencyStore interface {
+	// Get returns the stored response, or nil when there is none.
+	Get(ctx context.Context, key string) (*IdempotentResponse, error)
+	// Lock reserves key for ttl and reports false when it is already held.
+	Lock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Unlock releases a lock without storing a response.
+	Unlock(ctx context.Context, key string) error
+	// Set stores resp for ttl and releases the lock.
+	Set(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error
+}
+
+type IdempotencyConfig struct {
+	Header string
+	Store  IdempotencyStore
+	// TTL is how long responses are replayed.
+	TTL time.Duration
+	// LockTimeout bounds how long a crashed request can block its key.
+	LockTimeout time.Duration
+	Methods     []string
+	// Required rejects requests to the covered methods without a key.
+	Required bool
+	// KeyFunc scopes keys, by default to the authenticated user.
+	KeyFunc func(ctx *flux.Context, key string) string
+	// Fingerprint identifies the request a key was first used with.
+	Fingerprint func(ctx *flux.Context) string
+}
+
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		Header:      "Idempotency-Key",
+		TTL:         24 * time.Hour,
+		LockTimeout: time.Minute,
+		Methods:     []string{http.MethodPost, http.MethodPatch},
+		KeyFunc: func(ctx *flux.Context, key string) string {
+			if userID := ctx.Locals("user_id"); userID != nil {
+				return fmt.Sprint(userID) + ":" + key
+			}
+			return key
+		},
+		Fingerprint: func(ctx *flux.Context) string {
+			sum := sha256.New()
+			sum.Write([]byte(ctx.Method() + "\n" + ctx.OriginalURL() + "\n"))
+			sum.Write(ctx.Body())
+			return hex.EncodeToString(sum.Sum(nil))
+		},
+	}
+}
+
+// Idempotency replays the first response for a repeated Idempotency-Key.
+// Concurrent duplicates get 409 while the first request runs, and reusing a
+// key for a different request gets 422. Server errors and streamed
+// responses are not stored, so those requests can be retried.
+func Idempotency(config IdempotencyConfig) flux.MiddlewareFunc {
+	defaults := DefaultIdempotencyConfig()
+	if config.Header == "" {
+		config.Header = defaults.Header
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryIdempotencyStore()
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = defaults.LockTimeout
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = defaults.Methods
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = defaults.KeyFunc
+	}
+	if config.Fingerprint == nil {
+		config.Fingerprint = defaults.Fingerprint
+	}
+
+	return func(next flux.HandlerFunc) flux.HandlerFunc {
+		return func(ctx *flux.Context) error {
+			if !containsMethod(config.Methods, ctx.Method()) {
+				return next(ctx)
+			}
+
+			header := ctx.Get(config.Header)
+			if header == "" {
+				if config.Required {
+					return ErrIdempotencyKeyRequired
+				}
+				return next(ctx)
+			}
+			if len(header) > 255 {
+				return ErrIdempotencyKeyInvalid
+			}
+
+			store := config.Store
+			key := config.KeyFunc(ctx, header)
+			fingerprint := config.Fingerprint(ctx)
+			reqCtx := ctx.UserContext()
+
+			stored, err := store.Get(reqCtx, key)
+			if err != nil {
+				return fmt.Errorf("failed to read idempotency key: %w", err)
+			}
+			if stored != nil {
+				return replay(ctx, stored, fingerprint)
+			}
+
+			locked, err := store.Lock(reqCtx, key, config.LockTimeout)
+			if err != nil {
+				return fmt.Errorf("failed to lock idempotency key: %w", err)
+			}
+			if !locked {
+				// The first request may have finished since Get.
+				if stored, err := store.Get(reqCtx, key); err == nil && stored != nil {
+					return replay(ctx, stored, fingerprint)
+				}
+				ctx.Set("Retry-After", "1")
+				return ErrIdempotencyKeyInUse
+			}
+
+			completed := false
+			defer func() {
+				if !completed {
+					_ = store.Unlock(context.Background(), key)
+				}
+			}()
+
+			if err := next(ctx); err != nil {
+				if err := flux.HandleError(ctx, err); err != nil {
+					return err
+				}
+			}
+
+			resp := ctx.Response()
+			if resp.StatusCode() >= http.StatusInternalServerError || resp.IsBodyStream() {
+				return nil
+			}
+
+			stored = &IdempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      resp.StatusCode(),
+				Headers:     make(map[string][]string),
+				Body:        append([]byte(nil), resp.Body()...),
+				CreatedAt:   time.Now(),
+			}
+			resp.Header.VisitAll(func(k, v []byte) {
+				name := string(k)
+				if !skipReplayHeader(name) {
+					stored.Headers[name] = append(stored.Headers[name], string(v))
+				}
+			})
+
+			if err := store.Set(reqCtx, key, stored, config.TTL); err != nil {
+				return fmt.Errorf("failed to store idempotent response: %w", err)
+			}
+			completed = true
+			return nil
+		}
+	}
+}
+
+func replay(ctx *flux.Context, stored *IdempotentResponse, fingerprint string) error {
+	if stored.Fingerprint != fingerprint {
+		return ErrIdempotencyKeyReused
+	}
+
+	for name, values := range stored.Headers {
+		ctx.Response().Header.Del(name)
+		for _, v := range values {
+			ctx.Response().Header.Add(name, v)
+		}
+	}
+	ctx.Set("Idempotent-Replayed", "true")
+	ctx.Status(stored.Status)
+	return ctx.Send(stored.Body)
+}
+
+func skipReplayHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "content-length", "date", "connection", "transfer-encoding", "server":
+		return true
+	}
+	return false
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryIdempotencyStore keeps keys in process memory. Use the Redis or
+// database store when running more than one instance.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryIdempotencyEntry
+	lastSweep time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	resp      *IdempotentResponse
+	expiresAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]*memoryIdempotencyEntry)}
+}
+
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.resp == nil || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+	return entry.resp, nil
+}
+
+func (s *MemoryIdempotencyStore) Lock(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		return false, nil
+	}
+	s.entries[key] = &memoryIdempotencyEntry{expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryIdempotencyStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.resp == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Set(_ context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = &memoryIdempotencyEntry{resp: resp, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RedisIdempotencyStore shares keys between instances through Redis.
+type RedisIdempotencyStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func NewRedisIdempotencyStore(client redis.UniversalClient, prefix string) *RedisIdempotencyStore {
+	if prefix == "" {
+		prefix = "idempotency:"
+	}
+	return &RedisIdempotencyStore{client: client, prefix: prefix}
+}
+
+func (s *RedisIdempotencyStore) Get(ctx context.Context, key string) (*IdempotentResponse, error) {
+	data, err := s.client.Get(ctx, s.prefix+"resp:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp IdempotentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotent response: %w", err)
+	}
+	return &resp, nil
+}
+
+// lockScript takes the lock only while no response is stored, so a request
+// that missed the response in Get cannot run the handler again after Set
+// released the lock.
+var lockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+if redis.call("SET", KEYS[1], 1, "NX", "PX", ARGV[1]) then
+	return 1
+end
+return 0`)
+
+func (s *RedisIdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	keys := []string{s.prefix + "lock:" + key, s.prefix + "resp:" + key}
+	locked, err := lockScript.Run(ctx, s.client, keys, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return locked == 1, nil
+}
+
+func (s *RedisIdempotencyStore) Unlock(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+"lock:"+key).Err()
+}
+
+func (s *RedisIdempotencyStore) Set(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.prefix+"resp:"+key, data, ttl)
+		pipe.Del(ctx, s.prefix+"lock:"+key)
+		return nil
+	})
+	return err
+}
+
+// IdempotencyRecord is a row of the database idempotency store. A row without
+// a response is a lock.
+type IdempotencyRecord struct {
+	IdempotencyKey string `gorm:"primaryKey;size:512"`
+	Response       []byte
+	ExpiresAt      time.Time `gorm:"index"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// GormIdempotencyStore shares keys between instances through the database.
+type GormIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewGormIdempotencyStore migrates the idempotency_keys table and returns a
+// store using it.
+func NewGormIdempotencyStore(db *gorm.DB) (*GormIdempotencyStore, error) {
+	if err := db.AutoMigrate(&IdempotencyRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate idempotency keys: %w", err)
+	}
+	return &GormIdempotencyStore{db: db}, nil
+}
+
+func (s *GormIdempotencyStore) Get(ctx context.Context, key string) (*IdempotentResponse, error) {
+	var record IdempotencyRecord
+	err := s.db.WithContext(ctx).
+		Where("idempotency_key = ? AND response IS NOT NULL AND expires_at > ?", key, time.Now()).
+		Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp IdempotentResponse
+	if err := json.Unmarshal(record.Response, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotent response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (s *GormIdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("idempotency_key = ? AND expires_at <= ?", key, time.Now()).Delete(&IdempotencyRecord{}).Error; err != nil {
+		return false, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&IdempotencyRecord{IdempotencyKey: key, ExpiresAt: time.Now().Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *GormIdempotencyStore) Unlock(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("idempotency_key = ? AND response IS NULL", key).Delete(&IdempotencyRecord{}).Error
+}
+
+func (s *GormIdempotencyStore) Set(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response: %w", err)
+	}
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&IdempotencyRecord{IdempotencyKey: key, Response: data, ExpiresAt: time.Now().Add(ttl)}).Error
+}
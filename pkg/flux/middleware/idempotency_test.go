@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newIdempotencyServer(t *testing.T, store IdempotencyStore, handler flux.HandlerFunc) *fiber.App {
+	t.Helper()
+	app, err := flux.New(&flux.Config{Name: "test", Version: "1.0.0"})
+	require.NoError(t, err)
+
+	config := DefaultIdempotencyConfig()
+	config.Store = store
+	wrapped := Idempotency(config)(handler)
+
+	server := app.Get()
+	server.Post("/orders", func(c *fiber.Ctx) error {
+		return wrapped(flux.NewContext(c, app))
+	})
+	return server
+}
+
+func postOrder(t *testing.T, server *fiber.App, key, body string) (int, string, string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := server.Test(req, -1)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), resp.Header.Get("Idempotent-Replayed")
+}
+
+func idempotencyStores(t *testing.T) map[string]IdempotencyStore {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	require.NoError(t, err)
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	gormStore, err := NewGormIdempotencyStore(db)
+	require.NoError(t, err)
+	return map[string]IdempotencyStore{
+		"memory": NewMemoryIdempotencyStore(),
+		"gorm":   gormStore,
+		"redis":  newRedisIdempotencyStore(t),
+	}
+}
+
+func newRedisIdempotencyStore(t *testing.T) *RedisIdempotencyStore {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisIdempotencyStore(client, "")
+}
+
+func TestRedisIdempotencyStoreKeepsKeyAfterResponse(t *testing.T) {
+	store := newRedisIdempotencyStore(t)
+	ctx := context.Background()
+
+	locked, err := store.Lock(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	locked, err = store.Lock(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	// Set frees the lock, but a request that missed the response in Get
+	// must still not get it.
+	require.NoError(t, store.Set(ctx, "k", &IdempotentResponse{Status: 201, Body: []byte("ok")}, time.Hour))
+	locked, err = store.Lock(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	resp, err := store.Get(ctx, "k")
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, 201, resp.Status)
+
+	require.NoError(t, store.Unlock(ctx, "other"))
+	locked, err = store.Lock(ctx, "other", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	for name, store := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var orders int32
+			server := newIdempotencyServer(t, store, func(ctx *flux.Context) error {
+				n := atomic.AddInt32(&orders, 1)
+				ctx.Set("Location", fmt.Sprintf("/orders/%d", n))
+				return ctx.Status(201).JSON(flux.H{"id": n})
+			})
+
+			status, body, replayed := postOrder(t, server, "k1", `{"sku":"a"}`)
+			assert.Equal(t, 201, status)
+			assert.Equal(t, `{"id":1}`, body)
+			assert.Empty(t, replayed)
+
+			status, body, replayed = postOrder(t, server, "k1", `{"sku":"a"}`)
+			assert.Equal(t, 201, status)
+			assert.Equal(t, `{"id":1}`, body)
+			assert.Equal(t, "true", replayed)
+			assert.EqualValues(t, 1, atomic.LoadInt32(&orders))
+
+			status, _, _ = postOrder(t, server, "k1", `{"sku":"b"}`)
+			assert.Equal(t, 422, status)
+
+			status, body, _ = postOrder(t, server, "", `{"sku":"a"}`)
+			assert.Equal(t, 201, status)
+			assert.Equal(t, `{"id":2}`, body)
+		})
+	}
+}
+
+func TestIdempotencyLocksConcurrentDuplicates(t *testing.T) {
+	for name, store := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			started, release := make(chan struct{}), make(chan struct{})
+			server := newIdempotencyServer(t, store, func(ctx *flux.Context) error {
+				close(started)
+				<-release
+				return ctx.Status(201).JSON(flux.H{"ok": true})
+			})
+
+			done := make(chan int)
+			go func() {
+				status, _, _ := postOrder(t, server, "k2", `{}`)
+				done <- status
+			}()
+			<-started
+
+			status, _, _ := postOrder(t, server, "k2", `{}`)
+			assert.Equal(t, 409, status)
+
+			close(release)
+			assert.Equal(t, 201, <-done)
+
+			status, _, replayed := postOrder(t, server, "k2", `{}`)
+			assert.Equal(t, 201, status)
+			assert.Equal(t, "true", replayed)
+		})
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	for name, store := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			server := newIdempotencyServer(t, store, func(ctx *flux.Context) error {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					return flux.ErrInternalError
+				}
+				return ctx.Status(201).JSON(flux.H{"ok": true})
+			})
+
+			status, _, _ := postOrder(t, server, "k3", `{}`)
+			assert.Equal(t, 500, status)
+
+			status, _, replayed := postOrder(t, server, "k3", `{}`)
+			assert.Equal(t, 201, status)
+			assert.Empty(t, replayed)
+		})
+	}
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/Fluxgo/flux/pkg/flux"
 	"github.com/gofiber/fiber/v2"
-	"github.com/go-playground/validator/v10"
 )
 
 type MiddlewareConfig struct {
@@ -187,6 +186,8 @@ func SecurityHeaders() fiber.Handler {
 	}
 }
 
+// CacheControl only instructs clients and proxies; see cache.ResponseCache in
+// plugins/cache for caching responses on the server.
 func CacheControl(maxAge string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		c.Set("Cache-Control", "public, max-age="+maxAge)
@@ -375,6 +376,8 @@ func Timeout(duration time.Duration) flux.MiddlewareFunc {
 	}
 }
 
+// Deprecated: validation failures are reported by flux.ValidationFailed with
+// a []flux.FieldError under details["errors"].
 type ValidationError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
@@ -390,73 +393,26 @@ func (vr *ValidationResult) ValidationFailed() bool {
 	return len(vr.Errors) > 0
 }
 
-func Validator() flux.MiddlewareFunc {
+// Validator binds the request body into a new T and validates it with the
+// application's validator, so failures have the same shape as
+// ctx.BindAndValidate. Handlers read the body with ValidatedBody.
+//
+//	c.RegisterRoute("POST", "/users", "Create user", middleware.Validator[CreateUserInput]()(c.create))
+func Validator[T any]() flux.MiddlewareFunc {
 	return func(next flux.HandlerFunc) flux.HandlerFunc {
 		return func(ctx *flux.Context) error {
-			if ctx.Body() == nil {
-				return next(ctx)
-			}
-
-			validate := ctx.App().Validator()
-			if validate == nil {
-				return next(ctx)
-			}
-
-			if err := validate.Struct(ctx.Body()); err != nil {
-				
-				validationErrors, ok := err.(validator.ValidationErrors)
-				if !ok {
-					
-					return flux.NewAppError("Validation failed", 422).WithError(err)
-				}
-				
-				result := &ValidationResult{
-					Errors: make([]ValidationError, len(validationErrors)),
-				}
-
-				for i, fieldError := range validationErrors {
-					field := fieldError.Field()
-					tag := fieldError.Tag()
-					
-					message := fmt.Sprintf("Validation failed on '%s'", tag)
-					switch tag {
-					case "required":
-						message = "This field is required"
-					case "min":
-						message = fmt.Sprintf("Should be at least %s", fieldError.Param())
-					case "max":
-						message = fmt.Sprintf("Should be at most %s", fieldError.Param())
-					case "email":
-						message = "Invalid email address"
-					case "url":
-						message = "Invalid URL format"
-					case "uuid":
-						message = "Invalid UUID format"
-					case "json":
-						message = "Invalid JSON format"
-					case "numeric":
-						message = "Must be a numeric value"
-					case "alpha":
-						message = "Must contain only alphabetic characters"
-					case "alphanum":
-						message = "Must contain only alphanumeric characters"
-					case "datetime":
-						message = fmt.Sprintf("Invalid datetime format, expected: %s", fieldError.Param())
-					}
-					
-					result.Errors[i] = ValidationError{
-						Field:   field,
-						Tag:     tag,
-						Value:   fmt.Sprintf("%v", fieldError.Value()),
-						Message: message,
-					}
-				}
-				
-				return flux.NewAppError("Validation Error", 422).
-					WithDetail("validation", result)
+			body := new(T)
+			if err := ctx.BindAndValidate(body); err != nil {
+				return err
 			}
-			
+			ctx.Locals("validated_body", body)
 			return next(ctx)
 		}
 	}
 }
+
+// ValidatedBody returns the body bound by Validator[T], or nil.
+func ValidatedBody[T any](ctx *flux.Context) *T {
+	body, _ := ctx.Locals("validated_body").(*T)
+	return body
+}
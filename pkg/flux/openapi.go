@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 
@@ -73,6 +77,7 @@ type MediaTypeObject struct {
 
 type Schema struct {
 	Type                 string                `json:"type,omitempty"`
+	Title                string                `json:"title,omitempty"`
 	Format              string                `json:"format,omitempty"`
 	Description         string                `json:"description,omitempty"`
 	Properties          map[string]*Schema    `json:"properties,omitempty"`
@@ -97,6 +102,59 @@ type SecurityScheme struct {
 	BearerFormat string `json:"bearerFormat,omitempty"`
 }
 
+type documentedRoute struct {
+	method    string
+	path      string
+	operation *Operation
+}
+
+// DocumentRoute adds an operation for a route that is not served by a
+// controller method, such as a Fiber handler or a generated resource.
+func (app *Application) DocumentRoute(method, path string, operation *Operation) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.documented = append(app.documented, documentedRoute{method: strings.ToUpper(method), path: path, operation: operation})
+}
+
+// EnableOpenAPI serves the generated OpenAPI document as JSON at path, where
+// a Gateway can collect it.
+func (app *Application) EnableOpenAPI(path string) {
+	if path == "" {
+		path = "/openapi.json"
+	}
+	app.server.Get(path, func(c *fiber.Ctx) error {
+		spec, err := app.GenerateOpenAPI()
+		if err != nil {
+			return err
+		}
+		return c.JSON(spec)
+	})
+}
+
+// PageSchema describes the Page[T] envelope returned by Paginate for items
+// of type itemType.
+func PageSchema(itemType reflect.Type) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data": {Type: "array", Items: generateSchemaFromType(itemType)},
+			"meta": generateSchemaFromType(reflect.TypeOf(PageMeta{})),
+		},
+		Required: []string{"data", "meta"},
+	}
+}
+
+// PaginationParameters lists the query parameters understood by Paginate.
+func PaginationParameters(cursor bool) []*Parameter {
+	params := []*Parameter{
+		{Name: "per_page", In: "query", Description: "Items per page", Schema: &Schema{Type: "integer"}},
+	}
+	if cursor {
+		return append(params, &Parameter{Name: "cursor", In: "query", Description: "Cursor from meta.next_cursor", Schema: &Schema{Type: "string"}})
+	}
+	return append(params, &Parameter{Name: "page", In: "query", Description: "Page number, starting at 1", Schema: &Schema{Type: "integer"}})
+}
+
 func getMethodAnnotations(methodName string) []string {
 	annotations := make([]string, 0)
 	for _, httpMethod := range []string{"Get", "Post", "Put", "Delete", "Patch"} {
@@ -223,6 +281,23 @@ func (app *Application) GenerateOpenAPI() (*OpenAPISpec, error) {
 		}
 	}
 
+	for _, route := range app.documented {
+		pathItem := spec.Paths[route.path]
+		switch route.method {
+		case "GET":
+			pathItem.Get = route.operation
+		case "POST":
+			pathItem.Post = route.operation
+		case "PUT":
+			pathItem.Put = route.operation
+		case "DELETE":
+			pathItem.Delete = route.operation
+		case "PATCH":
+			pathItem.Patch = route.operation
+		}
+		spec.Paths[route.path] = pathItem
+	}
+
 	return spec, nil
 }
 
@@ -277,15 +352,20 @@ func generateSchemaFromType(t reflect.Type) *Schema {
 	switch t.Kind() {
 	case reflect.String:
 		return &Schema{Type: "string"}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return &Schema{Type: "integer"}
 	case reflect.Float32, reflect.Float64:
 		return &Schema{Type: "number"}
 	case reflect.Bool:
 		return &Schema{Type: "boolean"}
 	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(gorm.DeletedAt{}) {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
 		schema := &Schema{
 			Type:       "object",
+			Title:      t.Name(),
 			Properties: make(map[string]*Schema),
 			Required:   make([]string, 0),
 		}
@@ -298,6 +378,16 @@ func generateSchemaFromType(t reflect.Type) *Schema {
 			}
 
 			name := strings.Split(jsonTag, ",")[0]
+			if name == "" && field.Anonymous {
+				// Embedded structs such as gorm.Model are flattened like encoding/json does.
+				if embedded := generateSchemaFromType(field.Type); embedded != nil && embedded.Type == "object" {
+					for k, v := range embedded.Properties {
+						schema.Properties[k] = v
+					}
+					schema.Required = append(schema.Required, embedded.Required...)
+					continue
+				}
+			}
 			if name == "" {
 				name = field.Name
 			}
@@ -0,0 +1,122 @@
+// Package outbox publishes messages reliably after database writes. Messages
+// are recorded in the outbox_messages table in the same transaction as the
+// data they describe, and a Relay publishes them once the transaction has
+// committed. A crash between the write and the publish then delays the
+// message instead of losing it.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/broker"
+	"github.com/Fluxgo/flux/pkg/flux/queue"
+	"gorm.io/gorm"
+)
+
+type Message struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// Topic is the broker topic or queue job type.
+	Topic string `gorm:"size:255;not null" json:"topic"`
+	// AggregateID groups messages that must be published in order, such as
+	// all messages about one order. Messages without one are unordered.
+	AggregateID string     `gorm:"size:255;index" json:"aggregate_id,omitempty"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	AvailableAt time.Time  `gorm:"index;not null" json:"available_at"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Migrate creates the outbox table.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Message{}); err != nil {
+		return fmt.Errorf("failed to migrate outbox: %w", err)
+	}
+	return nil
+}
+
+// Record adds a message to the outbox using tx, which should be the
+// transaction that writes the data the message is about. Payloads other
+// than []byte and json.RawMessage are encoded as JSON.
+func Record(tx *gorm.DB, topic, aggregateID string, payload interface{}) error {
+	if topic == "" {
+		return errors.New("outbox: topic is required")
+	}
+
+	var data []byte
+	switch p := payload.(type) {
+	case []byte:
+		data = p
+	case json.RawMessage:
+		data = p
+	default:
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode outbox payload: %w", err)
+		}
+		data = encoded
+	}
+
+	msg := &Message{Topic: topic, AggregateID: aggregateID, Payload: string(data), AvailableAt: time.Now()}
+	if err := tx.Create(msg).Error; err != nil {
+		return fmt.Errorf("failed to record outbox message: %w", err)
+	}
+	return nil
+}
+
+// Publisher delivers relayed messages. An error leaves the message in the
+// outbox to be retried.
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+type PublisherFunc func(ctx context.Context, msg *Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// BrokerPublisher publishes messages to b. The outbox and aggregate IDs are
+// sent as the outbox_id and aggregate_id headers, so consumers can drop
+// duplicates.
+func BrokerPublisher(b broker.Broker) Publisher {
+	return PublisherFunc(func(ctx context.Context, msg *Message) error {
+		headers := map[string]string{"outbox_id": fmt.Sprint(msg.ID)}
+		if msg.AggregateID != "" {
+			headers["aggregate_id"] = msg.AggregateID
+		}
+		_, err := b.Publish(broker.WithHeaders(ctx, headers), msg.Topic, json.RawMessage(msg.Payload))
+		return err
+	})
+}
+
+// Queue is the part of queue.Queue used by QueuePublisher.
+type Queue interface {
+	Enqueue(jobType string, data map[string]interface{}, maxRetries int) (*queue.Job, error)
+}
+
+// QueuePublisher enqueues each message as a job of type Topic. The job data
+// is the payload if it is a JSON object, and {"payload": ...} otherwise.
+func QueuePublisher(q Queue, maxRetries int) Publisher {
+	return PublisherFunc(func(ctx context.Context, msg *Message) error {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.Payload), &data); err != nil || data == nil {
+			var value interface{}
+			if err := json.Unmarshal([]byte(msg.Payload), &value); err != nil {
+				return fmt.Errorf("failed to decode outbox payload: %w", err)
+			}
+			data = map[string]interface{}{"payload": value}
+		}
+		_, err := q.Enqueue(msg.Topic, data, maxRetries)
+		return err
+	})
+}
@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/broker"
+	"github.com/Fluxgo/flux/pkg/flux/queue"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type order struct {
+	ID    uint `gorm:"primaryKey"`
+	Total int
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, Migrate(db))
+	require.NoError(t, db.AutoMigrate(&order{}))
+	return db
+}
+
+func TestRecordIsPartOfTheTransaction(t *testing.T) {
+	db := newTestDB(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		o := &order{Total: 10}
+		if err := tx.Create(o).Error; err != nil {
+			return err
+		}
+		if err := Record(tx, "orders.placed", "order-1", map[string]int{"total": 10}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	var count int64
+	db.Model(&Message{}).Count(&count)
+	assert.Zero(t, count)
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return Record(tx, "orders.placed", "order-1", map[string]int{"total": 10})
+	}))
+	var msg Message
+	require.NoError(t, db.First(&msg).Error)
+	assert.JSONEq(t, `{"total":10}`, msg.Payload)
+	assert.Nil(t, msg.PublishedAt)
+}
+
+func TestRelayKeepsAggregateOrderOnFailure(t *testing.T) {
+	db := newTestDB(t)
+	for _, m := range []struct{ topic, aggregate string }{
+		{"orders.placed", "order-1"},
+		{"orders.paid", "order-1"},
+		{"orders.placed", "order-2"},
+		{"audit", ""},
+	} {
+		require.NoError(t, Record(db, m.topic, m.aggregate, m.topic))
+	}
+
+	var published []string
+	fail := true
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, msg *Message) error {
+		if msg.ID == 1 && fail {
+			return errors.New("broker down")
+		}
+		published = append(published, msg.AggregateID+":"+msg.Topic)
+		return nil
+	}), Config{Backoff: time.Hour, MaxAttempts: 3})
+
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"order-2:orders.placed", ":audit"}, published)
+
+	var first Message
+	require.NoError(t, db.First(&first, 1).Error)
+	assert.Equal(t, 1, first.Attempts)
+	assert.Equal(t, "broker down", first.LastError)
+	assert.True(t, first.AvailableAt.After(time.Now().Add(59*time.Minute)))
+
+	// order-1 waits behind its failed message until the retry is due.
+	n, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	fail = false
+	require.NoError(t, db.Model(&Message{}).Where("id = ?", 1).Update("available_at", time.Now()).Error)
+	_, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order-2:orders.placed", ":audit", "order-1:orders.placed", "order-1:orders.paid"}, published)
+}
+
+func TestRelayCleanup(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Record(db, "a", "", "x"))
+	require.NoError(t, Record(db, "b", "", "y"))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, db.Model(&Message{}).Where("id = ?", 1).Update("published_at", old).Error)
+
+	relay := NewRelay(db, PublisherFunc(func(context.Context, *Message) error { return nil }), DefaultConfig())
+	deleted, err := relay.Cleanup(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var count int64
+	db.Model(&Message{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+type fakeQueue struct{ jobs []*queue.Job }
+
+func (q *fakeQueue) Enqueue(jobType string, data map[string]interface{}, maxRetries int) (*queue.Job, error) {
+	job := &queue.Job{Type: jobType, Data: data, MaxRetries: maxRetries}
+	q.jobs = append(q.jobs, job)
+	return job, nil
+}
+
+func TestPublishers(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, Record(db, "orders.placed", "order-1", map[string]int{"id": 1}))
+	require.NoError(t, Record(db, "orders.placed", "", 42))
+
+	q := &fakeQueue{}
+	_, err := NewRelay(db, QueuePublisher(q, 3), DefaultConfig()).RelayOnce(context.Background())
+	require.NoError(t, err)
+	require.Len(t, q.jobs, 2)
+	assert.Equal(t, "orders.placed", q.jobs[0].Type)
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, q.jobs[0].Data)
+	assert.Equal(t, map[string]interface{}{"payload": float64(42)}, q.jobs[1].Data)
+
+	b := broker.NewMemory(broker.DefaultConfig())
+	defer b.Close()
+	require.NoError(t, Record(db, "orders.paid", "order-1", map[string]int{"id": 1}))
+	_, err = NewRelay(db, BrokerPublisher(b), DefaultConfig()).RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	published := b.Published("orders.paid")
+	require.Len(t, published, 1)
+	assert.JSONEq(t, `{"id":1}`, string(published[0].Payload))
+	assert.Equal(t, "3", published[0].Headers["outbox_id"])
+	assert.Equal(t, "order-1", published[0].Headers["aggregate_id"])
+}
+
+func TestRelayStartStop(t *testing.T) {
+	db := newTestDB(t)
+	published := make(chan uint, 1)
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, msg *Message) error {
+		published <- msg.ID
+		return nil
+	}), Config{Interval: 10 * time.Millisecond})
+
+	relay.Start()
+	defer relay.Stop()
+	require.NoError(t, Record(db, "a", "", "x"))
+
+	select {
+	case id := <-published:
+		assert.Equal(t, uint(1), id)
+	case <-time.After(time.Second):
+		t.Fatal("relay did not publish")
+	}
+}
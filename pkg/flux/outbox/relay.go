@@ -0,0 +1,235 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Logger interface {
+	Error(format string, args ...interface{})
+}
+
+type Config struct {
+	// Interval is how often the relay polls the outbox.
+	Interval  time.Duration `yaml:"interval" json:"interval"`
+	BatchSize int           `yaml:"batch_size" json:"batch_size"`
+	// MaxAttempts gives up on a message after that many failed publishes.
+	// Failed messages stay in the table with their last error.
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+	// Backoff is the delay after the first failure. It doubles with every
+	// further failure up to MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	// Retention is how long published messages are kept before cleanup
+	// deletes them. Zero deletes them on the next cleanup.
+	Retention       time.Duration `yaml:"retention" json:"retention"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" json:"cleanup_interval"`
+	Logger          Logger        `yaml:"-" json:"-"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:        time.Second,
+		BatchSize:       100,
+		MaxAttempts:     10,
+		Backoff:         time.Second,
+		MaxBackoff:      5 * time.Minute,
+		Retention:       24 * time.Hour,
+		CleanupInterval: time.Hour,
+	}
+}
+
+// Relay publishes recorded messages in the order they were recorded. When a
+// message fails, later messages with the same aggregate ID wait until it
+// has been published or given up on. Rows are locked while a batch is
+// published, so relays running in several instances do not publish the
+// same message twice or out of order.
+type Relay struct {
+	db        *gorm.DB
+	publisher Publisher
+	config    Config
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewRelay(db *gorm.DB, publisher Publisher, config Config) *Relay {
+	defaults := DefaultConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaults.Backoff
+	}
+	if config.MaxBackoff < config.Backoff {
+		config.MaxBackoff = config.Backoff
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = defaults.CleanupInterval
+	}
+	return &Relay{db: db, publisher: publisher, config: config}
+}
+
+// Start polls the outbox in the background until Stop.
+func (r *Relay) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done)
+}
+
+func (r *Relay) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+
+	for {
+		// Keep going while batches come back full.
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.logError("%v", err)
+			}
+			if err != nil || n < r.config.BatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) >= r.config.CleanupInterval {
+			if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				r.logError("%v", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops polling and waits for the current batch to finish.
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// RelayOnce publishes one batch of due messages and returns how many it
+// attempted.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	attempted := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Due messages, minus those queued behind an earlier message of
+		// their aggregate that is waiting for a retry.
+		now := time.Now()
+		var messages []Message
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("published_at IS NULL AND attempts < ? AND available_at <= ?", r.config.MaxAttempts, now).
+			Where(`NOT EXISTS (SELECT 1 FROM outbox_messages earlier
+				WHERE earlier.aggregate_id = outbox_messages.aggregate_id AND earlier.aggregate_id <> ''
+				AND earlier.id < outbox_messages.id AND earlier.published_at IS NULL
+				AND earlier.attempts < ? AND earlier.available_at > ?)`, r.config.MaxAttempts, now).
+			Order("id").
+			Limit(r.config.BatchSize).
+			Find(&messages).Error
+		if err != nil {
+			return fmt.Errorf("failed to load outbox messages: %w", err)
+		}
+
+		blocked := make(map[string]bool)
+		for i := range messages {
+			msg := &messages[i]
+			if msg.AggregateID != "" && blocked[msg.AggregateID] {
+				continue
+			}
+
+			attempted++
+			updates := r.publish(ctx, msg)
+			if _, failed := updates["last_error"]; failed && msg.AggregateID != "" {
+				blocked[msg.AggregateID] = true
+			}
+			if err := tx.Model(msg).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update outbox message %d: %w", msg.ID, err)
+			}
+		}
+		return nil
+	})
+	return attempted, err
+}
+
+func (r *Relay) publish(ctx context.Context, msg *Message) map[string]interface{} {
+	err := r.publisher.Publish(ctx, msg)
+	if err == nil {
+		return map[string]interface{}{"published_at": time.Now()}
+	}
+
+	attempts := msg.Attempts + 1
+	if attempts >= r.config.MaxAttempts {
+		r.logError("Giving up on outbox message %d (%s) after %d attempts: %v", msg.ID, msg.Topic, attempts, err)
+	} else {
+		r.logError("Failed to publish outbox message %d (%s): %v", msg.ID, msg.Topic, err)
+	}
+	return map[string]interface{}{
+		"attempts":     attempts,
+		"last_error":   err.Error(),
+		"available_at": time.Now().Add(r.backoff(attempts)),
+	}
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.Backoff
+	for i := 1; i < attempts && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.config.MaxBackoff {
+		delay = r.config.MaxBackoff
+	}
+	return delay
+}
+
+// Cleanup deletes messages published longer than Retention ago and returns
+// how many it deleted.
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-r.config.Retention)
+	result := r.db.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at <= ?", cutoff).
+		Delete(&Message{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to clean up outbox: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *Relay) logError(format string, args ...interface{}) {
+	if r.config.Logger != nil {
+		r.config.Logger.Error(format, args...)
+	}
+}
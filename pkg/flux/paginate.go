@@ -0,0 +1,289 @@
+package flux
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// PaginationConfig controls how Paginate reads the page from the query string.
+type PaginationConfig struct {
+	DefaultPerPage int `yaml:"default_per_page" json:"default_per_page"`
+	MaxPerPage     int `yaml:"max_per_page" json:"max_per_page"`
+	// DisableCount skips the COUNT(*) query, which is expensive on large
+	// tables. Total, TotalPages and the "last" link are then omitted.
+	DisableCount bool `yaml:"disable_count" json:"disable_count"`
+	// Cursor switches to keyset pagination on CursorField, which must be
+	// unique and sortable. Prefix it with "-" for descending order.
+	Cursor      bool   `yaml:"cursor" json:"cursor"`
+	CursorField string `yaml:"cursor_field" json:"cursor_field"`
+}
+
+func DefaultPaginationConfig() PaginationConfig {
+	return PaginationConfig{
+		DefaultPerPage: 20,
+		MaxPerPage:     100,
+		CursorField:    "id",
+	}
+}
+
+// PageMeta is the "meta" part of the list envelope.
+type PageMeta struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      *int64 `json:"total,omitempty"`
+	TotalPages *int   `json:"total_pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// Page is the standard envelope for list endpoints.
+type Page[T any] struct {
+	Data []T      `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
+
+// Paginate runs query for the page requested by ?page=&per_page= (or
+// ?cursor=&per_page= in cursor mode), sets RFC 8288 Link headers and returns
+// the page envelope.
+//
+//	page, err := flux.Paginate[User](ctx, app.DB().Model(&User{}).Where("active = ?", true))
+func Paginate[T any](ctx *Context, query *gorm.DB, config ...PaginationConfig) (*Page[T], error) {
+	result := &Page[T]{Data: make([]T, 0)}
+	meta, err := paginate(ctx, query, &result.Data, config...)
+	if err != nil {
+		return nil, err
+	}
+	result.Meta = meta
+	return result, nil
+}
+
+// paginate is Paginate for a model only known at runtime. dest must point to
+// a slice of the model type.
+func paginate(ctx *Context, query *gorm.DB, dest interface{}, config ...PaginationConfig) (PageMeta, error) {
+	cfg := DefaultPaginationConfig()
+	if len(config) > 0 {
+		cfg = normalizePaginationConfig(config[0])
+	}
+
+	perPage := cfg.DefaultPerPage
+	if v, err := strconv.Atoi(ctx.Ctx.Query("per_page")); err == nil && v > 0 {
+		perPage = v
+	}
+	if perPage > cfg.MaxPerPage {
+		perPage = cfg.MaxPerPage
+	}
+
+	if cfg.Cursor {
+		return paginateCursor(ctx, query, dest, cfg, perPage)
+	}
+	return paginateOffset(ctx, query, dest, cfg, perPage)
+}
+
+func normalizePaginationConfig(cfg PaginationConfig) PaginationConfig {
+	defaults := DefaultPaginationConfig()
+	if cfg.DefaultPerPage <= 0 {
+		cfg.DefaultPerPage = defaults.DefaultPerPage
+	}
+	if cfg.MaxPerPage <= 0 {
+		cfg.MaxPerPage = defaults.MaxPerPage
+	}
+	if cfg.CursorField == "" {
+		cfg.CursorField = defaults.CursorField
+	}
+	return cfg
+}
+
+func paginateOffset(ctx *Context, query *gorm.DB, dest interface{}, cfg PaginationConfig, perPage int) (PageMeta, error) {
+	page := 1
+	if v, err := strconv.Atoi(ctx.Ctx.Query("page")); err == nil && v > 0 {
+		page = v
+	}
+
+	// Bound page so the offset and the next page number cannot overflow.
+	if page >= math.MaxInt/perPage {
+		return PageMeta{}, ErrBadRequest.WithDetail("page", "page is out of range")
+	}
+
+	meta := PageMeta{Page: page, PerPage: perPage}
+
+	if !cfg.DisableCount {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return meta, fmt.Errorf("failed to count records: %w", err)
+		}
+		totalPages := int((total + int64(perPage) - 1) / int64(perPage))
+		meta.Total = &total
+		meta.TotalPages = &totalPages
+	}
+
+	// One extra row tells us whether there is a next page without a COUNT.
+	if err := query.Session(&gorm.Session{}).Offset((page - 1) * perPage).Limit(perPage + 1).Find(dest).Error; err != nil {
+		return meta, fmt.Errorf("failed to fetch records: %w", err)
+	}
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > perPage {
+		rows.Set(rows.Slice(0, perPage))
+		meta.HasMore = true
+	}
+
+	links := map[string]url.Values{"first": {"page": {"1"}}}
+	if page > 1 {
+		links["prev"] = url.Values{"page": {strconv.Itoa(page - 1)}}
+	}
+	if meta.HasMore {
+		links["next"] = url.Values{"page": {strconv.Itoa(page + 1)}}
+	}
+	if meta.TotalPages != nil && *meta.TotalPages > 0 {
+		links["last"] = url.Values{"page": {strconv.Itoa(*meta.TotalPages)}}
+	}
+	setLinkHeader(ctx, links)
+
+	return meta, nil
+}
+
+func paginateCursor(ctx *Context, query *gorm.DB, dest interface{}, cfg PaginationConfig, perPage int) (PageMeta, error) {
+	column := strings.TrimPrefix(cfg.CursorField, "-")
+	desc := strings.HasPrefix(cfg.CursorField, "-")
+	meta := PageMeta{PerPage: perPage}
+
+	rows := reflect.ValueOf(dest).Elem()
+	s, err := parseModel(reflect.New(rows.Type().Elem()).Interface(), query.NamingStrategy)
+	if err != nil {
+		return meta, err
+	}
+	field := s.LookUpField(column)
+	if field == nil {
+		return meta, fmt.Errorf("unknown cursor field %q on %s", column, s.Name)
+	}
+
+	q := query.Session(&gorm.Session{})
+	if cursor := ctx.Ctx.Query("cursor"); cursor != "" {
+		value, err := decodeCursor(cursor)
+		if err != nil {
+			return meta, ErrBadRequest.WithDetail("cursor", "invalid cursor")
+		}
+		col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		if desc {
+			q = q.Where(clause.Lt{Column: col, Value: value})
+		} else {
+			q = q.Where(clause.Gt{Column: col, Value: value})
+		}
+	}
+
+	if !cfg.DisableCount {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return meta, fmt.Errorf("failed to count records: %w", err)
+		}
+		meta.Total = &total
+	}
+
+	order := clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Desc: desc}
+	if err := q.Order(order).Limit(perPage + 1).Find(dest).Error; err != nil {
+		return meta, fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	if rows.Len() > perPage {
+		rows.Set(rows.Slice(0, perPage))
+		meta.HasMore = true
+
+		value, _ := field.ValueOf(ctx.Context(), rows.Index(perPage-1))
+		cursor, err := encodeCursor(value)
+		if err != nil {
+			return meta, err
+		}
+		meta.NextCursor = cursor
+		setLinkHeader(ctx, map[string]url.Values{"next": {"cursor": {cursor}}})
+	}
+
+	return meta, nil
+}
+
+var modelSchemas sync.Map
+
+// parseModel returns the cached GORM schema for model.
+func parseModel(model interface{}, namer schema.Namer) (*schema.Schema, error) {
+	s, err := schema.Parse(model, &modelSchemas, namer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	return s, nil
+}
+
+func encodeCursor(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string) (interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	// UseNumber keeps int64 keys above 2^53 exact.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	}
+	return nil, fmt.Errorf("unsupported cursor value")
+}
+
+// setLinkHeader writes an RFC 8288 Link header, keeping the current query
+// string (filters, sorting, per_page) and overriding the paging parameters.
+func setLinkHeader(ctx *Context, links map[string]url.Values) {
+	base := url.Values{}
+	ctx.Ctx.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		base.Add(string(key), string(value))
+	})
+
+	var parts []string
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		override, ok := links[rel]
+		if !ok {
+			continue
+		}
+
+		query := url.Values{}
+		for k, v := range base {
+			query[k] = v
+		}
+		query.Del("page")
+		query.Del("cursor")
+		for k, v := range override {
+			query[k] = v
+		}
+
+		parts = append(parts, fmt.Sprintf(`<%s?%s>; rel="%s"`, ctx.Ctx.Path(), query.Encode(), rel))
+	}
+
+	if len(parts) > 0 {
+		ctx.Ctx.Set(fiber.HeaderLink, strings.Join(parts, ", "))
+	}
+}
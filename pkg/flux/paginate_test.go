@@ -0,0 +1,136 @@
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type pageItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Price     float64   `json:"price"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newTestDatabase(t *testing.T, models ...interface{}) *Database {
+	db, err := NewDatabase(&DatabaseConfig{
+		Driver:       "sqlite",
+		Name:         ":memory:",
+		MaxOpenConns: 1,
+		LogLevel:     gormlogger.Silent,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func seedPageItems(t *testing.T, db *Database, n int) {
+	for i := 1; i <= n; i++ {
+		status := "active"
+		if i%3 == 0 {
+			status = "archived"
+		}
+		item := pageItem{Name: fmt.Sprintf("item-%02d", i), Status: status, Price: float64(i), CreatedAt: time.Date(2024, 1, i, 0, 0, 0, 0, time.UTC)}
+		if err := db.Create(&item); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPaginateOffset(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &pageItem{})
+	seedPageItems(t, app.database, 25)
+
+	app.Get().Get("/items", func(c *fiber.Ctx) error {
+		ctx := NewContext(c, app)
+		page, err := Paginate[pageItem](ctx, app.DB().Model(&pageItem{}).Order("id"), PaginationConfig{MaxPerPage: 10})
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(page)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/items?page=2&per_page=50&status=x", nil))
+	assert.NoError(t, err)
+
+	var page Page[pageItem]
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Len(t, page.Data, 10)
+	assert.Equal(t, uint(11), page.Data[0].ID)
+	assert.Equal(t, 10, page.Meta.PerPage)
+	assert.Equal(t, int64(25), *page.Meta.Total)
+	assert.Equal(t, 3, *page.Meta.TotalPages)
+	assert.True(t, page.Meta.HasMore)
+	assert.Equal(t,
+		`</items?page=1&per_page=50&status=x>; rel="first", </items?page=1&per_page=50&status=x>; rel="prev", `+
+			`</items?page=3&per_page=50&status=x>; rel="next", </items?page=3&per_page=50&status=x>; rel="last"`,
+		resp.Header.Get("Link"))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/items?page=9223372036854775807", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestPaginateCursor(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &pageItem{})
+	seedPageItems(t, app.database, 7)
+
+	app.Get().Get("/items", func(c *fiber.Ctx) error {
+		ctx := NewContext(c, app)
+		page, err := Paginate[pageItem](ctx, app.DB().Model(&pageItem{}), PaginationConfig{
+			Cursor:       true,
+			CursorField:  "-id",
+			DisableCount: true,
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(page)
+	})
+
+	var ids []uint
+	url := "/items?per_page=3"
+	for i := 0; i < 5 && url != ""; i++ {
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		assert.NoError(t, err)
+
+		var page Page[pageItem]
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		assert.Nil(t, page.Meta.Total)
+		for _, item := range page.Data {
+			ids = append(ids, item.ID)
+		}
+
+		url = ""
+		if page.Meta.HasMore {
+			url = "/items?per_page=3&cursor=" + page.Meta.NextCursor
+			assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+		}
+	}
+	assert.Equal(t, []uint{7, 6, 5, 4, 3, 2, 1}, ids)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/items?cursor=!!", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// Keys beyond float64 precision survive the round trip.
+	cursor, err := encodeCursor(int64(1<<53 + 1))
+	assert.NoError(t, err)
+	value, err := decodeCursor(cursor)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<53+1), value)
+}
@@ -0,0 +1,189 @@
+package flux
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/Fluxgo/flux/pkg/flux/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// ErrorFormatJSON renders errors as {"error": true, "message": "..."}.
+	ErrorFormatJSON = "json"
+	// ErrorFormatProblem renders errors as RFC 7807 application/problem+json.
+	ErrorFormatProblem = "problem"
+)
+
+// Problem is an RFC 7807 problem details object. Extensions are written as
+// additional top-level members.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	TraceID    string
+	Code       string
+	Extensions map[string]interface{}
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		out[k] = v
+	}
+	out["type"] = p.Type
+	out["title"] = p.Title
+	out["status"] = p.Status
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		out["instance"] = p.Instance
+	}
+	if p.TraceID != "" {
+		out["trace_id"] = p.TraceID
+	}
+	if p.Code != "" {
+		out["code"] = p.Code
+	}
+	return json.Marshal(out)
+}
+
+// FieldError describes one invalid field in a validation failure.
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationFailed wraps validator errors in the 422 AppError used by every
+// validation path, with the field errors under details["errors"].
+func ValidationFailed(err error) *AppError {
+	return ErrValidationFailed.WithDetail("errors", fieldErrors(err)).WithError(err)
+}
+
+func fieldErrors(err error) []FieldError {
+	return localizedFieldErrors(nil, err)
+}
+
+func localizedFieldErrors(localizer *i18n.Localizer, err error) []FieldError {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		out := make([]FieldError, len(verrs))
+		for i, e := range verrs {
+			out[i] = FieldError{Field: fieldPath(e), Tag: e.Tag(), Message: translateValidation(localizer, e)}
+		}
+		return out
+	}
+
+	var ferrs ValidationErrors
+	if errors.As(err, &ferrs) {
+		out := make([]FieldError, 0, len(ferrs))
+		for field, message := range ferrs {
+			out = append(out, FieldError{Field: field, Message: message})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+		return out
+	}
+	return nil
+}
+
+// NewProblem converts any error returned from a handler into a Problem.
+func NewProblem(c *fiber.Ctx, err error) Problem {
+	appErr, message := publicError(c, err)
+
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(appErr.StatusCode),
+		Status:   appErr.StatusCode,
+		Detail:   message,
+		Instance: c.Path(),
+		Code:     appErr.Code,
+	}
+	if traceID, ok := c.Locals("trace_id").(string); ok {
+		p.TraceID = traceID
+	}
+	if len(appErr.Details) > 0 {
+		p.Extensions = make(map[string]interface{}, len(appErr.Details))
+		for k, v := range appErr.Details {
+			p.Extensions[k] = v
+		}
+	}
+	return p
+}
+
+// publicError resolves err and decides what the client may see. Outside
+// production the whole error chain is returned to ease debugging. In
+// production only the safe message is returned, server error details are
+// dropped, and the internal error is logged with the request's trace ID.
+func publicError(c *fiber.Ctx, err error) (*AppError, string) {
+	appErr := resolveError(err)
+	if appErr.StatusCode == 0 {
+		clone := *appErr
+		clone.StatusCode = http.StatusInternalServerError
+		appErr = &clone
+	}
+
+	translated := false
+	if localizer := localizerFor(c); localizer != nil {
+		appErr, translated = localizeError(localizer, appErr)
+	}
+
+	if getEnvironment() != "production" {
+		if translated {
+			return appErr, appErr.Message
+		}
+		return appErr, err.Error()
+	}
+
+	if appErr.Err != nil || appErr.StatusCode >= http.StatusInternalServerError {
+		NewContext(c, nil).Logger().Error("%s %s failed: %v", c.Method(), c.Path(), err)
+	}
+	if appErr.StatusCode >= http.StatusInternalServerError {
+		appErr = appErr.WithDetails(nil)
+	}
+	return appErr, appErr.Message
+}
+
+// localizeError translates the catalog message and validation field errors
+// of appErr, reporting whether the message was translated.
+func localizeError(localizer *i18n.Localizer, appErr *AppError) (*AppError, bool) {
+	message := translateError(localizer, appErr)
+	translated := message != appErr.Message
+
+	clone := *appErr
+	clone.Message = message
+	if _, ok := appErr.Details["errors"]; ok && appErr.Err != nil {
+		if errs := localizedFieldErrors(localizer, appErr.Err); errs != nil {
+			clone.Details = make(map[string]interface{}, len(appErr.Details))
+			for k, v := range appErr.Details {
+				clone.Details[k] = v
+			}
+			clone.Details["errors"] = errs
+		}
+	}
+	return &clone, translated
+}
+
+// handleError is the fiber error handler for applications created with New.
+func (app *Application) handleError(c *fiber.Ctx, err error) error {
+	if app.config != nil && app.config.ErrorFormat == ErrorFormatProblem {
+		return writeProblem(c, NewProblem(c, err))
+	}
+	return defaultErrorHandler(c, err)
+}
+
+func writeProblem(c *fiber.Ctx, p Problem) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	c.Status(p.Status)
+	c.Set(fiber.HeaderContentType, "application/problem+json")
+	return c.Send(body)
+}
@@ -0,0 +1,70 @@
+package flux
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemDetails(t *testing.T) {
+	app := newTestApplication()
+	app.config.ErrorFormat = ErrorFormatProblem
+	app.server = fiber.New(fiber.Config{ErrorHandler: app.handleError})
+	app.server.Use(recover.New())
+	app.server.Use(func(c *fiber.Ctx) error {
+		c.Locals("trace_id", "trace-1")
+		return c.Next()
+	})
+
+	app.Get().Get("/app-error", func(c *fiber.Ctx) error {
+		return NewAppError("Order is locked", 409).WithCode("ORDER_LOCKED").WithDetail("order_id", 7)
+	})
+	app.Get().Get("/context-error", func(c *fiber.Ctx) error {
+		return NewContext(c, app).Error(ErrForbidden)
+	})
+	app.Get().Post("/validate", func(c *fiber.Ctx) error {
+		var body struct {
+			Email string `json:"email" validate:"required,email"`
+		}
+		return NewContext(c, app).BindAndValidate(&body)
+	})
+	app.Get().Get("/panic", func(c *fiber.Ctx) error {
+		panic("boom")
+	})
+
+	get := func(method, path string) map[string]interface{} {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), path)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, float64(resp.StatusCode), body["status"], path)
+		return body
+	}
+
+	body := get("GET", "/app-error")
+	assert.Equal(t, "about:blank", body["type"])
+	assert.Equal(t, "Conflict", body["title"])
+	assert.Equal(t, "Order is locked", body["detail"])
+	assert.Equal(t, "/app-error", body["instance"])
+	assert.Equal(t, "trace-1", body["trace_id"])
+	assert.Equal(t, "ORDER_LOCKED", body["code"])
+	assert.Equal(t, float64(7), body["order_id"])
+
+	assert.Equal(t, float64(403), get("GET", "/context-error")["status"])
+	assert.Equal(t, float64(404), get("GET", "/missing")["status"])
+	assert.Equal(t, float64(500), get("GET", "/panic")["status"])
+
+	body = get("POST", "/validate")
+	assert.Equal(t, float64(422), body["status"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"field": "email", "tag": "required", "message": "The email field is required",
+	}}, body["errors"])
+}
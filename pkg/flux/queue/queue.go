@@ -136,7 +136,12 @@ func (q *Queue) processJobs() {
 				if err := handler(&job); err != nil {
 					job.Attempts++
 					if job.Attempts < job.MaxRetries {
-						q.client.LPush(q.ctx, "queue", job.ID)
+						// Keep the job data for the retry.
+						if data, err := json.Marshal(job); err == nil {
+							q.client.Set(q.ctx, key, data, 0)
+							q.client.LPush(q.ctx, "queue", job.ID)
+							continue
+						}
 					}
 				}
 			}
@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+type DNSConfig struct {
+	// Domain is appended to SRV lookups: _<service>._tcp.<Domain>.
+	Domain string
+	// Scheme of the instance addresses, "http" by default.
+	Scheme   string
+	Resolver *net.Resolver
+}
+
+// DNS finds instances through SRV records, as published by Consul,
+// Kubernetes headless services or a plain DNS zone. Register and Deregister
+// do nothing.
+type DNS struct {
+	config DNSConfig
+}
+
+func NewDNS(config DNSConfig) *DNS {
+	if config.Scheme == "" {
+		config.Scheme = "http"
+	}
+	if config.Resolver == nil {
+		config.Resolver = net.DefaultResolver
+	}
+	return &DNS{config: config}
+}
+
+func (d *DNS) Register(context.Context, Instance) error {
+	return nil
+}
+
+func (d *DNS) Deregister(context.Context, Instance) error {
+	return nil
+}
+
+func (d *DNS) Instances(ctx context.Context, service string) ([]Instance, error) {
+	_, records, err := d.config.Resolver.LookupSRV(ctx, service, "tcp", d.config.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", service, err)
+	}
+
+	instances := make([]Instance, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		hostPort := net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))
+		instances = append(instances, Instance{
+			ID:      service + "-" + hostPort,
+			Service: service,
+			Address: d.config.Scheme + "://" + hostPort,
+		})
+	}
+	return instances, nil
+}
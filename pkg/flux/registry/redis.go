@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisConfig struct {
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// TTL is how long an instance stays listed without a heartbeat.
+	TTL time.Duration `yaml:"ttl" json:"ttl"`
+	// Heartbeat is how often registered instances refresh their entry.
+	Heartbeat time.Duration `yaml:"heartbeat" json:"heartbeat"`
+	// Logger receives failed heartbeats.
+	Logger Logger `yaml:"-" json:"-"`
+}
+
+type Logger interface {
+	Error(format string, args ...interface{})
+}
+
+func DefaultRedisConfig() RedisConfig {
+	return RedisConfig{
+		Prefix:    "flux:registry:",
+		TTL:       15 * time.Second,
+		Heartbeat: 5 * time.Second,
+	}
+}
+
+// Redis lets services register themselves. Each instance is kept in a hash
+// per service and listed in a sorted set scored by its expiry, which
+// heartbeats push forward. Instances that stop sending heartbeats drop out
+// after TTL.
+type Redis struct {
+	client     redis.UniversalClient
+	config     RedisConfig
+	mu         sync.Mutex
+	heartbeats map[string]context.CancelFunc
+}
+
+func NewRedis(client redis.UniversalClient, config RedisConfig) *Redis {
+	defaults := DefaultRedisConfig()
+	if config.Prefix == "" {
+		config.Prefix = defaults.Prefix
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.Heartbeat <= 0 || config.Heartbeat >= config.TTL {
+		config.Heartbeat = config.TTL / 3
+	}
+	return &Redis{client: client, config: config, heartbeats: make(map[string]context.CancelFunc)}
+}
+
+func (r *Redis) instancesKey(service string) string {
+	return r.config.Prefix + service + ":instances"
+}
+
+func (r *Redis) aliveKey(service string) string {
+	return r.config.Prefix + service + ":alive"
+}
+
+// Register stores inst and keeps it alive until Deregister.
+func (r *Redis) Register(ctx context.Context, inst Instance) error {
+	if err := inst.validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("failed to encode instance: %w", err)
+	}
+	if err := r.heartbeat(ctx, inst, data); err != nil {
+		return fmt.Errorf("failed to register %s: %w", inst.ID, err)
+	}
+
+	beatCtx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	if previous, ok := r.heartbeats[inst.ID]; ok {
+		previous()
+	}
+	r.heartbeats[inst.ID] = cancel
+	r.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(r.config.Heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-beatCtx.Done():
+				return
+			case <-ticker.C:
+				if err := r.heartbeat(beatCtx, inst, data); err != nil && beatCtx.Err() == nil && r.config.Logger != nil {
+					r.config.Logger.Error("Heartbeat of %s failed: %v", inst.ID, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// heartbeat writes the instance data along with its expiry, so an instance
+// pruned after missing a heartbeat comes back complete.
+func (r *Redis) heartbeat(ctx context.Context, inst Instance, data []byte) error {
+	expiry := float64(time.Now().Add(r.config.TTL).UnixMilli())
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.instancesKey(inst.Service), inst.ID, data)
+		pipe.ZAdd(ctx, r.aliveKey(inst.Service), redis.Z{Score: expiry, Member: inst.ID})
+		return nil
+	})
+	return err
+}
+
+// pruneScript drops instances whose heartbeats stopped. It runs atomically,
+// so a heartbeat arriving during the sweep is not lost.
+var pruneScript = redis.NewScript(`
+local expired = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("HDEL", KEYS[2], id)
+end
+return #expired`)
+
+func (r *Redis) Deregister(ctx context.Context, inst Instance) error {
+	r.mu.Lock()
+	if cancel, ok := r.heartbeats[inst.ID]; ok {
+		cancel()
+		delete(r.heartbeats, inst.ID)
+	}
+	r.mu.Unlock()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, r.aliveKey(inst.Service), inst.ID)
+		pipe.HDel(ctx, r.instancesKey(inst.Service), inst.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deregister %s: %w", inst.ID, err)
+	}
+	return nil
+}
+
+func (r *Redis) Instances(ctx context.Context, service string) ([]Instance, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	if err := pruneScript.Run(ctx, r.client, []string{r.aliveKey(service), r.instancesKey(service)}, now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", service, err)
+	}
+
+	ids, err := r.client.ZRangeByScore(ctx, r.aliveKey(service), &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", service, err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	values, err := r.client.HMGet(ctx, r.instancesKey(service), ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", service, err)
+	}
+
+	instances := make([]Instance, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var inst Instance
+		if err := json.Unmarshal([]byte(data), &inst); err == nil {
+			instances = append(instances, inst)
+		}
+	}
+	sortInstances(instances)
+	return instances, nil
+}
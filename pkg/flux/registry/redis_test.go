@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRegistry(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	reg := NewRedis(client, RedisConfig{TTL: 300 * time.Millisecond})
+	a := Instance{ID: "orders-1", Service: "orders", Address: "http://10.0.0.5:3000"}
+	b := Instance{ID: "orders-2", Service: "orders", Address: "http://10.0.0.6:3000", Metadata: map[string]string{"zone": "b"}}
+	require.NoError(t, reg.Register(ctx, a))
+	require.NoError(t, reg.Register(ctx, b))
+	t.Cleanup(func() { reg.Deregister(ctx, a) })
+
+	instances, err := reg.Instances(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, []Instance{a, b}, instances)
+
+	// b stops sending heartbeats, as if its process died.
+	reg.mu.Lock()
+	reg.heartbeats[b.ID]()
+	reg.mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		instances, err := reg.Instances(ctx, "orders")
+		return err == nil && len(instances) == 1 && instances[0].ID == a.ID
+	}, 2*time.Second, 50*time.Millisecond, "a keeps heartbeating, b expires")
+
+	// Expired instances are pruned from both keys.
+	ids, err := client.HKeys(ctx, "flux:registry:orders:instances").Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID}, ids)
+	alive, err := client.ZRange(ctx, "flux:registry:orders:alive", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID}, alive)
+
+	// A late heartbeat brings a pruned instance back complete.
+	data, _ := json.Marshal(b)
+	require.NoError(t, reg.heartbeat(ctx, b, data))
+	instances, err = reg.Instances(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, []Instance{a, b}, instances)
+
+	require.NoError(t, reg.Deregister(ctx, b))
+	instances, _ = reg.Instances(ctx, "orders")
+	assert.Equal(t, []Instance{a}, instances)
+}
@@ -0,0 +1,139 @@
+// Package registry lets flux services find each other by name.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoInstances = errors.New("registry: no healthy instances")
+
+// Instance is one running copy of a service.
+type Instance struct {
+	ID      string `json:"id" yaml:"id"`
+	Service string `json:"service" yaml:"service"`
+	// Address is the base URL other services call, e.g. http://10.0.0.5:3000.
+	Address  string            `json:"address" yaml:"address"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+func (i Instance) validate() error {
+	if i.ID == "" || i.Service == "" {
+		return fmt.Errorf("registry: instance needs an ID and a service")
+	}
+	if u, err := url.Parse(i.Address); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("registry: invalid instance address %q", i.Address)
+	}
+	return nil
+}
+
+type Registry interface {
+	// Register announces inst until Deregister is called. Registries that
+	// expire entries keep them alive with heartbeats.
+	Register(ctx context.Context, inst Instance) error
+	Deregister(ctx context.Context, inst Instance) error
+	// Instances returns the healthy instances of service.
+	Instances(ctx context.Context, service string) ([]Instance, error)
+}
+
+// Resolver turns service names into instance addresses for the HTTP client
+// (client.Config.Resolver), caching lookups for a short time.
+type Resolver struct {
+	registry Registry
+	ttl      time.Duration
+	mu       sync.Mutex
+	cache    map[string]resolved
+}
+
+type resolved struct {
+	addresses []string
+	expiresAt time.Time
+}
+
+// NewResolver caches lookups for ttl; zero or less means 5 seconds.
+func NewResolver(registry Registry, ttl ...time.Duration) *Resolver {
+	r := &Resolver{registry: registry, ttl: 5 * time.Second, cache: make(map[string]resolved)}
+	if len(ttl) > 0 && ttl[0] > 0 {
+		r.ttl = ttl[0]
+	}
+	return r
+}
+
+func (r *Resolver) Resolve(ctx context.Context, service string) ([]string, error) {
+	r.mu.Lock()
+	entry, ok := r.cache[service]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.addresses, nil
+	}
+
+	instances, err := r.registry.Instances(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNoInstances, service)
+	}
+
+	addresses := make([]string, len(instances))
+	for i, inst := range instances {
+		addresses[i] = inst.Address
+	}
+
+	r.mu.Lock()
+	r.cache[service] = resolved{addresses: addresses, expiresAt: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+	return addresses, nil
+}
+
+// Memory is an in-process registry for tests and single-binary setups.
+type Memory struct {
+	mu       sync.RWMutex
+	services map[string]map[string]Instance
+}
+
+func NewMemory() *Memory {
+	return &Memory{services: make(map[string]map[string]Instance)}
+}
+
+func (m *Memory) Register(_ context.Context, inst Instance) error {
+	if err := inst.validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.services[inst.Service] == nil {
+		m.services[inst.Service] = make(map[string]Instance)
+	}
+	m.services[inst.Service][inst.ID] = inst
+	return nil
+}
+
+func (m *Memory) Deregister(_ context.Context, inst Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.services[inst.Service], inst.ID)
+	return nil
+}
+
+func (m *Memory) Instances(_ context.Context, service string) ([]Instance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	instances := make([]Instance, 0, len(m.services[service]))
+	for _, inst := range m.services[service] {
+		instances = append(instances, inst)
+	}
+	sortInstances(instances)
+	return instances, nil
+}
+
+func sortInstances(instances []Instance) {
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+}
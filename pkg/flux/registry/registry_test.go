@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRegistry(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemory()
+
+	assert.Error(t, reg.Register(ctx, Instance{ID: "orders-1", Service: "orders", Address: "orders:3000"}))
+
+	a := Instance{ID: "orders-1", Service: "orders", Address: "http://10.0.0.5:3000"}
+	b := Instance{ID: "orders-2", Service: "orders", Address: "http://10.0.0.6:3000"}
+	assert.NoError(t, reg.Register(ctx, b))
+	assert.NoError(t, reg.Register(ctx, a))
+
+	instances, err := reg.Instances(ctx, "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []Instance{a, b}, instances)
+
+	assert.NoError(t, reg.Deregister(ctx, a))
+	instances, _ = reg.Instances(ctx, "orders")
+	assert.Equal(t, []Instance{b}, instances)
+}
+
+func TestResolverCachesLookups(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemory()
+	resolver := NewResolver(reg, time.Hour)
+
+	_, err := resolver.Resolve(ctx, "orders")
+	assert.True(t, errors.Is(err, ErrNoInstances))
+
+	inst := Instance{ID: "orders-1", Service: "orders", Address: "http://10.0.0.5:3000"}
+	assert.NoError(t, reg.Register(ctx, inst))
+
+	addresses, err := resolver.Resolve(ctx, "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://10.0.0.5:3000"}, addresses)
+
+	// Served from the cache until the TTL expires.
+	assert.NoError(t, reg.Deregister(ctx, inst))
+	addresses, err = resolver.Resolve(ctx, "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://10.0.0.5:3000"}, addresses)
+
+	assert.Equal(t, 5*time.Second, NewResolver(reg, 0).ttl)
+}
+
+func TestStaticRegistryReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("services:\n  orders:\n    - http://10.0.0.5:3000\n"), 0644))
+
+	reg, err := NewStatic(path)
+	assert.NoError(t, err)
+
+	instances, err := reg.Instances(context.Background(), "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []Instance{{ID: "orders-0", Service: "orders", Address: "http://10.0.0.5:3000"}}, instances)
+
+	assert.NoError(t, os.WriteFile(path, []byte("services:\n  orders:\n    - http://10.0.0.7:3000\n    - http://10.0.0.8:3000\n"), 0644))
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, os.Chtimes(path, later, later))
+
+	instances, err = reg.Instances(context.Background(), "orders")
+	assert.NoError(t, err)
+	assert.Len(t, instances, 2)
+	assert.Equal(t, "http://10.0.0.7:3000", instances[0].Address)
+
+	_, err = NewStatic(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
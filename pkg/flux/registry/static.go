@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Static reads instances from a YAML or JSON file, reloading it when it
+// changes:
+//
+//	services:
+//	  orders:
+//	    - http://10.0.0.5:3000
+//	    - http://10.0.0.6:3000
+//
+// Register and Deregister do nothing; edit the file instead.
+type Static struct {
+	path     string
+	mu       sync.Mutex
+	modTime  time.Time
+	services map[string][]Instance
+}
+
+type staticFile struct {
+	Services map[string][]string `yaml:"services" json:"services"`
+}
+
+func NewStatic(path string) (*Static, error) {
+	s := &Static{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewStaticMap serves a fixed map of service names to addresses.
+func NewStaticMap(services map[string][]string) *Static {
+	return &Static{services: staticInstances(services)}
+}
+
+func staticInstances(services map[string][]string) map[string][]Instance {
+	out := make(map[string][]Instance, len(services))
+	for service, addresses := range services {
+		for i, address := range addresses {
+			out[service] = append(out[service], Instance{
+				ID:      service + "-" + strconv.Itoa(i),
+				Service: service,
+				Address: address,
+			})
+		}
+	}
+	return out
+}
+
+func (s *Static) reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read service file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read service file: %w", err)
+	}
+
+	var file staticFile
+	if filepath.Ext(s.path) == ".json" {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse service file: %w", err)
+	}
+
+	s.services = staticInstances(file.Services)
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func (s *Static) Register(context.Context, Instance) error {
+	return nil
+}
+
+func (s *Static) Deregister(context.Context, Instance) error {
+	return nil
+}
+
+func (s *Static) Instances(_ context.Context, service string) ([]Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return append([]Instance(nil), s.services[service]...), nil
+}
@@ -0,0 +1,54 @@
+package flux
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CacheInvalidator drops cached responses by tag. The response cache in
+// plugins/cache registers itself with SetCacheInvalidator.
+type CacheInvalidator interface {
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+func (app *Application) SetCacheInvalidator(invalidator CacheInvalidator) {
+	app.invalidator = invalidator
+}
+
+// CacheTags labels the response so a later ctx.InvalidateCache with any of
+// the tags drops it from the response cache:
+//
+//	ctx.CacheTags("products", "product:"+id)
+func (c *Context) CacheTags(tags ...string) {
+	existing, _ := c.Locals("cache_tags").([]string)
+	c.Locals("cache_tags", append(existing, tags...))
+}
+
+// CacheTTL overrides how long the response cache keeps this response. A
+// zero or negative ttl keeps it out of the cache.
+func (c *Context) CacheTTL(ttl time.Duration) {
+	c.Locals("cache_ttl", ttl)
+}
+
+// InvalidateCache drops every cached response tagged with one of tags. It
+// does nothing when no response cache is configured.
+func (c *Context) InvalidateCache(tags ...string) error {
+	if c.app == nil || c.app.invalidator == nil {
+		return nil
+	}
+	return c.app.invalidator.InvalidateTags(c.Ctx.UserContext(), tags...)
+}
+
+// ResponseCacheTags returns the tags set with Context.CacheTags.
+func ResponseCacheTags(c *fiber.Ctx) []string {
+	tags, _ := c.Locals("cache_tags").([]string)
+	return tags
+}
+
+// ResponseCacheTTL returns the TTL set with Context.CacheTTL.
+func ResponseCacheTTL(c *fiber.Ctx) (time.Duration, bool) {
+	ttl, ok := c.Locals("cache_ttl").(time.Duration)
+	return ttl, ok
+}
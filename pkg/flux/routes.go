@@ -2,6 +2,7 @@ package flux
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -289,6 +290,33 @@ func (rm *RouteManager) GenerateControllerRoutes(outputDir, controllerName strin
 }
 
 
+// URL builds the path of the route registered under handler (for example
+// "UserController.HandleGetById"), filling :params in order.
+func (rm *RouteManager) URL(handler string, params ...interface{}) (string, error) {
+	for _, route := range rm.routes {
+		if route.Handler != handler {
+			continue
+		}
+
+		segments := strings.Split(route.Path, "/")
+		next := 0
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, ":") && segment != "*" {
+				continue
+			}
+			if next >= len(params) {
+				return "", fmt.Errorf("missing parameter %s for route %s", segment, handler)
+			}
+			segments[i] = url.PathEscape(fmt.Sprint(params[next]))
+			next++
+		}
+		return strings.Join(segments, "/"), nil
+	}
+
+	return "", fmt.Errorf("route %s not found", handler)
+}
+
+
 func (rm *RouteManager) SortRoutes() {
 	sort.Slice(rm.routes, func(i, j int) bool {
 		if rm.routes[i].Path == rm.routes[j].Path {
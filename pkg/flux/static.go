@@ -0,0 +1,255 @@
+package flux
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StaticConfig controls how Application.Static serves files from an fs.FS.
+type StaticConfig struct {
+	// Index is served for directory requests.
+	Index string
+	// SPA serves Index for unknown GET paths that accept HTML so client side
+	// routers can take over.
+	SPA bool
+	// SPAExclude lists path prefixes that never fall back to Index.
+	SPAExclude []string
+	// Precompressed serves name.br / name.gz when the client accepts them.
+	Precompressed bool
+	// MaxAge is used for files whose name does not carry a content hash.
+	MaxAge time.Duration
+	// ImmutablePattern matches hashed filenames that can be cached forever.
+	// The default accepts a hex hash of at least 8 digits, e.g. app.3f9a1c2b.js.
+	ImmutablePattern *regexp.Regexp
+	// ETag enables entity tags and If-None-Match handling.
+	ETag bool
+}
+
+var defaultImmutablePattern = regexp.MustCompile(`[.-][0-9a-f]{8,}\.[a-zA-Z0-9]+$`)
+
+func DefaultStaticConfig() StaticConfig {
+	return StaticConfig{
+		Index:            "index.html",
+		SPA:              false,
+		SPAExclude:       []string{"/api"},
+		Precompressed:    true,
+		MaxAge:           time.Hour,
+		ImmutablePattern: defaultImmutablePattern,
+		ETag:             true,
+	}
+}
+
+// Static serves fsys under prefix. fsys can be an embed.FS (usually wrapped in
+// fs.Sub to strip the build directory) or os.DirFS for files on disk.
+func (app *Application) Static(prefix string, fsys fs.FS, config ...StaticConfig) {
+	cfg := DefaultStaticConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+		if cfg.Index == "" {
+			cfg.Index = "index.html"
+		}
+		if cfg.ImmutablePattern == nil {
+			cfg.ImmutablePattern = defaultImmutablePattern
+		}
+	}
+
+	prefix = "/" + strings.Trim(prefix, "/")
+	app.server.Use(prefix, newStaticHandler(prefix, fsys, cfg))
+	app.logger.Info("Serving static files at %s", prefix)
+}
+
+type staticHandler struct {
+	prefix string
+	fsys   fs.FS
+	config StaticConfig
+	etags  sync.Map
+}
+
+func newStaticHandler(prefix string, fsys fs.FS, config StaticConfig) fiber.Handler {
+	h := &staticHandler{prefix: prefix, fsys: fsys, config: config}
+	return h.handle
+}
+
+func (h *staticHandler) handle(c *fiber.Ctx) error {
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return c.Next()
+	}
+
+	name := h.resolve(c.Path())
+	if name != "" {
+		if served, err := h.serve(c, name); served || err != nil {
+			return err
+		}
+	}
+
+	err := c.Next()
+	if !h.config.SPA || !isNotFound(err) || !h.wantsIndex(c) {
+		return err
+	}
+
+	_, err = h.serve(c, h.config.Index)
+	return err
+}
+
+func (h *staticHandler) resolve(requestPath string) string {
+	rel := strings.TrimPrefix(requestPath, h.prefix)
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	if rel == "" || rel == "." {
+		return h.config.Index
+	}
+
+	info, err := fs.Stat(h.fsys, rel)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return path.Join(rel, h.config.Index)
+	}
+	return rel
+}
+
+func (h *staticHandler) wantsIndex(c *fiber.Ctx) bool {
+	if c.Method() != fiber.MethodGet {
+		return false
+	}
+
+	p := c.Path()
+	for _, excluded := range h.config.SPAExclude {
+		if excluded != "" && strings.HasPrefix(p, excluded) {
+			return false
+		}
+	}
+
+	if path.Ext(p) != "" {
+		return false
+	}
+
+	return c.Accepts(fiber.MIMETextHTML) != ""
+}
+
+func (h *staticHandler) serve(c *fiber.Ctx, name string) (bool, error) {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || info.IsDir() {
+		return false, nil
+	}
+
+	servedName, encoding := name, ""
+	if h.config.Precompressed {
+		servedName, encoding = h.precompressed(c, name)
+	}
+
+	data, err := fs.ReadFile(h.fsys, servedName)
+	if err != nil {
+		return false, fmt.Errorf("failed to read static file %s: %w", servedName, err)
+	}
+
+	if encoding != "" {
+		c.Set(fiber.HeaderContentEncoding, encoding)
+	}
+	if h.config.Precompressed {
+		c.Vary(fiber.HeaderAcceptEncoding)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderCacheControl, h.cacheControl(name))
+
+	if modTime := info.ModTime(); !modTime.IsZero() {
+		c.Set(fiber.HeaderLastModified, modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if h.config.ETag {
+		etag := h.etag(servedName, info, data)
+		c.Set(fiber.HeaderETag, etag)
+		if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+			return true, c.SendStatus(fiber.StatusNotModified)
+		}
+	}
+
+	c.Status(fiber.StatusOK)
+	if c.Method() == fiber.MethodHead {
+		c.Set(fiber.HeaderContentLength, fmt.Sprintf("%d", len(data)))
+		return true, nil
+	}
+	return true, c.Send(data)
+}
+
+func (h *staticHandler) precompressed(c *fiber.Ctx, name string) (string, string) {
+	accept := c.Get(fiber.HeaderAcceptEncoding)
+	candidates := []struct {
+		encoding string
+		ext      string
+	}{
+		{"br", ".br"},
+		{"gzip", ".gz"},
+	}
+
+	for _, candidate := range candidates {
+		if !strings.Contains(accept, candidate.encoding) {
+			continue
+		}
+		if _, err := fs.Stat(h.fsys, name+candidate.ext); err == nil {
+			return name + candidate.ext, candidate.encoding
+		}
+	}
+	return name, ""
+}
+
+func (h *staticHandler) cacheControl(name string) string {
+	if path.Base(name) == h.config.Index {
+		return "no-cache"
+	}
+	if h.config.ImmutablePattern.MatchString(path.Base(name)) {
+		return "public, max-age=31536000, immutable"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(h.config.MaxAge.Seconds()))
+}
+
+func (h *staticHandler) etag(name string, info fs.FileInfo, data []byte) string {
+	key := fmt.Sprintf("%s:%d:%d", name, info.Size(), info.ModTime().UnixNano())
+	if cached, ok := h.etags.Load(key); ok {
+		return cached.(string)
+	}
+
+	sum := sha1.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	h.etags.Store(key, etag)
+	return etag
+}
+
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	var fiberErr *fiber.Error
+	return errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound
+}
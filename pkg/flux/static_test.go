@@ -0,0 +1,98 @@
+package flux
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Fluxgo/flux/pkg/flux/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestApplication() *Application {
+	app := &Application{
+		config: &Config{Name: "test", Version: "1.0.0"},
+		server: fiber.New(fiber.Config{ErrorHandler: defaultErrorHandler}),
+		logger: logger.New(logger.Config{Level: logger.LevelError}),
+	}
+	app.validator = newValidator(app)
+	app.routes = NewRouteManager(app)
+	return app
+}
+
+func TestStaticServesFilesAndSPAFallback(t *testing.T) {
+	app := newTestApplication()
+	files := fstest.MapFS{
+		"index.html":                {Data: []byte("<html>spa</html>")},
+		"assets/app.3f9a1c2b.js":    {Data: []byte("console.log(1)")},
+		"assets/app.3f9a1c2b.js.gz": {Data: []byte("gzipped")},
+		"robots.txt":                {Data: []byte("User-agent: *")},
+		"bootstrap_override.css":    {Data: []byte("body{}")},
+	}
+
+	config := DefaultStaticConfig()
+	config.SPA = true
+	app.Static("/", files, config)
+	app.Get().Get("/api/ping", func(c *fiber.Ctx) error {
+		return c.SendString("pong")
+	})
+
+	t.Run("hashed asset is immutable and precompressed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/assets/app.3f9a1c2b.js", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Contains(t, resp.Header.Get("Cache-Control"), "immutable")
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "gzipped", string(body))
+	})
+
+	t.Run("unhashed asset gets max-age", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/bootstrap_override.css", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "public, max-age=3600", resp.Header.Get("Cache-Control"))
+		assert.False(t, defaultImmutablePattern.MatchString("app-settings.json"))
+	})
+
+	t.Run("etag revalidation", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/robots.txt", nil))
+		assert.NoError(t, err)
+		etag := resp.Header.Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		req := httptest.NewRequest("GET", "/robots.txt", nil)
+		req.Header.Set("If-None-Match", etag)
+		resp, err = app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 304, resp.StatusCode)
+	})
+
+	t.Run("unknown page falls back to index", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/dashboard/settings", nil)
+		req.Header.Set("Accept", "text/html")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "<html>spa</html>", string(body))
+	})
+
+	t.Run("api routes are untouched", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/ping", nil))
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "pong", string(body))
+
+		req := httptest.NewRequest("GET", "/api/missing", nil)
+		req.Header.Set("Accept", "text/html")
+		resp, err = app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+}
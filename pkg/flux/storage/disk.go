@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"mime"
+	"path"
+	"time"
+)
+
+// Object describes a stored file.
+type Object struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	ModTime     time.Time `json:"mod_time"`
+}
+
+// Disk is a Storage that can also be read back and listed. The readers
+// returned by Stream implement io.Seeker when the driver supports it, which
+// lets download handlers serve byte ranges without reading the whole file.
+type Disk interface {
+	Storage
+	Get(ctx context.Context, key string) ([]byte, error)
+	Stream(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+func contentTypeFor(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func readAll(ctx context.Context, disk Disk, key string) ([]byte, error) {
+	r, _, err := disk.Stream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
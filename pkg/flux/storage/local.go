@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores objects as files below a root directory.
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+	return &Local{root: root}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file next to the destination and renames it into
+// place, so readers never see a partial file and failures leave nothing behind.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	dest, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	return readAll(ctx, l, key)
+}
+
+// Stream opens the file for key. The returned reader is an *os.File.
+func (l *Local) Stream(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+
+	key, _ = CleanKey(key)
+	return f, &Object{Key: key, Size: info.Size(), ContentType: contentTypeFor(key), ModTime: info.ModTime()}, nil
+}
+
+// List returns the objects whose key starts with prefix, sorted by key.
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	prefix = strings.ReplaceAll(prefix, "\\", "/")
+	start := l.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir, err := CleanKey(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		start = filepath.Join(l.root, filepath.FromSlash(dir))
+	}
+
+	var objects []Object
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ContentType: contentTypeFor(key), ModTime: info.ModTime()})
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return objects, nil
+}
+
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
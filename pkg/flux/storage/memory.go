@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// Memory keeps objects in a map. It is meant for tests and development.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: buf.Bytes(), contentType: contentType, modTime: time.Now()}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	return readAll(ctx, m, key)
+}
+
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error { return nil }
+
+func (m *Memory) Stream(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	return memoryReader{bytes.NewReader(obj.data)}, m.object(key, obj), nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var objects []Object
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, *m.object(key, obj))
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	_, ok := m.objects[key]
+	m.mu.RUnlock()
+	return ok, nil
+}
+
+func (m *Memory) object(key string, obj memoryObject) *Object {
+	contentType := obj.contentType
+	if contentType == "" {
+		contentType = contentTypeFor(key)
+	}
+	return &Object{Key: key, Size: int64(len(obj.data)), ContentType: contentType, ModTime: obj.modTime}
+}
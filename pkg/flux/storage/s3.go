@@ -0,0 +1,394 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures any S3-compatible API (AWS, MinIO, R2, ...).
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" json:"endpoint"`
+	Region    string `yaml:"region" json:"region"`
+	Bucket    string `yaml:"bucket" json:"bucket"`
+	AccessKey string `yaml:"access_key" json:"access_key"`
+	SecretKey string `yaml:"secret_key" json:"secret_key"`
+	// PathStyle addresses objects as endpoint/bucket/key instead of
+	// bucket.endpoint/key. Most self-hosted servers need it.
+	PathStyle  bool         `yaml:"path_style" json:"path_style"`
+	HTTPClient *http.Client `yaml:"-" json:"-"`
+}
+
+// S3 stores objects in a bucket using AWS Signature Version 4.
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires a bucket")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3.amazonaws.com"
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+
+	return &S3{config: config, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.config.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	// S3 rejects chunked uploads, so the length has to be known up front.
+	// Seekable readers (multipart files, os.File) are measured in place,
+	// anything else is spooled to a temp file first.
+	body, size, cleanup, err := sizedReader(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil && err != ErrNotFound {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// do signs and sends req, turning error statuses into errors.
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, "UNSIGNED-PAYLOAD")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		names = append(names, "content-type")
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func canonicalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode escapes everything except the RFC 3986 unreserved characters, as
+// SigV4 requires.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sizedReader(r io.Reader) (io.Reader, int64, func(), error) {
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err == nil {
+				if _, err := seeker.Seek(start, io.SeekStart); err == nil {
+					return seeker, end - start, func() {}, nil
+				}
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "flux-s3-*")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("failed to buffer object: %w", err)
+	}
+	return tmp, size, cleanup, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	return readAll(ctx, s, key)
+}
+
+// Stream returns the response body of a GET for key. It is not seekable.
+func (s *S3) Stream(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, objectFromHeader(key, resp), nil
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.do(req)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2 until every key under prefix is returned.
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+
+	for {
+		u := s.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object list: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, ContentType: contentTypeFor(c.Key), ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func objectFromHeader(key string, resp *http.Response) *Object {
+	obj := &Object{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if obj.ContentType == "" {
+		obj.ContentType = contentTypeFor(key)
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = t
+	}
+	return obj
+}
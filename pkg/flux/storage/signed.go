@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("storage: invalid signature")
+	ErrExpiredSignature = errors.New("storage: signature expired")
+)
+
+// Signer creates and verifies expiring download URLs. URLs have the form
+// BaseURL/<key>?expires=<unix>&signature=<hmac>.
+type Signer struct {
+	key     []byte
+	baseURL string
+	now     func() time.Time
+}
+
+func NewSigner(key []byte, baseURL string) *Signer {
+	return &Signer{key: key, baseURL: strings.TrimSuffix(baseURL, "/"), now: time.Now}
+}
+
+// SignedURL returns a URL for key that stops working after ttl.
+func (s *Signer) SignedURL(key string, ttl time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+	return s.baseURL + "/" + strings.Join(segments, "/") + "?" + query.Encode(), nil
+}
+
+// Verify checks the expires and signature query values for key.
+func (s *Signer) Verify(key, expires, signature string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(s.sign(key, expires)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if s.now().Unix() > unix {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+func (s *Signer) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	defaultSigner   *Signer
+	defaultSignerMu sync.RWMutex
+)
+
+// SetDefaultSigner sets the signer used by SignedURL.
+// Application.ServeSignedFiles calls it.
+func SetDefaultSigner(s *Signer) {
+	defaultSignerMu.Lock()
+	defaultSigner = s
+	defaultSignerMu.Unlock()
+}
+
+func DefaultSigner() *Signer {
+	defaultSignerMu.RLock()
+	defer defaultSignerMu.RUnlock()
+	return defaultSigner
+}
+
+// SignedURL signs key with the default signer.
+func SignedURL(key string, ttl time.Duration) (string, error) {
+	s := DefaultSigner()
+	if s == nil {
+		return "", errors.New("storage: no signer configured, set an application key")
+	}
+	return s.SignedURL(key, ttl)
+}
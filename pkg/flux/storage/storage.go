@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Storage is where uploaded files end up. Keys are slash separated and
+// relative, e.g. "avatars/3f2a.png".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Delete(ctx context.Context, key string) error
+}
+
+type Config struct {
+	// Driver is one of "local", "memory" or "s3".
+	Driver string   `yaml:"driver" json:"driver"`
+	Root   string   `yaml:"root" json:"root"`
+	S3     S3Config `yaml:"s3" json:"s3"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Driver: "local",
+		Root:   "storage/uploads",
+	}
+}
+
+// New creates the driver selected by config.Driver.
+func New(config Config) (Disk, error) {
+	switch config.Driver {
+	case "", "local":
+		root := config.Root
+		if root == "" {
+			root = DefaultConfig().Root
+		}
+		return NewLocal(root)
+	case "memory":
+		return NewMemory(), nil
+	case "s3":
+		return NewS3(config.S3)
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", config.Driver)
+	}
+}
+
+// CleanKey normalises key and rejects anything that could escape the storage
+// root, such as ".." segments, absolute paths or NUL bytes.
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	if strings.ContainsRune(key, 0) || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == "" {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanKey(t *testing.T) {
+	valid := map[string]string{
+		"a/b.txt":      "a/b.txt",
+		"a//b/./c.txt": "a/b/c.txt",
+		"a\\b.txt":     "a/b.txt",
+	}
+	for in, want := range valid {
+		got, err := CleanKey(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got)
+	}
+
+	for _, in := range []string{"", ".", "../etc/passwd", "a/../../b", "/etc/passwd", "a\x00b", "..\\secret"} {
+		_, err := CleanKey(in)
+		assert.ErrorIs(t, err, ErrInvalidKey, in)
+	}
+}
+
+func TestLocalPutAndDelete(t *testing.T) {
+	root := t.TempDir()
+	local, err := NewLocal(root)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, local.Put(ctx, "docs/report.txt", strings.NewReader("hello"), "text/plain"))
+
+	data, err := os.ReadFile(filepath.Join(root, "docs", "report.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	entries, _ := os.ReadDir(filepath.Join(root, "docs"))
+	assert.Len(t, entries, 1, "temp files are cleaned up")
+
+	assert.ErrorIs(t, local.Put(ctx, "../escape.txt", strings.NewReader("x"), ""), ErrInvalidKey)
+
+	assert.NoError(t, local.Delete(ctx, "docs/report.txt"))
+	assert.NoError(t, local.Delete(ctx, "docs/report.txt"))
+	_, err = os.Stat(filepath.Join(root, "docs", "report.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestS3AgainstStandIn(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+			r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			if r.ContentLength < 0 {
+				w.WriteHeader(http.StatusLengthRequired)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	s3, err := NewS3(S3Config{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "uploads",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+		PathStyle: true,
+	})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, s3.Put(ctx, "avatars/a b.png", io.MultiReader(strings.NewReader("png"), strings.NewReader("data")), "image/png"))
+	assert.Equal(t, "pngdata", objects["/uploads/avatars/a b.png"])
+
+	assert.NoError(t, s3.Delete(ctx, "avatars/a b.png"))
+	assert.Empty(t, objects)
+}
+
+func TestDisks(t *testing.T) {
+	local, err := NewLocal(t.TempDir())
+	assert.NoError(t, err)
+
+	for name, disk := range map[string]Disk{"local": local, "memory": NewMemory()} {
+		ctx := context.Background()
+		assert.NoError(t, disk.Put(ctx, "reports/2024/jan.csv", strings.NewReader("a,b\n"), ""), name)
+		assert.NoError(t, disk.Put(ctx, "reports/2024/feb.csv", strings.NewReader("c,d\n"), ""), name)
+		assert.NoError(t, disk.Put(ctx, "exports/all.json", strings.NewReader("{}"), ""), name)
+
+		data, err := disk.Get(ctx, "reports/2024/jan.csv")
+		assert.NoError(t, err, name)
+		assert.Equal(t, "a,b\n", string(data), name)
+
+		r, obj, err := disk.Stream(ctx, "exports/all.json")
+		if assert.NoError(t, err, name) {
+			_, seekable := r.(io.Seeker)
+			assert.True(t, seekable, name)
+			assert.Equal(t, int64(2), obj.Size, name)
+			assert.Equal(t, "application/json", obj.ContentType, name)
+			r.Close()
+		}
+
+		objects, err := disk.List(ctx, "reports/")
+		assert.NoError(t, err, name)
+		if assert.Len(t, objects, 2, name) {
+			assert.Equal(t, "reports/2024/feb.csv", objects[0].Key, name)
+		}
+
+		ok, err := disk.Exists(ctx, "exports/all.json")
+		assert.NoError(t, err, name)
+		assert.True(t, ok, name)
+
+		assert.NoError(t, disk.Delete(ctx, "exports/all.json"), name)
+		ok, _ = disk.Exists(ctx, "exports/all.json")
+		assert.False(t, ok, name)
+		_, err = disk.Get(ctx, "exports/all.json")
+		assert.ErrorIs(t, err, ErrNotFound, name)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner([]byte("app-key"), "/files/")
+	now := time.Unix(1700000000, 0)
+	signer.now = func() time.Time { return now }
+
+	signed, err := signer.SignedURL("reports/q1 summary.pdf", time.Minute)
+	assert.NoError(t, err)
+
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "/files/reports/q1%20summary.pdf", u.EscapedPath())
+
+	q := u.Query()
+	assert.NoError(t, signer.Verify("reports/q1 summary.pdf", q.Get("expires"), q.Get("signature")))
+	assert.ErrorIs(t, signer.Verify("reports/other.pdf", q.Get("expires"), q.Get("signature")), ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify("reports/q1 summary.pdf", "1800000000", q.Get("signature")), ErrInvalidSignature)
+
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, signer.Verify("reports/q1 summary.pdf", q.Get("expires"), q.Get("signature")), ErrExpiredSignature)
+}
@@ -0,0 +1,277 @@
+package flux
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/logger"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RowIterator yields rows for StreamJSON and StreamCSV. Close is called once
+// streaming stops, whether the iterator was exhausted or the client went away.
+type RowIterator interface {
+	Next() bool
+	Row() (interface{}, error)
+	Close() error
+}
+
+// StreamOptions tunes how often streamed rows are flushed to the client.
+// Zero fields keep the DefaultStreamOptions value.
+type StreamOptions struct {
+	// FlushEvery flushes after this many rows; negative disables it.
+	FlushEvery int
+	// FlushInterval flushes when this much time has passed since the last
+	// flush; negative disables it.
+	FlushInterval time.Duration
+	// Compress gzips the stream when the client accepts it.
+	Compress bool
+	// Filename sets Content-Disposition so browsers download the stream.
+	Filename string
+}
+
+func DefaultStreamOptions() StreamOptions {
+	return StreamOptions{
+		FlushEvery:    100,
+		FlushInterval: time.Second,
+	}
+}
+
+type sliceIterator struct {
+	value reflect.Value
+	index int
+}
+
+// SliceIterator iterates over the elements of a slice or array.
+func SliceIterator(slice interface{}) RowIterator {
+	return &sliceIterator{value: reflect.ValueOf(slice), index: -1}
+}
+
+func (it *sliceIterator) Next() bool {
+	it.index++
+	return it.index < it.value.Len()
+}
+
+func (it *sliceIterator) Row() (interface{}, error) {
+	return it.value.Index(it.index).Interface(), nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+type gormRowsIterator[T any] struct {
+	query *gorm.DB
+	rows  *sql.Rows
+}
+
+// GormRows runs query with a database cursor and scans each row into a T, so
+// large tables are never loaded into memory at once.
+func GormRows[T any](query *gorm.DB) (RowIterator, error) {
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rows: %w", err)
+	}
+	return &gormRowsIterator[T]{query: query, rows: rows}, nil
+}
+
+func (it *gormRowsIterator[T]) Next() bool {
+	return it.rows.Next()
+}
+
+func (it *gormRowsIterator[T]) Row() (interface{}, error) {
+	var row T
+	if err := it.query.ScanRows(it.rows, &row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (it *gormRowsIterator[T]) Close() error {
+	return it.rows.Close()
+}
+
+// StreamJSON writes rows as newline delimited JSON (application/x-ndjson).
+func (c *Context) StreamJSON(rows RowIterator, opts ...StreamOptions) error {
+	return c.streamRows(rows, "application/x-ndjson", newNDJSONRowWriter, opts)
+}
+
+// StreamCSV writes rows as CSV with a header taken from the first row's
+// json/csv tags.
+func (c *Context) StreamCSV(rows RowIterator, opts ...StreamOptions) error {
+	return c.streamRows(rows, "text/csv; charset=utf-8", newCSVRowWriter, opts)
+}
+
+type rowWriter interface {
+	Write(row interface{}) error
+	Flush() error
+}
+
+func (c *Context) streamRows(rows RowIterator, contentType string, newWriter func(io.Writer) rowWriter, opts []StreamOptions) error {
+	options := DefaultStreamOptions()
+	if len(opts) > 0 {
+		if opts[0].FlushEvery != 0 {
+			options.FlushEvery = opts[0].FlushEvery
+		}
+		if opts[0].FlushInterval != 0 {
+			options.FlushInterval = opts[0].FlushInterval
+		}
+		options.Compress = opts[0].Compress
+		options.Filename = opts[0].Filename
+	}
+
+	c.Ctx.Set(fiber.HeaderContentType, contentType)
+	c.Ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Ctx.Set("X-Content-Type-Options", "nosniff")
+	if options.Filename != "" {
+		c.Ctx.Attachment(options.Filename)
+		c.Ctx.Set(fiber.HeaderContentType, contentType)
+	}
+
+	compress := options.Compress && strings.Contains(c.Ctx.Get(fiber.HeaderAcceptEncoding), "gzip")
+	if options.Compress {
+		c.Ctx.Vary(fiber.HeaderAcceptEncoding)
+	}
+	if compress {
+		c.Ctx.Set(fiber.HeaderContentEncoding, "gzip")
+	}
+
+	// The stream writer runs after the handler has returned and the fiber.Ctx
+	// has been recycled, so only copies of what it needs are captured here.
+	log := c.Logger()
+	c.Ctx.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		defer rows.Close()
+
+		var out io.Writer = bw
+		var gz *gzip.Writer
+		if compress {
+			gz = gzip.NewWriter(bw)
+			defer gz.Close()
+			out = gz
+		}
+
+		flush := func() error {
+			if gz != nil {
+				if err := gz.Flush(); err != nil {
+					return err
+				}
+			}
+			return bw.Flush()
+		}
+
+		writer := newWriter(out)
+		pending, lastFlush := 0, time.Now()
+
+		for rows.Next() {
+			row, err := rows.Row()
+			if err != nil {
+				log.Error("Streaming stopped: %v", err)
+				return
+			}
+			if err := writer.Write(row); err != nil {
+				log.Error("Streaming stopped: %v", err)
+				return
+			}
+
+			pending++
+			if (options.FlushEvery > 0 && pending >= options.FlushEvery) ||
+				(options.FlushInterval > 0 && time.Since(lastFlush) >= options.FlushInterval) {
+				if err := writer.Flush(); err != nil {
+					return
+				}
+				// A failed flush means the client disconnected.
+				if err := flush(); err != nil {
+					log.Debug("Client disconnected during stream: %v", err)
+					return
+				}
+				pending, lastFlush = 0, time.Now()
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			return
+		}
+		flush()
+	})
+
+	return nil
+}
+
+type ndjsonRowWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONRowWriter(w io.Writer) rowWriter {
+	return &ndjsonRowWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *ndjsonRowWriter) Write(row interface{}) error {
+	return w.enc.Encode(row)
+}
+
+func (w *ndjsonRowWriter) Flush() error {
+	return nil
+}
+
+type csvRowWriter struct {
+	w       *csv.Writer
+	columns []csvColumn
+	record  []string
+}
+
+func newCSVRowWriter(w io.Writer) rowWriter {
+	return &csvRowWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvRowWriter) Write(row interface{}) error {
+	if values, ok := row.([]string); ok {
+		return w.w.Write(values)
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(row))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot stream %T as CSV", row)
+	}
+
+	if w.columns == nil {
+		w.columns = csvColumns(value.Type())
+		header := make([]string, len(w.columns))
+		for i, col := range w.columns {
+			header[i] = col.name
+		}
+		if err := w.w.Write(header); err != nil {
+			return err
+		}
+		w.record = make([]string, len(w.columns))
+	}
+
+	for i, col := range w.columns {
+		w.record[i] = formatCSVValue(value.FieldByIndex(col.index))
+	}
+	return w.w.Write(w.record)
+}
+
+func (w *csvRowWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Logger returns the request logger set by tracing, or the application logger.
+func (c *Context) Logger() *logger.Logger {
+	if l, ok := c.Locals("logger").(*logger.Logger); ok {
+		return l
+	}
+	if c.app != nil && c.app.logger != nil {
+		return c.app.logger
+	}
+	return logger.Global()
+}
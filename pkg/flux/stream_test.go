@@ -0,0 +1,108 @@
+package flux
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamJSONAndCSV(t *testing.T) {
+	app := newTestApplication()
+	rows := []codecRow{{ID: 1, Name: "Ada"}, {ID: 2, Name: "Grace"}}
+
+	app.Get().Get("/export.ndjson", func(c *fiber.Ctx) error {
+		return NewContext(c, app).StreamJSON(SliceIterator(rows), StreamOptions{FlushEvery: 1})
+	})
+	app.Get().Get("/export.csv", func(c *fiber.Ctx) error {
+		return NewContext(c, app).StreamCSV(SliceIterator(rows), StreamOptions{Compress: true, Filename: "users.csv"})
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/export.ndjson", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "{\"id\":1,\"name\":\"Ada\"}\n{\"id\":2,\"name\":\"Grace\"}\n", string(body))
+
+	req := httptest.NewRequest("GET", "/export.csv", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "users.csv")
+	gz, err := gzip.NewReader(resp.Body)
+	if assert.NoError(t, err) {
+		body, _ = io.ReadAll(gz)
+		assert.Equal(t, "id,name\n1,Ada\n2,Grace\n", string(body))
+	}
+}
+
+// gatedIterator yields rows forever, blocking after the first limit rows
+// until release is closed.
+type gatedIterator struct {
+	limit   int64
+	release chan struct{}
+	served  atomic.Int64
+	closed  chan struct{}
+}
+
+func (it *gatedIterator) Next() bool {
+	if it.served.Add(1) > it.limit {
+		<-it.release
+	}
+	return true
+}
+
+func (it *gatedIterator) Row() (interface{}, error) {
+	return codecRow{ID: int(it.served.Load()), Name: "row"}, nil
+}
+
+func (it *gatedIterator) Close() error {
+	close(it.closed)
+	return nil
+}
+
+func TestStreamFlushesBeforeEndAndStopsOnDisconnect(t *testing.T) {
+	app := newTestApplication()
+	rows := &gatedIterator{limit: 150, release: make(chan struct{}), closed: make(chan struct{})}
+	app.Get().Get("/export.ndjson", func(c *fiber.Ctx) error {
+		return NewContext(c, app).StreamJSON(rows, StreamOptions{Compress: true})
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.server.Listener(ln)
+	defer app.server.Shutdown()
+	var release sync.Once
+	unblock := func() { release.Do(func() { close(rows.release) }) }
+	defer unblock()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + ln.Addr().String() + "/export.ndjson")
+	require.NoError(t, err)
+
+	// The default FlushEvery still applies when only Compress is set, so
+	// the first 100 rows arrive while the iterator is blocked.
+	scanner := bufio.NewScanner(resp.Body)
+	for i := 0; i < 100; i++ {
+		require.True(t, scanner.Scan(), "row %d was not flushed", i+1)
+	}
+
+	resp.Body.Close()
+	unblock()
+	select {
+	case <-rows.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("streaming did not stop after the client disconnected")
+	}
+}
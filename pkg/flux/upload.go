@@ -0,0 +1,275 @@
+package flux
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Fluxgo/flux/pkg/flux/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	ErrFileTooLarge        = DefineError("FILE_TOO_LARGE", http.StatusRequestEntityTooLarge, "file too large")
+	ErrUnsupportedFileType = DefineError("UNSUPPORTED_FILE_TYPE", http.StatusUnsupportedMediaType, "unsupported file type")
+	ErrMissingFile         = DefineError("MISSING_FILE", http.StatusBadRequest, "missing file")
+)
+
+// UploadRule limits what a multipart field accepts.
+type UploadRule struct {
+	// MaxSize is the largest accepted file in bytes.
+	MaxSize int64 `yaml:"max_size" json:"max_size"`
+	// AllowedTypes lists accepted MIME types, sniffed from the file content
+	// rather than trusted from the client. Wildcards such as "image/*" work.
+	AllowedTypes []string `yaml:"allowed_types" json:"allowed_types"`
+	Required     bool     `yaml:"required" json:"required"`
+	// MaxFiles caps how many files the field may carry. Zero means one.
+	MaxFiles int `yaml:"max_files" json:"max_files"`
+	// Prefix is prepended to generated keys, e.g. "avatars".
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// Storage overrides the application storage for this field.
+	Storage storage.Storage `yaml:"-" json:"-"`
+}
+
+func DefaultUploadRule() UploadRule {
+	return UploadRule{
+		MaxSize:  10 * 1024 * 1024,
+		MaxFiles: 1,
+	}
+}
+
+// UploadedFile describes a stored upload.
+type UploadedFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Checksum    string `json:"checksum"`
+}
+
+// Upload validates and stores the file sent in field. It returns nil without
+// an error when the field is empty and the rule does not require it.
+func (c *Context) Upload(field string, rule UploadRule) (*UploadedFile, error) {
+	files, err := c.UploadFiles(map[string]UploadRule{field: rule})
+	if err != nil {
+		return nil, err
+	}
+	if len(files[field]) == 0 {
+		return nil, nil
+	}
+	return files[field][0], nil
+}
+
+// UploadFiles validates every field against its rule before storing anything.
+// If storing fails part way, files already stored are deleted again.
+func (c *Context) UploadFiles(rules map[string]UploadRule) (map[string][]*UploadedFile, error) {
+	form, err := c.Ctx.MultipartForm()
+	if err != nil && !errors.Is(err, fasthttp.ErrNoMultipartForm) {
+		return nil, ErrBadRequest.WithError(err)
+	}
+
+	type pending struct {
+		field  string
+		header *multipart.FileHeader
+		rule   UploadRule
+	}
+	var uploads []pending
+
+	fields := make([]string, 0, len(rules))
+	for field := range rules {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		rule := normalizeUploadRule(rules[field])
+
+		var headers []*multipart.FileHeader
+		if form != nil {
+			headers = form.File[field]
+		}
+		if len(headers) == 0 {
+			if rule.Required {
+				return nil, ErrMissingFile.WithDetail("field", field)
+			}
+			continue
+		}
+		if len(headers) > rule.MaxFiles {
+			return nil, ErrBadRequest.WithDetail("field", field).WithDetail("max_files", rule.MaxFiles)
+		}
+		for _, header := range headers {
+			if header.Size > rule.MaxSize {
+				return nil, ErrFileTooLarge.WithDetail("field", field).WithDetail("max_size", rule.MaxSize)
+			}
+			uploads = append(uploads, pending{field: field, header: header, rule: rule})
+		}
+	}
+
+	result := make(map[string][]*UploadedFile)
+	var stored []storedUpload
+
+	for _, u := range uploads {
+		store := u.rule.Storage
+		if store == nil && c.app != nil {
+			store = c.app.storage
+		}
+		if store == nil {
+			rollbackUploads(stored)
+			return nil, ErrInternalError.WithError(fmt.Errorf("storage is not configured"))
+		}
+
+		file, err := storeUpload(c.Context(), store, u.field, u.header, u.rule)
+		if err != nil {
+			rollbackUploads(stored)
+			return nil, err
+		}
+		stored = append(stored, storedUpload{store: store, key: file.Key})
+		result[u.field] = append(result[u.field], file)
+	}
+
+	return result, nil
+}
+
+type storedUpload struct {
+	store storage.Storage
+	key   string
+}
+
+func rollbackUploads(stored []storedUpload) {
+	for _, s := range stored {
+		s.store.Delete(context.Background(), s.key)
+	}
+}
+
+func normalizeUploadRule(rule UploadRule) UploadRule {
+	defaults := DefaultUploadRule()
+	if rule.MaxSize <= 0 {
+		rule.MaxSize = defaults.MaxSize
+	}
+	if rule.MaxFiles <= 0 {
+		rule.MaxFiles = defaults.MaxFiles
+	}
+	return rule
+}
+
+func storeUpload(ctx context.Context, store storage.Storage, field string, header *multipart.FileHeader, rule UploadRule) (*UploadedFile, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if !mimeAllowed(contentType, rule.AllowedTypes) {
+		return nil, ErrUnsupportedFileType.WithDetail("field", field).WithDetail("content_type", contentType)
+	}
+
+	// The client-supplied size is only a hint; the real size is counted while
+	// hashing so oversized bodies are caught either way.
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, io.LimitReader(f, rule.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if size > rule.MaxSize {
+		return nil, ErrFileTooLarge.WithDetail("field", field).WithDetail("max_size", rule.MaxSize)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	filename := sanitizeFilename(header.Filename)
+	key := randomKey() + strings.ToLower(filepath.Ext(filename))
+	if rule.Prefix != "" {
+		prefix, err := storage.CleanKey(rule.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload prefix: %w", err)
+		}
+		key = path.Join(prefix, key)
+	}
+
+	// f is passed as is so drivers can use it as an io.ReadSeeker; its full
+	// length is size, which was checked above.
+	if err := store.Put(ctx, key, f, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	return &UploadedFile{
+		Field:       field,
+		Filename:    filename,
+		Key:         key,
+		Size:        size,
+		ContentType: contentType,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+func mimeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "*/*" || a == contentType {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFilename strips directories and anything outside a conservative
+// character set from a client-supplied filename.
+func sanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+
+	name = strings.TrimLeft(b.String(), ".-")
+	if name == "" {
+		return "file"
+	}
+	if len(name) > 200 {
+		name = name[len(name)-200:]
+	}
+	return name
+}
+
+func randomKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return generateTraceID()
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,135 @@
+package flux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Fluxgo/flux/pkg/flux/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type failingStorage struct {
+	*storage.Memory
+	failOn   string
+	keys     map[string]bool
+	seekable bool
+}
+
+func newFailingStorage(failOn string) *failingStorage {
+	return &failingStorage{Memory: storage.NewMemory(), failOn: failOn, keys: make(map[string]bool)}
+}
+
+func (s *failingStorage) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if s.failOn != "" && strings.HasPrefix(key, s.failOn) {
+		return errors.New("disk full")
+	}
+	s.keys[key] = true
+	_, s.seekable = r.(io.ReadSeeker)
+	return s.Memory.Put(ctx, key, r, contentType)
+}
+
+func (s *failingStorage) Delete(ctx context.Context, key string) error {
+	delete(s.keys, key)
+	return s.Memory.Delete(ctx, key)
+}
+
+func newUploadRequest(files map[string]map[string][]byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for field, named := range files {
+		for name, data := range named {
+			part, _ := w.CreateFormFile(field, name)
+			part.Write(data)
+		}
+	}
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+func TestUploadValidatesAndStores(t *testing.T) {
+	app := newTestApplication()
+	store := newFailingStorage("")
+	app.SetStorage(store)
+
+	app.Get().Post("/avatar", func(c *fiber.Ctx) error {
+		file, err := NewContext(c, app).Upload("avatar", UploadRule{
+			MaxSize:      64,
+			AllowedTypes: []string{"image/*"},
+			Required:     true,
+			Prefix:       "avatars",
+		})
+		if err != nil {
+			return err
+		}
+		return c.JSON(file)
+	})
+
+	body, contentType := newUploadRequest(map[string]map[string][]byte{"avatar": {"../../Me Photo.PNG": pngHeader}})
+	req := httptest.NewRequest("POST", "/avatar", body)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var file UploadedFile
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&file))
+	assert.Equal(t, "Me-Photo.PNG", file.Filename)
+	assert.Regexp(t, `^avatars/[0-9a-f]{32}\.png$`, file.Key)
+	assert.Equal(t, "image/png", file.ContentType)
+	assert.Equal(t, int64(len(pngHeader)), file.Size)
+	assert.Len(t, file.Checksum, 64)
+	assert.True(t, store.keys[file.Key])
+	assert.True(t, store.seekable, "drivers get the seekable file")
+
+	cases := []struct {
+		files  map[string]map[string][]byte
+		status int
+	}{
+		{map[string]map[string][]byte{"avatar": {"a.png": []byte("just text pretending")}}, 415},
+		{map[string]map[string][]byte{"avatar": {"a.png": append(pngHeader, make([]byte, 64)...)}}, 413},
+		{map[string]map[string][]byte{"other": {"a.png": pngHeader}}, 400},
+	}
+	for _, tc := range cases {
+		body, contentType := newUploadRequest(tc.files)
+		req := httptest.NewRequest("POST", "/avatar", body)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.status, resp.StatusCode)
+	}
+}
+
+func TestUploadFilesRollsBackOnFailure(t *testing.T) {
+	app := newTestApplication()
+	store := newFailingStorage("images/")
+	app.SetStorage(store)
+
+	app.Get().Post("/both", func(c *fiber.Ctx) error {
+		_, err := NewContext(c, app).UploadFiles(map[string]UploadRule{
+			"image": {Prefix: "images"},
+			"doc":   {Prefix: "docs"},
+		})
+		return err
+	})
+
+	body, contentType := newUploadRequest(map[string]map[string][]byte{
+		"image": {"a.png": pngHeader},
+		"doc":   {"b.txt": []byte("hello")},
+	})
+	req := httptest.NewRequest("POST", "/both", body)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Empty(t, store.keys)
+}
@@ -0,0 +1,245 @@
+package flux
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// defaultValidator is only used by Contexts created without an application.
+var defaultValidator = newValidator(nil)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// newValidator returns the validator shared by Context.Validate, Bind,
+// CRUD and the middleware. Errors carry JSON field names, and the unique
+// and exists rules query app's database.
+func newValidator(app *Application) *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(jsonTagName)
+
+	_ = v.RegisterValidationCtx("unique", databaseRule(app, true))
+	_ = v.RegisterValidationCtx("exists", databaseRule(app, false))
+	return v
+}
+
+func jsonTagName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// ruleError carries a failed query or misconfiguration out of the unique
+// and exists rules, which can only report a bool. The rules record it on
+// the ruleState that Context.Validate puts in the context, and Validate
+// returns it, so a database outage is a 500 rather than a field message.
+type ruleError struct {
+	err error
+}
+
+func (e *ruleError) Error() string { return e.err.Error() }
+func (e *ruleError) Unwrap() error { return e.err }
+
+type ruleStateKey struct{}
+
+// ruleState collects the first rule failure of one Validate call.
+type ruleState struct {
+	err *ruleError
+}
+
+// databaseRule returns the unique or exists rule. When the query cannot run
+// the error goes to the caller's ruleState; callers of the bare validator,
+// which have none, see the field fail instead.
+func databaseRule(app *Application, unique bool) validator.FuncCtx {
+	return func(ctx context.Context, fl validator.FieldLevel) bool {
+		count, err := countMatching(ctx, app, fl, unique)
+		if err != nil {
+			if state, ok := ctx.Value(ruleStateKey{}).(*ruleState); ok && state.err == nil {
+				state.err = &ruleError{err: err}
+			}
+			return false
+		}
+		if unique {
+			return count == 0
+		}
+		return count > 0
+	}
+}
+
+// countMatching counts rows of the "table.column" rule parameter that hold
+// the field's value. For unique, the row of the struct being validated is
+// ignored when the struct has a non-zero ID, so updates pass.
+func countMatching(ctx context.Context, app *Application, fl validator.FieldLevel, excludeSelf bool) (int64, error) {
+	table, column, err := ruleTarget(fl.GetTag(), fl.Param())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fl.FieldName(), err)
+	}
+	if app == nil || app.database == nil || app.database.DB == nil {
+		return 0, fmt.Errorf("%s rule on %s needs a database", fl.GetTag(), fl.FieldName())
+	}
+
+	query := app.database.DB.WithContext(ctx).Table(table).Where(column+" = ?", fl.Field().Interface())
+	if excludeSelf {
+		parent := reflect.Indirect(fl.Parent())
+		if parent.Kind() == reflect.Struct {
+			if id := parent.FieldByName("ID"); id.IsValid() && !id.IsZero() {
+				query = query.Where("id <> ?", id.Interface())
+			}
+		}
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("%s rule on %s failed: %w", fl.GetTag(), fl.FieldName(), err)
+	}
+	return count, nil
+}
+
+// ruleTarget splits a unique or exists parameter into table and column.
+func ruleTarget(tag, param string) (string, string, error) {
+	table, column, ok := strings.Cut(param, ".")
+	if !ok || !identifierPattern.MatchString(table) || !identifierPattern.MatchString(column) {
+		return "", "", fmt.Errorf("%s rule needs a table.column parameter, got %q", tag, param)
+	}
+	return table, column, nil
+}
+
+// ruleSummary is what checkRules found in one struct type.
+type ruleSummary struct {
+	database bool
+	err      error
+}
+
+// ruleSummaries caches ruleSummary by reflect.Type.
+var ruleSummaries sync.Map
+
+// summarizeRules walks t's `validate` tags, including nested structs,
+// slices and maps, for unique and exists rules and checks their
+// parameters.
+func summarizeRules(t reflect.Type) ruleSummary {
+	if cached, ok := ruleSummaries.Load(t); ok {
+		return cached.(ruleSummary)
+	}
+	var summary ruleSummary
+	walkRules(t, map[reflect.Type]bool{}, &summary)
+	ruleSummaries.Store(t, summary)
+	return summary
+}
+
+func walkRules(t reflect.Type, seen map[reflect.Type]bool, summary *ruleSummary) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		for _, rule := range strings.FieldsFunc(field.Tag.Get("validate"), func(r rune) bool { return r == ',' || r == '|' }) {
+			tag, param, _ := strings.Cut(rule, "=")
+			if tag != "unique" && tag != "exists" {
+				continue
+			}
+			summary.database = true
+			if _, _, err := ruleTarget(tag, param); err != nil && summary.err == nil {
+				summary.err = fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+			}
+		}
+		walkRules(field.Type, seen, summary)
+	}
+}
+
+// checkRules reports malformed unique and exists parameters on v's type,
+// and such rules on an application without a database.
+func checkRules(app *Application, v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+	summary := summarizeRules(t)
+	if summary.err != nil {
+		return summary.err
+	}
+	if summary.database && (app == nil || app.database == nil || app.database.DB == nil) {
+		return fmt.Errorf("%s uses unique or exists rules, which need a database", t)
+	}
+	return nil
+}
+
+// CheckValidationRules reports misconfigured unique and exists rules on the
+// given types, such as a parameter that is not table.column or an
+// application without a database. CRUD runs it for its model; call it at
+// startup for request types so mistakes surface before the first request.
+// Context.Validate runs the same check and returns its error.
+func (app *Application) CheckValidationRules(types ...interface{}) error {
+	for _, v := range types {
+		if err := checkRules(app, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterValidation adds a rule usable in `validate` tags. Register rules
+// before the server starts; the validator is not safe for concurrent
+// registration.
+//
+//	app.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+//		return slugPattern.MatchString(fl.Field().String())
+//	})
+func (app *Application) RegisterValidation(tag string, fn validator.Func) error {
+	if err := app.validator.RegisterValidation(tag, fn); err != nil {
+		return fmt.Errorf("failed to register validation %s: %w", tag, err)
+	}
+	return nil
+}
+
+// RegisterValidationCtx adds a rule that receives the request context, for
+// rules that call a database or another service.
+func (app *Application) RegisterValidationCtx(tag string, fn validator.FuncCtx) error {
+	if err := app.validator.RegisterValidationCtx(tag, fn); err != nil {
+		return fmt.Errorf("failed to register validation %s: %w", tag, err)
+	}
+	return nil
+}
+
+// RegisterStructValidation adds a rule that sees the whole struct, for
+// checks spanning several fields. Report failures with sl.ReportError.
+func (app *Application) RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	app.validator.RegisterStructValidation(fn, types...)
+}
+
+func (c *Context) validator() *validator.Validate {
+	if c.app != nil && c.app.validator != nil {
+		return c.app.validator
+	}
+	return defaultValidator
+}
+
+// fieldPath is the JSON path of a failed field without the root struct,
+// e.g. "address.city".
+func fieldPath(e validator.FieldError) string {
+	ns := e.Namespace()
+	i := strings.Index(ns, ".")
+	if i < 0 {
+		return lowerFirst(e.Field())
+	}
+
+	parts := strings.Split(ns[i+1:], ".")
+	for j, part := range parts {
+		parts[j] = lowerFirst(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,185 @@
+package flux
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+type validationAccount struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Email string `json:"email_address" validate:"required,email,unique=validation_accounts.email"`
+}
+
+type validationAddress struct {
+	PostCode string `json:"post_code" validate:"required"`
+}
+
+type validationSignup struct {
+	Username        string            `json:"user_name" validate:"required,slug"`
+	Password        string            `json:"password" validate:"required"`
+	ConfirmPassword string            `json:"confirm_password" validate:"eqfield=Password"`
+	Address         validationAddress `json:"address"`
+	Plan            string            `json:"plan"`
+	Seats           int               `json:"seats"`
+}
+
+func validateWith(t *testing.T, app *Application, v interface{}) []FieldError {
+	t.Helper()
+	c := app.server.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.server.ReleaseCtx(c)
+	return fieldErrors(NewContext(c, app).Validate(v))
+}
+
+func TestValidatorCustomAndStructRules(t *testing.T) {
+	app := newTestApplication()
+	require.NoError(t, app.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsAny(fl.Field().String(), " /")
+	}))
+	app.RegisterStructValidation(func(sl validator.StructLevel) {
+		s := sl.Current().Interface().(validationSignup)
+		if s.Plan == "team" && s.Seats < 2 {
+			sl.ReportError(s.Seats, "seats", "Seats", "team_seats", "")
+		}
+	}, validationSignup{})
+
+	errs := validateWith(t, app, &validationSignup{
+		Username:        "not a slug",
+		Password:        "secret",
+		ConfirmPassword: "other",
+		Plan:            "team",
+	})
+
+	fields := make(map[string]FieldError)
+	for _, e := range errs {
+		fields[e.Field] = e
+	}
+	assert.Len(t, fields, 4)
+	assert.Equal(t, "slug", fields["user_name"].Tag)
+	assert.Equal(t, "The confirm_password must match password", fields["confirm_password"].Message)
+	assert.Equal(t, "required", fields["address.post_code"].Tag)
+	assert.Equal(t, "team_seats", fields["seats"].Tag)
+}
+
+func TestValidatorDatabaseRules(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t, &validationAccount{})
+	require.NoError(t, app.database.Create(&validationAccount{Email: "taken@example.com"}))
+
+	errs := validateWith(t, app, &validationAccount{Email: "taken@example.com"})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "email_address", errs[0].Field)
+	assert.Equal(t, "The email_address has already been taken", errs[0].Message)
+
+	// Updating the record that owns the value is not a conflict.
+	assert.Empty(t, validateWith(t, app, &validationAccount{ID: 1, Email: "taken@example.com"}))
+	assert.Empty(t, validateWith(t, app, &validationAccount{Email: "free@example.com"}))
+
+	type order struct {
+		AccountID uint `json:"account_id" validate:"exists=validation_accounts.id"`
+	}
+	assert.Empty(t, validateWith(t, app, &order{AccountID: 1}))
+	errs = validateWith(t, app, &order{AccountID: 99})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "exists", errs[0].Tag)
+}
+
+func TestValidatorDatabaseRuleFailures(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t)
+
+	type missingTable struct {
+		Email string `json:"email" validate:"unique=no_such_table.email"`
+	}
+	c := app.server.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.server.ReleaseCtx(c)
+	err := NewContext(c, app).Validate(&missingTable{Email: "a@example.com"})
+	require.Error(t, err)
+	assert.Nil(t, fieldErrors(err), "a failed query is not a field error")
+	assert.Equal(t, 500, AsAppError(err).StatusCode)
+
+	type badParam struct {
+		Email string `validate:"unique=accounts"`
+	}
+	err = NewContext(c, app).Validate(&badParam{Email: "a"})
+	require.Error(t, err)
+	assert.Nil(t, fieldErrors(err))
+	assert.Contains(t, err.Error(), "table.column")
+	assert.Equal(t, 500, AsAppError(err).StatusCode)
+
+	err = NewContext(c, newTestApplication()).Validate(&validationAccount{Email: "a@example.com"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "need a database")
+
+	// The bare validator has nowhere to report the failure, so the field
+	// fails instead of the process crashing.
+	var verrs validator.ValidationErrors
+	assert.NotPanics(t, func() { err = app.Validator().Struct(&missingTable{Email: "a@example.com"}) })
+	require.True(t, errors.As(err, &verrs))
+	assert.Equal(t, "unique", verrs[0].Tag())
+}
+
+func TestCheckValidationRules(t *testing.T) {
+	app := newTestApplication()
+	type nested struct {
+		Owner struct {
+			Email string `validate:"omitempty,unique=accounts"`
+		}
+	}
+	err := app.CheckValidationRules(&validationAccount{})
+	require.Error(t, err, "no database")
+	err = app.CheckValidationRules(nested{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Email")
+
+	app.database = newTestDatabase(t)
+	assert.NoError(t, app.CheckValidationRules(&validationAccount{}, []validationSignup{}))
+
+	type badModel struct {
+		ID    uint   `gorm:"primaryKey"`
+		Email string `validate:"exists=accounts"`
+	}
+	assert.Error(t, app.CRUD("/bad", &badModel{}), "CRUD checks rules at registration")
+}
+
+func TestValidateWithDetailsUsesFieldPaths(t *testing.T) {
+	app := newTestApplication()
+	c := app.server.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.server.ReleaseCtx(c)
+
+	type profile struct {
+		Address validationAddress `json:"address"`
+	}
+	errs := NewContext(c, app).ValidateWithDetails(&profile{})
+	assert.Contains(t, errs, "address.post_code")
+}
+
+func TestBindAndValidateUsesOneErrorShape(t *testing.T) {
+	app := newTestApplication()
+	app.server.Post("/accounts", func(c *fiber.Ctx) error {
+		var input struct {
+			Email string `json:"email_address" validate:"required,email"`
+		}
+		return NewContext(c, app).BindAndValidate(&input)
+	})
+
+	req := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"email_address":"nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.server.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, 422, resp.StatusCode)
+
+	var verrs validator.ValidationErrors
+	appErr := ValidationFailed(app.validator.Struct(struct {
+		Email string `json:"email_address" validate:"required"`
+	}{}))
+	require.True(t, errors.As(appErr, &verrs))
+	assert.Equal(t, []FieldError{{Field: "email_address", Tag: "required", Message: "The email_address field is required"}}, appErr.Details["errors"])
+}
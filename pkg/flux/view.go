@@ -0,0 +1,343 @@
+package flux
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+type ViewConfig struct {
+	Directory     string `yaml:"directory" json:"directory"`
+	Extension     string `yaml:"extension" json:"extension"`
+	LayoutsDir    string `yaml:"layouts_dir" json:"layouts_dir"`
+	PartialsDir   string `yaml:"partials_dir" json:"partials_dir"`
+	DefaultLayout string `yaml:"default_layout" json:"default_layout"`
+	AssetPrefix   string `yaml:"asset_prefix" json:"asset_prefix"`
+	AssetManifest string `yaml:"asset_manifest" json:"asset_manifest"`
+	// Cache keeps parsed templates in memory. Templates are always cached
+	// when ENVIRONMENT=production and re-parsed on every render otherwise.
+	Cache bool `yaml:"cache" json:"cache"`
+	// FS overrides Directory, e.g. for templates embedded with embed.FS.
+	FS    fs.FS            `yaml:"-" json:"-"`
+	Funcs template.FuncMap `yaml:"-" json:"-"`
+}
+
+func DefaultViewConfig() ViewConfig {
+	return ViewConfig{
+		Directory:   "templates",
+		Extension:   ".gohtml",
+		LayoutsDir:  "layouts",
+		PartialsDir: "partials",
+		AssetPrefix: "/assets",
+	}
+}
+
+// ViewEngine renders html/template views with layouts and partials.
+type ViewEngine struct {
+	config   ViewConfig
+	fsys     fs.FS
+	funcs    template.FuncMap
+	cache    map[string]*template.Template
+	manifest map[string]string
+	mu       sync.RWMutex
+}
+
+// RenderOption customises a single Context.Render call.
+type RenderOption func(*renderOptions)
+
+type renderOptions struct {
+	layout    string
+	setLayout bool
+}
+
+// Layout renders the view inside layouts/<name>.
+func Layout(name string) RenderOption {
+	return func(o *renderOptions) {
+		o.layout = name
+		o.setLayout = true
+	}
+}
+
+// NoLayout renders the view on its own, ignoring the default layout.
+func NoLayout() RenderOption {
+	return Layout("")
+}
+
+func NewViewEngine(config ViewConfig) (*ViewEngine, error) {
+	defaults := DefaultViewConfig()
+	if config.Directory == "" {
+		config.Directory = defaults.Directory
+	}
+	if config.Extension == "" {
+		config.Extension = defaults.Extension
+	}
+	if config.LayoutsDir == "" {
+		config.LayoutsDir = defaults.LayoutsDir
+	}
+	if config.PartialsDir == "" {
+		config.PartialsDir = defaults.PartialsDir
+	}
+	if config.AssetPrefix == "" {
+		config.AssetPrefix = defaults.AssetPrefix
+	}
+	if getEnvironment() == "production" {
+		config.Cache = true
+	}
+
+	fsys := config.FS
+	if fsys == nil {
+		fsys = os.DirFS(config.Directory)
+	}
+
+	v := &ViewEngine{
+		config: config,
+		fsys:   fsys,
+		funcs:  template.FuncMap{},
+		cache:  make(map[string]*template.Template),
+	}
+
+	for name, fn := range viewHelperStubs() {
+		v.funcs[name] = fn
+	}
+	for name, fn := range config.Funcs {
+		v.funcs[name] = fn
+	}
+
+	if config.AssetManifest != "" {
+		if err := v.loadManifest(); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// AddFunc registers a template helper available to every view.
+func (v *ViewEngine) AddFunc(name string, fn interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.funcs[name] = fn
+	v.cache = make(map[string]*template.Template)
+}
+
+// Render executes view inside layout (empty for none) and writes it to w.
+// helpers override the request-scoped stubs such as csrf and route.
+func (v *ViewEngine) Render(w io.Writer, view, layout string, data interface{}, helpers template.FuncMap) error {
+	base, err := v.lookup(view, layout)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := base.Clone()
+	if err != nil {
+		return fmt.Errorf("failed to clone template %s: %w", view, err)
+	}
+	if len(helpers) > 0 {
+		tmpl.Funcs(helpers)
+	}
+
+	entry := view
+	if layout != "" {
+		entry = path.Join(v.config.LayoutsDir, layout)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, entry, data); err != nil {
+		return fmt.Errorf("failed to render view %s: %w", view, err)
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (v *ViewEngine) lookup(view, layout string) (*template.Template, error) {
+	key := layout + "|" + view
+
+	if v.config.Cache {
+		v.mu.RLock()
+		tmpl, ok := v.cache[key]
+		v.mu.RUnlock()
+		if ok {
+			return tmpl, nil
+		}
+	}
+
+	tmpl, err := v.parse(view, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.config.Cache {
+		v.mu.Lock()
+		v.cache[key] = tmpl
+		v.mu.Unlock()
+	}
+
+	return tmpl, nil
+}
+
+func (v *ViewEngine) parse(view, layout string) (*template.Template, error) {
+	v.mu.RLock()
+	funcs := make(template.FuncMap, len(v.funcs))
+	for name, fn := range v.funcs {
+		funcs[name] = fn
+	}
+	v.mu.RUnlock()
+
+	root := template.New(view).Funcs(funcs)
+
+	partials, err := fs.Glob(v.fsys, path.Join(v.config.PartialsDir, "*"+v.config.Extension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partials: %w", err)
+	}
+	for _, file := range partials {
+		if err := v.parseFile(root, file); err != nil {
+			return nil, err
+		}
+	}
+
+	// The layout is parsed before the view so blocks the view defines
+	// (title, scripts, ...) replace the layout's defaults.
+	if layout != "" {
+		if err := v.parseFile(root, path.Join(v.config.LayoutsDir, layout)+v.config.Extension); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.parseFile(root, view+v.config.Extension); err != nil {
+		return nil, err
+	}
+
+	if layout != "" {
+		if _, err := root.AddParseTree("content", root.Lookup(view).Tree); err != nil {
+			return nil, fmt.Errorf("failed to attach view %s to layout %s: %w", view, layout, err)
+		}
+	}
+
+	return root, nil
+}
+
+func (v *ViewEngine) parseFile(root *template.Template, file string) error {
+	data, err := fs.ReadFile(v.fsys, file)
+	if err != nil {
+		return fmt.Errorf("failed to read template %s: %w", file, err)
+	}
+
+	name := strings.TrimSuffix(file, v.config.Extension)
+	var tmpl *template.Template
+	if name == root.Name() {
+		tmpl = root
+	} else {
+		tmpl = root.New(name)
+	}
+
+	if _, err := tmpl.Parse(string(data)); err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", file, err)
+	}
+	return nil
+}
+
+func (v *ViewEngine) loadManifest() error {
+	data, err := fs.ReadFile(v.fsys, v.config.AssetManifest)
+	if err != nil {
+		data, err = os.ReadFile(v.config.AssetManifest)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read asset manifest: %w", err)
+	}
+
+	manifest := make(map[string]string)
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse asset manifest: %w", err)
+	}
+	v.manifest = manifest
+	return nil
+}
+
+// AssetPath resolves name through the asset manifest (if any) and prefixes it.
+func (v *ViewEngine) AssetPath(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := v.manifest[name]; ok {
+		name = hashed
+	}
+	return strings.TrimSuffix(v.config.AssetPrefix, "/") + "/" + name
+}
+
+// viewHelperStubs registers the request-scoped helpers at parse time; the
+// real implementations are bound per request by Context.Render.
+func viewHelperStubs() template.FuncMap {
+	return template.FuncMap{
+		"route":     func(name string, params ...interface{}) (string, error) { return "", nil },
+		"asset":     func(name string) string { return name },
+		"csrf":      func() string { return "" },
+		"csrfField": func() template.HTML { return "" },
+	}
+}
+
+func (c *Context) viewHelpers(engine *ViewEngine) template.FuncMap {
+	token := c.CSRFToken()
+	return template.FuncMap{
+		"route": func(name string, params ...interface{}) (string, error) {
+			return c.app.routes.URL(name, params...)
+		},
+		"asset": engine.AssetPath,
+		"csrf": func() string {
+			return token
+		},
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="_csrf" value="` + template.HTMLEscapeString(token) + `">`)
+		},
+	}
+}
+
+// CSRFToken returns the token stored by CSRF middleware, if any.
+func (c *Context) CSRFToken() string {
+	for _, key := range []string{"csrf_token", "csrf"} {
+		if token, ok := c.Locals(key).(string); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// Render renders a view from the application's view engine, e.g.
+// ctx.Render("users/show", data, flux.Layout("main")).
+func (c *Context) Render(name string, data interface{}, opts ...RenderOption) error {
+	if c.app == nil || c.app.views == nil {
+		return NewAppError("view engine is not configured", 500)
+	}
+	engine := c.app.views
+
+	options := renderOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	layout := engine.config.DefaultLayout
+	if options.setLayout {
+		layout = options.layout
+	}
+
+	// htmx swaps the response into an existing page, so the layout is
+	// dropped and caches are told the body depends on HX-Request.
+	c.Ctx.Vary(HXRequestHeader)
+	if c.wantsPartial() {
+		layout = ""
+	}
+
+	var buf bytes.Buffer
+	if err := engine.Render(&buf, name, layout, data, c.viewHelpers(engine)); err != nil {
+		return err
+	}
+
+	c.Ctx.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Ctx.Send(buf.Bytes())
+}
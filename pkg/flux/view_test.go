@@ -0,0 +1,82 @@
+package flux
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderWithLayoutAndPartials(t *testing.T) {
+	app := newTestApplication()
+	views, err := NewViewEngine(ViewConfig{
+		FS: fstest.MapFS{
+			"layouts/main.gohtml":   {Data: []byte(`<title>{{block "title" .}}Default{{end}}</title>{{template "partials/nav" .}}<main>{{template "content" .}}</main>`)},
+			"partials/nav.gohtml":   {Data: []byte(`<nav><a href="{{route "UserController.HandleGetById" 7}}">me</a></nav>`)},
+			"users/show.gohtml":     {Data: []byte(`{{define "title"}}{{.Name}}{{end}}<p>{{.Name}}</p><script src="{{asset "app.js"}}"></script>{{csrfField}}`)},
+			"users/fragment.gohtml": {Data: []byte(`<p>{{.Name}}</p>`)},
+		},
+		DefaultLayout: "main",
+	})
+	assert.NoError(t, err)
+	app.SetViews(views)
+	app.routes.Add("GET", "/user/:id", "UserController.HandleGetById", "")
+
+	app.Get().Get("/show", func(c *fiber.Ctx) error {
+		c.Locals("csrf_token", "tok")
+		return NewContext(c, app).Render("users/show", H{"Name": "Ada"})
+	})
+	app.Get().Get("/fragment", func(c *fiber.Ctx) error {
+		return NewContext(c, app).Render("users/fragment", H{"Name": "Ada"}, NoLayout())
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/show", nil))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `<title>Ada</title><nav><a href="/user/7">me</a></nav><main><p>Ada</p><script src="/assets/app.js"></script><input type="hidden" name="_csrf" value="tok"></main>`, string(body))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/fragment", nil))
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, `<p>Ada</p>`, string(body))
+}
+
+func TestRenderSkipsLayoutForHTMX(t *testing.T) {
+	app := newTestApplication()
+	views, err := NewViewEngine(ViewConfig{
+		FS: fstest.MapFS{
+			"layouts/main.gohtml": {Data: []byte(`<html>{{template "content" .}}</html>`)},
+			"items/list.gohtml":   {Data: []byte(`<li>{{.}}</li>`)},
+		},
+		DefaultLayout: "main",
+	})
+	assert.NoError(t, err)
+	app.SetViews(views)
+
+	app.Get().Get("/items", func(c *fiber.Ctx) error {
+		ctx := NewContext(c, app)
+		ctx.HXTriggerEvent("itemsLoaded").HXTriggerEvent("toast", H{"level": "info"})
+		return ctx.Render("items/list", "one")
+	})
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("HX-Request", "true")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `<li>one</li>`, string(body))
+	assert.Equal(t, "HX-Request", resp.Header.Get("Vary"))
+	assert.JSONEq(t, `{"itemsLoaded":null,"toast":{"level":"info"}}`, resp.Header.Get("HX-Trigger"))
+
+	req = httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Boosted", "true")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, `<html><li>one</li></html>`, string(body))
+}
@@ -0,0 +1,575 @@
+package workflow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/queue"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound     = errors.New("workflow: instance not found")
+	ErrNotResumable = errors.New("workflow: instance cannot be resumed")
+)
+
+// JobType is the queue job that runs an instance.
+const JobType = "workflow:run"
+
+// Queue is the part of queue.Queue used to run instances.
+type Queue interface {
+	RegisterHandler(jobType string, handler queue.Handler)
+	Enqueue(jobType string, data map[string]interface{}, maxRetries int) (*queue.Job, error)
+}
+
+type Logger interface {
+	Error(format string, args ...interface{})
+}
+
+type Config struct {
+	// Queue runs instances. Without it they run in goroutines.
+	Queue Queue
+	// PollInterval is how often the engine looks for due instances, such
+	// as ones whose timer expired or that a crashed process left behind.
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"`
+	// Lease is how long a process owns an instance while running it. Steps
+	// are cancelled when it runs out, so it must exceed the longest step.
+	Lease time.Duration `yaml:"lease" json:"lease"`
+	// MaxAttempts and Backoff apply to steps without a Retry option.
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	BatchSize   int           `yaml:"batch_size" json:"batch_size"`
+	Logger      Logger        `yaml:"-" json:"-"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		MaxAttempts:  3,
+		Backoff:      time.Second,
+		MaxBackoff:   10 * time.Minute,
+		BatchSize:    100,
+	}
+}
+
+type Engine struct {
+	db        *gorm.DB
+	config    Config
+	mu        sync.RWMutex
+	workflows map[string][]step
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewEngine creates an engine storing instances in db. With a queue, it
+// registers the JobType handler, so create it before starting the queue.
+func NewEngine(db *gorm.DB, config Config) *Engine {
+	defaults := DefaultConfig()
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.Lease <= 0 {
+		config.Lease = defaults.Lease
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaults.Backoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+
+	e := &Engine{db: db, config: config, workflows: make(map[string][]step)}
+	if config.Queue != nil {
+		config.Queue.RegisterHandler(JobType, func(job *queue.Job) error {
+			id, _ := job.Data["id"].(string)
+			return e.Run(context.Background(), id)
+		})
+	}
+	return e
+}
+
+// Register makes workflows runnable by this engine. Every process that
+// may pick up an instance must register its workflow.
+func (e *Engine) Register(workflows ...Definition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range workflows {
+		e.workflows[w.Name()] = w.definition()
+	}
+}
+
+func (e *Engine) steps(name string) ([]step, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	steps, ok := e.workflows[name]
+	return steps, ok
+}
+
+// Start creates an instance of the named workflow with the initial state
+// and schedules its first step.
+func (e *Engine) Start(ctx context.Context, name string, state interface{}) (*Instance, error) {
+	inst, err := e.create(e.db.WithContext(ctx), name, state)
+	if err != nil {
+		return nil, err
+	}
+	e.schedule(ctx, inst.ID)
+	return inst, nil
+}
+
+// StartTx creates the instance in tx, so it only exists if the transaction
+// commits. The engine's poller runs it after the commit.
+func (e *Engine) StartTx(tx *gorm.DB, name string, state interface{}) (*Instance, error) {
+	return e.create(tx, name, state)
+}
+
+func (e *Engine) create(db *gorm.DB, name string, state interface{}) (*Instance, error) {
+	steps, ok := e.steps(name)
+	if !ok {
+		return nil, fmt.Errorf("workflow %s is not registered", name)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state: %w", err)
+	}
+	now := time.Now()
+	inst := &Instance{
+		ID:        newID(),
+		Workflow:  name,
+		Status:    StatusRunning,
+		State:     string(data),
+		NextRunAt: &now,
+	}
+	inst.StepName = currentStep(inst, steps)
+	if err := db.Create(inst).Error; err != nil {
+		return nil, fmt.Errorf("failed to start workflow %s: %w", name, err)
+	}
+	return inst, nil
+}
+
+// schedule hands a due instance to the queue, or runs it in a goroutine.
+func (e *Engine) schedule(ctx context.Context, id string) {
+	now := time.Now()
+	if err := e.db.WithContext(ctx).Model(&Instance{}).Where("id = ?", id).Update("enqueued_at", now).Error; err != nil {
+		e.logError("Failed to schedule workflow %s: %v", id, err)
+		return
+	}
+
+	if e.config.Queue == nil {
+		go func() {
+			if err := e.Run(context.Background(), id); err != nil {
+				e.logError("%v", err)
+			}
+		}()
+		return
+	}
+	if _, err := e.config.Queue.Enqueue(JobType, map[string]interface{}{"id": id}, 3); err != nil {
+		// The poller enqueues it again once the lease has passed.
+		e.logError("Failed to enqueue workflow %s: %v", id, err)
+	}
+}
+
+// Run executes the instance until it completes, fails or waits for a timer
+// or retry. It does nothing when the instance is not due or another process
+// is running it.
+func (e *Engine) Run(ctx context.Context, id string) error {
+	now := time.Now()
+	leaseUntil := now.Add(e.config.Lease)
+	token := newID()
+
+	result := e.db.WithContext(ctx).Model(&Instance{}).
+		Where("id = ? AND status IN ? AND next_run_at <= ?", id, activeStatuses, now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Updates(map[string]interface{}{"locked_until": leaseUntil, "lock_token": token, "enqueued_at": nil})
+	if result.Error != nil {
+		return fmt.Errorf("failed to lock workflow %s: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var inst Instance
+	if err := e.db.WithContext(ctx).First(&inst, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to load workflow %s: %w", id, err)
+	}
+
+	runCtx, cancel := context.WithDeadline(ctx, leaseUntil)
+	defer cancel()
+
+	steps, ok := e.steps(inst.Workflow)
+	if !ok {
+		inst.Status = StatusFailed
+		inst.LastError = fmt.Sprintf("workflow %s is not registered", inst.Workflow)
+		inst.NextRunAt = nil
+		return e.save(ctx, &inst, token, true)
+	}
+
+	for {
+		if runCtx.Err() != nil {
+			// Out of time; let the poller continue it.
+			resume := time.Now()
+			inst.NextRunAt = &resume
+			return e.save(ctx, &inst, token, true)
+		}
+		pause := e.advance(runCtx, &inst, steps)
+		inst.StepName = currentStep(&inst, steps)
+		if err := e.save(ctx, &inst, token, pause); err != nil || pause {
+			return err
+		}
+	}
+}
+
+// advance runs the next step or compensation and reports whether the
+// instance stops running for now.
+func (e *Engine) advance(ctx context.Context, inst *Instance, steps []step) bool {
+	now := time.Now()
+	switch inst.Status {
+	case StatusRunning:
+		if inst.Step >= len(steps) {
+			e.finish(inst, StatusCompleted)
+			return true
+		}
+
+		s := steps[inst.Step]
+		if s.timer {
+			e.record(ctx, inst, s.name, ActionSleep, now, nil)
+			inst.Step++
+			if s.sleep <= 0 {
+				return false
+			}
+			wake := now.Add(s.sleep)
+			inst.NextRunAt = &wake
+			return true
+		}
+
+		state, err := s.run(ctx, inst.State)
+		e.record(ctx, inst, s.name, ActionRun, now, err)
+		if err == nil {
+			inst.State = state
+			inst.Step++
+			inst.Attempts = 0
+			inst.LastError = ""
+			return false
+		}
+		if e.retry(inst, s, err) {
+			return true
+		}
+		inst.Attempts = 0
+		if hasCompensation(steps[:inst.Step]) {
+			inst.Status = StatusCompensating
+			return false
+		}
+		inst.Status = StatusFailed
+		inst.NextRunAt = nil
+		return true
+
+	case StatusCompensating:
+		for inst.Step > 0 && steps[inst.Step-1].compensate == nil {
+			inst.Step--
+		}
+		if inst.Step == 0 {
+			e.finish(inst, StatusCompensated)
+			return true
+		}
+
+		s := steps[inst.Step-1]
+		state, err := s.compensate(ctx, inst.State)
+		e.record(ctx, inst, s.name, ActionCompensate, now, err)
+		if err == nil {
+			inst.State = state
+			inst.Step--
+			inst.Attempts = 0
+			return false
+		}
+		if e.retry(inst, s, err) {
+			return true
+		}
+		inst.Attempts = 0
+		inst.Status = StatusFailed
+		inst.Compensating = true
+		inst.NextRunAt = nil
+		return true
+	}
+	return true
+}
+
+// retry schedules another attempt of s after a failure and reports whether
+// attempts are left.
+func (e *Engine) retry(inst *Instance, s step, err error) bool {
+	inst.Attempts++
+	inst.LastError = err.Error()
+
+	maxAttempts, backoff := s.maxAttempts, s.backoff
+	if maxAttempts <= 0 {
+		maxAttempts = e.config.MaxAttempts
+	}
+	if backoff <= 0 {
+		backoff = e.config.Backoff
+	}
+	if isPermanent(err) || inst.Attempts >= maxAttempts {
+		return false
+	}
+
+	for i := 1; i < inst.Attempts && backoff < e.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > e.config.MaxBackoff {
+		backoff = e.config.MaxBackoff
+	}
+	next := time.Now().Add(backoff)
+	inst.NextRunAt = &next
+	return true
+}
+
+func (e *Engine) finish(inst *Instance, status string) {
+	now := time.Now()
+	inst.Status = status
+	inst.NextRunAt = nil
+	inst.CompletedAt = &now
+}
+
+func hasCompensation(steps []step) bool {
+	for _, s := range steps {
+		if s.compensate != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// currentStep names the step an instance runs, compensates or failed on.
+func currentStep(inst *Instance, steps []step) string {
+	index := inst.Step
+	if inst.Status == StatusCompensating || inst.Compensating {
+		index--
+	}
+	switch inst.Status {
+	case StatusRunning, StatusCompensating, StatusFailed:
+		if index >= 0 && index < len(steps) {
+			return steps[index].name
+		}
+	}
+	return ""
+}
+
+// save writes the instance if this run still holds its lock, releasing the
+// lock when release is set.
+func (e *Engine) save(ctx context.Context, inst *Instance, token string, release bool) error {
+	if release {
+		inst.LockedUntil = nil
+		inst.LockToken = ""
+	}
+	result := e.db.WithContext(context.WithoutCancel(ctx)).Model(&Instance{}).
+		Where("id = ? AND lock_token = ?", inst.ID, token).
+		Select("status", "state", "step", "step_name", "compensating", "attempts", "last_error",
+			"next_run_at", "locked_until", "lock_token", "completed_at", "updated_at").
+		Updates(inst)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save workflow %s: %w", inst.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("workflow %s: lost the lock while running", inst.ID)
+	}
+	return nil
+}
+
+func (e *Engine) record(ctx context.Context, inst *Instance, name, action string, started time.Time, err error) {
+	rec := &StepRecord{
+		InstanceID: inst.ID,
+		Step:       name,
+		Action:     action,
+		Attempt:    inst.Attempts + 1,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if err := e.db.WithContext(context.WithoutCancel(ctx)).Create(rec).Error; err != nil {
+		e.logError("Failed to record step %s of workflow %s: %v", name, inst.ID, err)
+	}
+}
+
+// Resume continues a failed instance by retrying the step or compensation
+// it failed on, or runs a waiting instance right away.
+func (e *Engine) Resume(ctx context.Context, id string) (*Instance, error) {
+	inst, err := e.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if inst.LockedUntil != nil && inst.LockedUntil.After(now) {
+		return nil, fmt.Errorf("%w: it is running", ErrNotResumable)
+	}
+
+	updates := map[string]interface{}{"next_run_at": now}
+	switch inst.Status {
+	case StatusRunning, StatusCompensating:
+	case StatusFailed:
+		status := StatusRunning
+		if inst.Compensating {
+			status = StatusCompensating
+		}
+		updates["status"] = status
+		updates["compensating"] = false
+		updates["attempts"] = 0
+	default:
+		return nil, fmt.Errorf("%w: it is %s", ErrNotResumable, inst.Status)
+	}
+
+	result := e.db.WithContext(ctx).Model(&Instance{}).
+		Where("id = ? AND status = ?", id, inst.Status).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to resume workflow %s: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: it changed while resuming", ErrNotResumable)
+	}
+
+	e.schedule(ctx, id)
+	return e.Get(ctx, id)
+}
+
+func (e *Engine) Get(ctx context.Context, id string) (*Instance, error) {
+	var inst Instance
+	err := e.db.WithContext(ctx).First(&inst, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow %s: %w", id, err)
+	}
+	return &inst, nil
+}
+
+type Filter struct {
+	Workflow string
+	Status   string
+	Limit    int
+	Offset   int
+}
+
+// List returns instances matching filter, newest first.
+func (e *Engine) List(ctx context.Context, filter Filter) ([]Instance, error) {
+	db := e.db.WithContext(ctx).Order("created_at DESC, id")
+	if filter.Workflow != "" {
+		db = db.Where("workflow = ?", filter.Workflow)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 50
+	}
+
+	var instances []Instance
+	if err := db.Limit(filter.Limit).Offset(filter.Offset).Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workflows: %w", err)
+	}
+	return instances, nil
+}
+
+// History returns the step attempts of an instance in order.
+func (e *Engine) History(ctx context.Context, id string) ([]StepRecord, error) {
+	var records []StepRecord
+	if err := e.db.WithContext(ctx).Where("instance_id = ?", id).Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load history of workflow %s: %w", id, err)
+	}
+	return records, nil
+}
+
+// StartPolling looks for due instances in the background until
+// StopPolling. Timers, retries and instances left behind by a crashed
+// process only continue while some process polls.
+func (e *Engine) StartPolling() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	go e.poll(ctx, e.done)
+}
+
+func (e *Engine) poll(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.ScheduleDue(ctx); err != nil && ctx.Err() == nil {
+			e.logError("%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Engine) StopPolling() {
+	e.mu.Lock()
+	cancel, done := e.cancel, e.done
+	e.cancel, e.done = nil, nil
+	e.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// ScheduleDue schedules instances whose next step is due and that are
+// neither running nor recently scheduled, and returns how many it found.
+func (e *Engine) ScheduleDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	var ids []string
+	err := e.db.WithContext(ctx).Model(&Instance{}).
+		Where("status IN ? AND next_run_at <= ?", activeStatuses, now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Where("enqueued_at IS NULL OR enqueued_at < ?", now.Add(-e.config.Lease)).
+		Order("next_run_at").
+		Limit(e.config.BatchSize).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due workflows: %w", err)
+	}
+	for _, id := range ids {
+		e.schedule(ctx, id)
+	}
+	return len(ids), nil
+}
+
+func (e *Engine) logError(format string, args ...interface{}) {
+	if e.config.Logger != nil {
+		e.config.Logger.Error(format, args...)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
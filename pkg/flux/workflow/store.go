@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StatusRunning instances run their steps. NextRunAt tells when the
+	// next step is due, e.g. after a timer or a failed attempt.
+	StatusRunning = "running"
+	// StatusCompensating instances undo their completed steps after a step
+	// ran out of attempts.
+	StatusCompensating = "compensating"
+	StatusCompleted    = "completed"
+	StatusCompensated  = "compensated"
+	// StatusFailed instances stopped because a step without compensations
+	// before it, or a compensation, ran out of attempts. Resume retries it.
+	StatusFailed = "failed"
+)
+
+var activeStatuses = []string{StatusRunning, StatusCompensating}
+
+type Instance struct {
+	ID       string `gorm:"primaryKey;size:64" json:"id"`
+	Workflow string `gorm:"size:255;index;not null" json:"workflow"`
+	Status   string `gorm:"size:32;index;not null" json:"status"`
+	State    string `gorm:"type:text" json:"state"`
+	// Step is the index of the next step while running. While compensating
+	// it is the number of completed steps left to undo.
+	Step         int    `gorm:"not null;default:0" json:"step"`
+	StepName     string `gorm:"size:255" json:"step_name,omitempty"`
+	Compensating bool   `gorm:"not null;default:false" json:"compensating"`
+	// Attempts counts the failed attempts of the current step.
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextRunAt   *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	EnqueuedAt  *time.Time `json:"-"`
+	LockedUntil *time.Time `json:"-"`
+	LockToken   string     `gorm:"size:64" json:"-"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Instance) TableName() string {
+	return "workflow_instances"
+}
+
+// MarshalJSON writes the state as a JSON value rather than a string.
+func (i Instance) MarshalJSON() ([]byte, error) {
+	type alias Instance
+	state := json.RawMessage("null")
+	if i.State != "" {
+		state = json.RawMessage(i.State)
+	}
+	return json.Marshal(struct {
+		alias
+		State json.RawMessage `json:"state"`
+	}{alias(i), state})
+}
+
+// Decode unmarshals the instance's state into v.
+func (i *Instance) Decode(v interface{}) error {
+	if err := json.Unmarshal([]byte(i.State), v); err != nil {
+		return fmt.Errorf("failed to decode state of %s: %w", i.ID, err)
+	}
+	return nil
+}
+
+// StepRecord is one attempt of a step, a compensation or a timer.
+type StepRecord struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	InstanceID string    `gorm:"size:64;index;not null" json:"instance_id"`
+	Step       string    `gorm:"size:255" json:"step"`
+	Action     string    `gorm:"size:32" json:"action"`
+	Attempt    int       `json:"attempt"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+func (StepRecord) TableName() string {
+	return "workflow_steps"
+}
+
+const (
+	ActionRun        = "run"
+	ActionCompensate = "compensate"
+	ActionSleep      = "sleep"
+)
+
+// Migrate creates the workflow tables.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Instance{}, &StepRecord{}); err != nil {
+		return fmt.Errorf("failed to migrate workflows: %w", err)
+	}
+	return nil
+}
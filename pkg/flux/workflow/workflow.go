@@ -0,0 +1,158 @@
+// Package workflow runs multi-step business processes as sagas. Steps and
+// their compensations are Go functions over a typed state, and the engine
+// persists the state with GORM after every step. A crashed or restarted
+// process picks instances up where they stopped, including pending retries
+// and timers.
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Workflow defines the steps of a process with state S. S is stored as
+// JSON, so only exported fields survive between steps.
+//
+// Instances remember their position by step index: add steps at the end
+// while instances of a workflow are in flight.
+type Workflow[S any] struct {
+	name  string
+	steps []step
+}
+
+type step struct {
+	name string
+	// timer steps wait for sleep instead of running a function.
+	timer       bool
+	sleep       time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	run         stepFunc
+	compensate  stepFunc
+}
+
+// stepFunc runs a step on the encoded state and returns the new state.
+type stepFunc func(ctx context.Context, state string) (string, error)
+
+// Definition is implemented by *Workflow.
+type Definition interface {
+	Name() string
+	definition() []step
+}
+
+func New[S any](name string) *Workflow[S] {
+	return &Workflow[S]{name: name}
+}
+
+func (w *Workflow[S]) Name() string {
+	return w.name
+}
+
+func (w *Workflow[S]) definition() []step {
+	return w.steps
+}
+
+// Step appends a step. A failed step is retried as configured with Retry;
+// once it runs out of attempts, the completed steps are compensated in
+// reverse order.
+func (w *Workflow[S]) Step(name string, run func(ctx context.Context, state *S) error, opts ...StepOption) *Workflow[S] {
+	var options stepOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	s := step{name: name, maxAttempts: options.maxAttempts, backoff: options.backoff, run: bind(run)}
+	if options.compensate != nil {
+		compensate, ok := options.compensate.(func(context.Context, *S) error)
+		if !ok {
+			panic(fmt.Sprintf("workflow %s: compensation of step %s must be a func(context.Context, *%T) error", w.name, name, *new(S)))
+		}
+		s.compensate = bind(compensate)
+	}
+	w.steps = append(w.steps, s)
+	return w
+}
+
+// Sleep appends a timer. The instance continues with the next step after d,
+// also when the process restarts in between. A d of zero or less does not
+// wait.
+func (w *Workflow[S]) Sleep(name string, d time.Duration) *Workflow[S] {
+	if d < 0 {
+		d = 0
+	}
+	w.steps = append(w.steps, step{name: name, timer: true, sleep: d})
+	return w
+}
+
+func bind[S any](fn func(ctx context.Context, state *S) error) stepFunc {
+	return func(ctx context.Context, encoded string) (result string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("step panicked: %v", r)
+			}
+		}()
+
+		var state S
+		if encoded != "" {
+			if err := json.Unmarshal([]byte(encoded), &state); err != nil {
+				return "", Permanent(fmt.Errorf("failed to decode state: %w", err))
+			}
+		}
+		if err := fn(ctx, &state); err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(state)
+		if err != nil {
+			return "", Permanent(fmt.Errorf("failed to encode state: %w", err))
+		}
+		return string(data), nil
+	}
+}
+
+type StepOption func(*stepOptions)
+
+type stepOptions struct {
+	compensate  interface{}
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// Compensate sets the function that undoes a completed step when a later
+// step fails.
+func Compensate[S any](fn func(ctx context.Context, state *S) error) StepOption {
+	return func(o *stepOptions) { o.compensate = fn }
+}
+
+// Retry runs the step (or its compensation) up to maxAttempts times, waiting
+// backoff after the first failure and twice as long after each further one.
+// Steps default to Config.MaxAttempts and Config.Backoff.
+func Retry(maxAttempts int, backoff time.Duration) StepOption {
+	return func(o *stepOptions) {
+		o.maxAttempts = maxAttempts
+		o.backoff = backoff
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a step error as not worth retrying, such as a declined
+// payment.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
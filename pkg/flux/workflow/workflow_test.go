@@ -0,0 +1,240 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/queue"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type fulfilment struct {
+	OrderID  int      `json:"order_id"`
+	Charged  bool     `json:"charged"`
+	Reserved bool     `json:"reserved"`
+	Log      []string `json:"log"`
+}
+
+type fakeQueue struct {
+	handlers map[string]queue.Handler
+	jobs     []*queue.Job
+}
+
+func (q *fakeQueue) RegisterHandler(jobType string, handler queue.Handler) {
+	q.handlers[jobType] = handler
+}
+
+func (q *fakeQueue) Enqueue(jobType string, data map[string]interface{}, maxRetries int) (*queue.Job, error) {
+	job := &queue.Job{Type: jobType, Data: data, MaxRetries: maxRetries}
+	q.jobs = append(q.jobs, job)
+	return job, nil
+}
+
+// drain runs queued jobs until the queue is empty.
+func (q *fakeQueue) drain(t *testing.T) {
+	for len(q.jobs) > 0 {
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		require.NoError(t, q.handlers[job.Type](job))
+	}
+}
+
+func newTestEngine(t *testing.T, config Config) (*Engine, *fakeQueue, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, Migrate(db))
+
+	q := &fakeQueue{handlers: make(map[string]queue.Handler)}
+	config.Queue = q
+	return NewEngine(db, config), q, db
+}
+
+func appendLog(entry string) func(context.Context, *fulfilment) error {
+	return func(ctx context.Context, s *fulfilment) error {
+		s.Log = append(s.Log, entry)
+		return nil
+	}
+}
+
+func TestWorkflowCompletes(t *testing.T) {
+	engine, q, _ := newTestEngine(t, DefaultConfig())
+	engine.Register(New[fulfilment]("fulfil").
+		Step("charge", func(ctx context.Context, s *fulfilment) error {
+			s.Charged = true
+			return nil
+		}).
+		Step("reserve", func(ctx context.Context, s *fulfilment) error {
+			s.Reserved = true
+			return nil
+		}))
+
+	inst, err := engine.Start(context.Background(), "fulfil", fulfilment{OrderID: 7})
+	require.NoError(t, err)
+	assert.Equal(t, "charge", inst.StepName)
+	q.drain(t)
+
+	inst, err = engine.Get(context.Background(), inst.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, inst.Status)
+	assert.NotNil(t, inst.CompletedAt)
+
+	var state fulfilment
+	require.NoError(t, inst.Decode(&state))
+	assert.Equal(t, fulfilment{OrderID: 7, Charged: true, Reserved: true}, state)
+
+	history, err := engine.History(context.Background(), inst.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "reserve", history[1].Step)
+
+	_, err = engine.Start(context.Background(), "unknown", nil)
+	assert.Error(t, err)
+}
+
+func TestWorkflowRetriesThenCompensates(t *testing.T) {
+	engine, q, db := newTestEngine(t, DefaultConfig())
+	engine.Register(New[fulfilment]("fulfil").
+		Step("charge", appendLog("charged"), Compensate(appendLog("refunded"))).
+		Step("notify", appendLog("notified")).
+		Step("reserve", func(ctx context.Context, s *fulfilment) error {
+			return errors.New("out of stock")
+		}, Retry(2, time.Minute)))
+
+	inst, err := engine.Start(context.Background(), "fulfil", fulfilment{})
+	require.NoError(t, err)
+	q.drain(t)
+
+	inst, _ = engine.Get(context.Background(), inst.ID)
+	assert.Equal(t, StatusRunning, inst.Status)
+	assert.Equal(t, "reserve", inst.StepName)
+	assert.Equal(t, 1, inst.Attempts)
+	assert.Equal(t, "out of stock", inst.LastError)
+	assert.True(t, inst.NextRunAt.After(time.Now().Add(59*time.Second)))
+
+	// The retry is not due yet, so nothing is scheduled.
+	n, err := engine.ScheduleDue(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// The retry survives a restart: a new engine picks it up once due.
+	require.NoError(t, db.Model(&Instance{}).Where("id = ?", inst.ID).Update("next_run_at", time.Now()).Error)
+	n, err = engine.ScheduleDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	q.drain(t)
+
+	inst, _ = engine.Get(context.Background(), inst.ID)
+	assert.Equal(t, StatusCompensated, inst.Status)
+	assert.Equal(t, "out of stock", inst.LastError)
+
+	var state fulfilment
+	require.NoError(t, inst.Decode(&state))
+	assert.Equal(t, []string{"charged", "notified", "refunded"}, state.Log)
+}
+
+func TestWorkflowSleepAndResume(t *testing.T) {
+	engine, q, db := newTestEngine(t, DefaultConfig())
+	shipFails := true
+	engine.Register(New[fulfilment]("fulfil").
+		Step("charge", appendLog("charged")).
+		Sleep("cool-off", time.Hour).
+		Step("ship", func(ctx context.Context, s *fulfilment) error {
+			if shipFails {
+				return Permanent(errors.New("carrier rejected"))
+			}
+			s.Log = append(s.Log, "shipped")
+			return nil
+		}))
+
+	inst, err := engine.Start(context.Background(), "fulfil", fulfilment{})
+	require.NoError(t, err)
+	q.drain(t)
+
+	inst, _ = engine.Get(context.Background(), inst.ID)
+	assert.Equal(t, StatusRunning, inst.Status)
+	assert.Equal(t, "ship", inst.StepName)
+	assert.True(t, inst.NextRunAt.After(time.Now().Add(59*time.Minute)))
+
+	// Running before the timer expires does nothing.
+	require.NoError(t, engine.Run(context.Background(), inst.ID))
+	history, _ := engine.History(context.Background(), inst.ID)
+	assert.Len(t, history, 2)
+
+	require.NoError(t, db.Model(&Instance{}).Where("id = ?", inst.ID).Update("next_run_at", time.Now()).Error)
+	require.NoError(t, engine.Run(context.Background(), inst.ID))
+
+	inst, _ = engine.Get(context.Background(), inst.ID)
+	assert.Equal(t, StatusFailed, inst.Status)
+	assert.Equal(t, "ship", inst.StepName)
+	assert.Equal(t, "carrier rejected", inst.LastError)
+
+	shipFails = false
+	resumed, err := engine.Resume(context.Background(), inst.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, resumed.Status)
+	q.drain(t)
+
+	inst, _ = engine.Get(context.Background(), inst.ID)
+	assert.Equal(t, StatusCompleted, inst.Status)
+	var state fulfilment
+	require.NoError(t, inst.Decode(&state))
+	assert.Equal(t, []string{"charged", "shipped"}, state.Log)
+
+	_, err = engine.Resume(context.Background(), inst.ID)
+	assert.ErrorIs(t, err, ErrNotResumable)
+	_, err = engine.Resume(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	list, err := engine.List(context.Background(), Filter{Status: StatusCompleted})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestRunSkipsLockedInstances(t *testing.T) {
+	engine, _, db := newTestEngine(t, DefaultConfig())
+	calls := 0
+	engine.Register(New[fulfilment]("fulfil").Step("charge", func(ctx context.Context, s *fulfilment) error {
+		calls++
+		return nil
+	}))
+
+	inst, err := engine.StartTx(db, "fulfil", fulfilment{})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&Instance{}).Where("id = ?", inst.ID).Update("locked_until", time.Now().Add(time.Minute)).Error)
+
+	require.NoError(t, engine.Run(context.Background(), inst.ID))
+	assert.Zero(t, calls)
+	_, err = engine.Resume(context.Background(), inst.ID)
+	assert.ErrorIs(t, err, ErrNotResumable)
+}
+
+func TestWorkflowZeroSleepDoesNotWait(t *testing.T) {
+	engine, q, _ := newTestEngine(t, DefaultConfig())
+	engine.Register(New[fulfilment]("fulfil").
+		Step("charge", appendLog("charged")).
+		Sleep("none", 0).
+		Sleep("negative", -time.Minute).
+		Step("ship", appendLog("shipped")))
+
+	inst, err := engine.Start(context.Background(), "fulfil", fulfilment{})
+	require.NoError(t, err)
+	q.drain(t)
+
+	inst, _ = engine.Get(context.Background(), inst.ID)
+	assert.Equal(t, StatusCompleted, inst.Status)
+	var state fulfilment
+	require.NoError(t, inst.Decode(&state))
+	assert.Equal(t, []string{"charged", "shipped"}, state.Log)
+
+	history, _ := engine.History(context.Background(), inst.ID)
+	assert.Len(t, history, 4)
+}
@@ -0,0 +1,96 @@
+package flux
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Fluxgo/flux/pkg/flux/workflow"
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrWorkflowNotResumable = DefineError("WORKFLOW_NOT_RESUMABLE", http.StatusConflict, "workflow instance cannot be resumed")
+
+func init() {
+	MapError(workflow.ErrNotFound, ErrNotFound)
+	MapError(workflow.ErrNotResumable, ErrWorkflowNotResumable)
+}
+
+// UseWorkflows creates the workflow tables and an engine that polls for due
+// instances while the app runs. Instances run through the app's queue when
+// one is configured.
+func (app *Application) UseWorkflows(config workflow.Config) (*workflow.Engine, error) {
+	if app.database == nil {
+		return nil, fmt.Errorf("workflows require a database")
+	}
+	if err := workflow.Migrate(app.database.DB); err != nil {
+		return nil, err
+	}
+	if config.Queue == nil && app.queue != nil {
+		config.Queue = app.queue
+	}
+	if config.Logger == nil && app.logger != nil {
+		config.Logger = app.logger
+	}
+
+	engine := workflow.NewEngine(app.database.DB, config)
+	app.mu.Lock()
+	app.workflows = engine
+	app.mu.Unlock()
+	return engine, nil
+}
+
+// WorkflowAPI serves routes to inspect and resume workflow instances:
+//
+//	GET  /workflows              ?workflow=&status=&limit=&offset=
+//	GET  /workflows/:id          the instance and its step history
+//	POST /workflows/:id/resume
+//
+// The routes are meant for operators and the flux workflow commands; pass
+// middleware that restricts access.
+func (app *Application) WorkflowAPI(prefix string, engine *workflow.Engine, middleware ...fiber.Handler) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	group := app.server.Group(prefix)
+	for _, m := range middleware {
+		group.Use(m)
+	}
+
+	wrap := func(handler func(*Context) error) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			return handler(NewContext(c, app))
+		}
+	}
+
+	group.Get("/", wrap(func(ctx *Context) error {
+		instances, err := engine.List(ctx.UserContext(), workflow.Filter{
+			Workflow: ctx.Query("workflow"),
+			Status:   ctx.Query("status"),
+			Limit:    ctx.Ctx.QueryInt("limit", 50),
+			Offset:   ctx.Ctx.QueryInt("offset", 0),
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.Success(instances)
+	}))
+
+	group.Get("/:id", wrap(func(ctx *Context) error {
+		instance, err := engine.Get(ctx.UserContext(), ctx.Param("id"))
+		if err != nil {
+			return err
+		}
+		history, err := engine.History(ctx.UserContext(), instance.ID)
+		if err != nil {
+			return err
+		}
+		return ctx.Success(H{"instance": instance, "history": history})
+	}))
+
+	group.Post("/:id/resume", wrap(func(ctx *Context) error {
+		instance, err := engine.Resume(ctx.UserContext(), ctx.Param("id"))
+		if err != nil {
+			return err
+		}
+		return ctx.Success(instance)
+	}))
+}
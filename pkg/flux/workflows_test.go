@@ -0,0 +1,67 @@
+package flux
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux/workflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signupState struct {
+	Email   string `json:"email"`
+	Welcome bool   `json:"welcome"`
+}
+
+func TestWorkflowAPI(t *testing.T) {
+	app := newTestApplication()
+	app.database = newTestDatabase(t)
+
+	engine, err := app.UseWorkflows(workflow.DefaultConfig())
+	require.NoError(t, err)
+	engine.Register(workflow.New[signupState]("signup").
+		Step("welcome", func(ctx context.Context, s *signupState) error {
+			s.Welcome = true
+			return nil
+		}))
+	app.WorkflowAPI("/admin/workflows", engine)
+
+	inst, err := engine.Start(context.Background(), "signup", signupState{Email: "a@example.com"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		current, err := engine.Get(context.Background(), inst.ID)
+		return err == nil && current.Status == workflow.StatusCompleted
+	}, time.Second, 5*time.Millisecond)
+
+	send := func(method, url string) (int, map[string]interface{}) {
+		resp, err := app.Test(httptest.NewRequest(method, url, nil))
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		var out map[string]interface{}
+		json.Unmarshal(data, &out)
+		return resp.StatusCode, out
+	}
+
+	status, body := send("GET", "/admin/workflows?status=completed")
+	assert.Equal(t, 200, status)
+	list := body["data"].([]interface{})
+	require.Len(t, list, 1)
+	assert.Equal(t, map[string]interface{}{"email": "a@example.com", "welcome": true}, list[0].(map[string]interface{})["state"])
+
+	status, body = send("GET", "/admin/workflows/"+inst.ID)
+	assert.Equal(t, 200, status)
+	data := body["data"].(map[string]interface{})
+	assert.Len(t, data["history"], 1)
+
+	status, body = send("POST", "/admin/workflows/"+inst.ID+"/resume")
+	assert.Equal(t, 409, status)
+	assert.Equal(t, "WORKFLOW_NOT_RESUMABLE", body["code"])
+
+	status, _ = send("GET", "/admin/workflows/missing")
+	assert.Equal(t, 404, status)
+}
@@ -0,0 +1,385 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/valyala/fasthttp"
+)
+
+// Store is the byte storage behind the response cache.
+type Store interface {
+	// Get returns nil, nil when key is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value for ttl; a zero ttl never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type ResponseCacheConfig struct {
+	// TTL is how long a response is served as fresh.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long after TTL a response is still served
+	// while it is refreshed in the background. Zero disables it.
+	StaleWhileRevalidate time.Duration
+	// Vary lists request headers that are part of the cache key.
+	Vary []string
+	// Prefix is prepended to every key in the store.
+	Prefix string
+	// Next skips the cache when it returns true.
+	Next func(c *fiber.Ctx) bool
+}
+
+func DefaultResponseCacheConfig() ResponseCacheConfig {
+	return ResponseCacheConfig{
+		TTL:    time.Minute,
+		Vary:   []string{fiber.HeaderAccept, fiber.HeaderAcceptEncoding, fiber.HeaderAcceptLanguage},
+		Prefix: "response:",
+	}
+}
+
+// ResponseCache caches successful GET and HEAD responses on the server.
+// Requests with an Authorization header bypass it unless Authorization is
+// listed in Vary, and responses that set cookies or are marked private or
+// no-store are never stored.
+type ResponseCache struct {
+	store    Store
+	config   ResponseCacheConfig
+	token    string
+	inflight sync.Map
+	// maxTTL is the longest TTL a route or handler asked for, in
+	// nanoseconds. Tag markers must outlive every entry they invalidate.
+	maxTTL atomic.Int64
+}
+
+type cachedResponse struct {
+	Status     int                 `json:"status"`
+	Headers    map[string][]string `json:"headers"`
+	Body       []byte              `json:"body"`
+	Tags       []string            `json:"tags,omitempty"`
+	StoredAt   time.Time           `json:"stored_at"`
+	FreshUntil time.Time           `json:"fresh_until"`
+}
+
+// NewResponseCache creates a response cache and registers it with app, so
+// ctx.InvalidateCache reaches it.
+func NewResponseCache(app *flux.Application, store Store, config ...ResponseCacheConfig) *ResponseCache {
+	cfg := DefaultResponseCacheConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+		if cfg.TTL <= 0 {
+			cfg.TTL = DefaultResponseCacheConfig().TTL
+		}
+	}
+
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+
+	rc := &ResponseCache{store: store, config: cfg, token: hex.EncodeToString(token)}
+	rc.observeTTL(cfg.TTL)
+	if app != nil {
+		app.SetCacheInvalidator(rc)
+	}
+	return rc
+}
+
+// revalidateHeader marks the internal request that refreshes a stale entry.
+const revalidateHeader = "X-Flux-Cache-Revalidate"
+
+// Middleware caches responses of the routes it is mounted on. ttl overrides
+// the configured TTL for those routes; handlers can also call ctx.CacheTTL.
+//
+//	products.Get("/", rc.Middleware(5*time.Minute), listProducts)
+func (rc *ResponseCache) Middleware(ttl ...time.Duration) fiber.Handler {
+	routeTTL := rc.config.TTL
+	if len(ttl) > 0 {
+		routeTTL = ttl[0]
+	}
+	rc.observeTTL(routeTTL)
+
+	return func(c *fiber.Ctx) error {
+		method := c.Method()
+		if (method != fiber.MethodGet && method != fiber.MethodHead) || (rc.config.Next != nil && rc.config.Next(c)) {
+			return c.Next()
+		}
+		if c.Get(fiber.HeaderAuthorization) != "" && !rc.varies(fiber.HeaderAuthorization) {
+			return c.Next()
+		}
+
+		c.Vary(rc.config.Vary...)
+		key := rc.key(c)
+
+		revalidating := c.Get(revalidateHeader) == rc.token
+		if !revalidating && !strings.Contains(c.Get(fiber.HeaderCacheControl), "no-cache") {
+			entry, err := rc.load(c.UserContext(), key)
+			if err != nil {
+				return fmt.Errorf("failed to read response cache: %w", err)
+			}
+			if entry != nil {
+				now := time.Now()
+				if now.Before(entry.FreshUntil) {
+					return serve(c, entry, "HIT")
+				}
+				rc.revalidate(c, key)
+				return serve(c, entry, "STALE")
+			}
+		}
+
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		c.Set("X-Cache", "MISS")
+		if err := rc.save(c, key, start, routeTTL); err != nil {
+			return fmt.Errorf("failed to write response cache: %w", err)
+		}
+		return nil
+	}
+}
+
+// InvalidateTags drops every response tagged with one of tags. The marker
+// it stores expires once every response cached before it has expired too,
+// i.e. after the longest TTL plus StaleWhileRevalidate.
+func (rc *ResponseCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	ttl := time.Duration(rc.maxTTL.Load()) + rc.config.StaleWhileRevalidate
+	for _, tag := range tags {
+		if err := rc.store.Set(ctx, rc.config.Prefix+"tag:"+tag, []byte(now), ttl); err != nil {
+			return fmt.Errorf("failed to invalidate tag %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+func (rc *ResponseCache) varies(header string) bool {
+	for _, h := range rc.config.Vary {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rc *ResponseCache) key(c *fiber.Ctx) string {
+	var query []string
+	c.Request().URI().QueryArgs().VisitAll(func(k, v []byte) {
+		query = append(query, string(k)+"="+string(v))
+	})
+	sort.Strings(query)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", c.Method(), c.Path(), strings.Join(query, "&"))
+	for _, header := range rc.config.Vary {
+		fmt.Fprintf(h, "%s=%s\n", strings.ToLower(header), c.Get(header))
+	}
+	return rc.config.Prefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// load returns the entry for key unless one of its tags was invalidated
+// after it was stored.
+func (rc *ResponseCache) load(ctx context.Context, key string) (*cachedResponse, error) {
+	data, err := rc.store.Get(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, nil
+	}
+
+	for _, tag := range entry.Tags {
+		mark, err := rc.store.Get(ctx, rc.config.Prefix+"tag:"+tag)
+		if err != nil {
+			return nil, err
+		}
+		if invalidated, err := strconv.ParseInt(string(mark), 10, 64); err == nil && invalidated >= entry.StoredAt.UnixNano() {
+			return nil, nil
+		}
+	}
+	return &entry, nil
+}
+
+// save stores the response. StoredAt is the time the request started, so a
+// tag invalidated while the handler ran also invalidates this response.
+func (rc *ResponseCache) save(c *fiber.Ctx, key string, start time.Time, ttl time.Duration) error {
+	if override, ok := flux.ResponseCacheTTL(c); ok {
+		ttl = override
+	}
+
+	resp := c.Response()
+	cacheControl := string(resp.Header.Peek(fiber.HeaderCacheControl))
+	if ttl <= 0 || resp.StatusCode() != fiber.StatusOK || resp.IsBodyStream() ||
+		len(resp.Header.Peek(fiber.HeaderSetCookie)) > 0 ||
+		strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return nil
+	}
+
+	entry := cachedResponse{
+		Status:     resp.StatusCode(),
+		Headers:    make(map[string][]string),
+		Body:       append([]byte(nil), resp.Body()...),
+		Tags:       flux.ResponseCacheTags(c),
+		StoredAt:   start,
+		FreshUntil: start.Add(ttl),
+	}
+	resp.Header.VisitAll(func(k, v []byte) {
+		switch strings.ToLower(string(k)) {
+		case "content-length", "date", "connection", "transfer-encoding", "x-cache", "age":
+			return
+		}
+		entry.Headers[string(k)] = append(entry.Headers[string(k)], string(v))
+	})
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	rc.observeTTL(ttl)
+	return rc.store.Set(c.UserContext(), key, data, ttl+rc.config.StaleWhileRevalidate)
+}
+
+func (rc *ResponseCache) observeTTL(ttl time.Duration) {
+	for {
+		current := rc.maxTTL.Load()
+		if int64(ttl) <= current || rc.maxTTL.CompareAndSwap(current, int64(ttl)) {
+			return
+		}
+	}
+}
+
+// revalidate refreshes a stale entry by replaying the request through the
+// app in the background, at most once per key at a time.
+func (rc *ResponseCache) revalidate(c *fiber.Ctx, key string) {
+	if _, busy := rc.inflight.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+
+	req := fasthttp.AcquireRequest()
+	c.Request().CopyTo(req)
+	req.Header.Set(revalidateHeader, rc.token)
+	req.Header.Del(fiber.HeaderIfNoneMatch)
+	req.Header.Del(fiber.HeaderIfModifiedSince)
+	handler := c.App().Handler()
+	remote := c.Context().RemoteAddr()
+
+	go func() {
+		defer rc.inflight.Delete(key)
+		defer fasthttp.ReleaseRequest(req)
+
+		var fctx fasthttp.RequestCtx
+		fctx.Init(req, remote, nil)
+		handler(&fctx)
+	}()
+}
+
+func serve(c *fiber.Ctx, entry *cachedResponse, status string) error {
+	for name, values := range entry.Headers {
+		c.Response().Header.Del(name)
+		for _, v := range values {
+			c.Response().Header.Add(name, v)
+		}
+	}
+	c.Set("X-Cache", status)
+	c.Set(fiber.HeaderAge, strconv.Itoa(int(time.Since(entry.StoredAt).Seconds())))
+	c.Status(entry.Status)
+	return c.Send(entry.Body)
+}
+
+// MemoryStore keeps entries in process memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		return nil, nil
+	}
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, entry := range s.entries {
+			if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Store returns the plugin's Redis connection as a response cache Store,
+// sharing its key prefix.
+func (p *CachePlugin) Store() Store {
+	return &redisStore{client: p.client, prefix: p.prefix}
+}
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
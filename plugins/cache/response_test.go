@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Fluxgo/flux/pkg/flux"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachedApp(t *testing.T, config ResponseCacheConfig) (*fiber.App, *int32) {
+	t.Helper()
+	app, err := flux.New(&flux.Config{Name: "test", Version: "1.0.0"})
+	require.NoError(t, err)
+	rc := NewResponseCache(app, NewMemoryStore(), config)
+
+	var calls int32
+	server := app.Get()
+	server.Get("/products/:id", rc.Middleware(), func(c *fiber.Ctx) error {
+		ctx := flux.NewContext(c, app)
+		n := atomic.AddInt32(&calls, 1)
+		ctx.CacheTags("product:" + ctx.Param("id"))
+		if ctx.Query("private") != "" {
+			ctx.CacheTTL(0)
+		}
+		return ctx.JSON(flux.H{"id": ctx.Param("id"), "render": n})
+	})
+	server.Post("/products/:id", func(c *fiber.Ctx) error {
+		ctx := flux.NewContext(c, app)
+		if err := ctx.InvalidateCache("product:" + ctx.Param("id")); err != nil {
+			return err
+		}
+		return c.SendStatus(204)
+	})
+	return server, &calls
+}
+
+func fetch(t *testing.T, server *fiber.App, method, url string, headers ...string) (string, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, url, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := server.Test(req, -1)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	return string(body), resp.Header.Get("X-Cache")
+}
+
+func TestResponseCacheHitsAndTagInvalidation(t *testing.T) {
+	server, calls := newCachedApp(t, DefaultResponseCacheConfig())
+
+	body, status := fetch(t, server, "GET", "/products/1?b=2&a=1")
+	assert.Equal(t, `{"id":"1","render":1}`, body)
+	assert.Equal(t, "MISS", status)
+
+	// Query order does not matter.
+	body, status = fetch(t, server, "GET", "/products/1?a=1&b=2")
+	assert.Equal(t, `{"id":"1","render":1}`, body)
+	assert.Equal(t, "HIT", status)
+
+	// Vary headers and Authorization are part of the decision.
+	_, status = fetch(t, server, "GET", "/products/1?a=1&b=2", "Accept-Language", "fr")
+	assert.Equal(t, "MISS", status)
+	_, status = fetch(t, server, "GET", "/products/1?a=1&b=2", "Authorization", "Bearer x")
+	assert.Empty(t, status)
+
+	fetch(t, server, "GET", "/products/2")
+	fetch(t, server, "POST", "/products/1")
+
+	_, status = fetch(t, server, "GET", "/products/1?a=1&b=2")
+	assert.Equal(t, "MISS", status)
+	_, status = fetch(t, server, "GET", "/products/2")
+	assert.Equal(t, "HIT", status)
+
+	before := atomic.LoadInt32(calls)
+	fetch(t, server, "GET", "/products/3?private=1")
+	fetch(t, server, "GET", "/products/3?private=1")
+	assert.Equal(t, before+2, atomic.LoadInt32(calls))
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	config := DefaultResponseCacheConfig()
+	config.TTL = 50 * time.Millisecond
+	config.StaleWhileRevalidate = time.Minute
+	server, calls := newCachedApp(t, config)
+
+	body, _ := fetch(t, server, "GET", "/products/1")
+	assert.Equal(t, `{"id":"1","render":1}`, body)
+
+	time.Sleep(80 * time.Millisecond)
+	body, status := fetch(t, server, "GET", "/products/1")
+	assert.Equal(t, `{"id":"1","render":1}`, body)
+	assert.Equal(t, "STALE", status)
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(calls) == 2 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		body, status := fetch(t, server, "GET", "/products/1")
+		return status == "HIT" && body == fmt.Sprintf(`{"id":"1","render":%d}`, 2)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestResponseCacheTagMarkersExpire(t *testing.T) {
+	store := NewMemoryStore()
+	config := DefaultResponseCacheConfig()
+	config.TTL = time.Minute
+	config.StaleWhileRevalidate = 30 * time.Second
+	rc := NewResponseCache(nil, store, config)
+	rc.Middleware(5 * time.Minute)
+
+	start := time.Now()
+	require.NoError(t, rc.InvalidateTags(context.Background(), "product:42"))
+
+	marker, ok := store.entries[config.Prefix+"tag:product:42"]
+	require.True(t, ok)
+	assert.WithinDuration(t, start.Add(5*time.Minute+30*time.Second), marker.expiresAt, time.Second)
+}